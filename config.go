@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// configOptions control how the AWS configuration is loaded, which role, if any, is assumed and which endpoints are used.
+type configOptions struct {
+	profile     string
+	region      string
+	roleArn     string
+	externalId  string
+	sessionName string
+	mfaSerial   string
+	endpointUrl string
+	// serviceEndpointUrls override endpointUrl for individual services, keyed by service ID e.g. "SQS"
+	serviceEndpointUrls map[string]string
+}
+
+// configFlags adds the flags that control how the AWS configuration is loaded to a subcommand's flags, with the same
+// names as the main command's. Only the endpoints of the services the subcommand uses, identified by their service ID,
+// can be set. The returned function provides the options once the flags have been parsed.
+func configFlags(flags *flag.FlagSet, serviceIds ...string) func() configOptions {
+	profile := flags.String("profile", "", "Optional named profile to load AWS configuration and credentials from")
+	region := flags.String("region", "", "Optional AWS region to use instead of the configured region")
+	roleArn := flags.String("role-arn", "", "Optional ARN of a role to assume")
+	externalId := flags.String("external-id", "", "Optional external ID to use when assuming the role")
+	sessionName := flags.String("session-name", "aws-sns-listener", "Session name to use when assuming the role")
+	mfaSerial := flags.String("mfa-serial", "", "Optional serial number or ARN of the MFA device required to assume the role")
+	endpointUrl := flags.String("endpoint-url", "", "Optional URL to send AWS requests to instead of the usual endpoints")
+	serviceEndpointUrls := map[string]*string{}
+
+	for _, serviceId := range serviceIds {
+		serviceEndpointUrls[serviceId] = flags.String(
+			strings.ToLower(serviceId)+"-endpoint-url",
+			"",
+			fmt.Sprintf("Optional URL to send %s requests to, overrides -endpoint-url", serviceId),
+		)
+	}
+
+	return func() configOptions {
+		opts := configOptions{
+			profile:             *profile,
+			region:              *region,
+			roleArn:             *roleArn,
+			externalId:          *externalId,
+			sessionName:         *sessionName,
+			mfaSerial:           *mfaSerial,
+			endpointUrl:         *endpointUrl,
+			serviceEndpointUrls: map[string]string{},
+		}
+
+		for serviceId, serviceEndpointUrl := range serviceEndpointUrls {
+			opts.serviceEndpointUrls[serviceId] = *serviceEndpointUrl
+		}
+
+		return opts
+	}
+}
+
+// loadConfig loads the AWS configuration from the default sources, using the named profile, region and endpoints if provided.
+// If a role ARN is provided it's assumed with the loaded credentials. MFA token codes, whether for the role
+// or for a profile with mfa_serial set, are prompted for on prompt and read from input.
+func loadConfig(ctx context.Context, opts configOptions, input io.Reader, prompt io.Writer) (aws.Config, error) {
+	tokenProvider := mfaTokenProvider(input, prompt)
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = tokenProvider
+		}),
+	}
+
+	if opts.profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(opts.profile))
+	}
+
+	if opts.region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(opts.region))
+	}
+
+	if opts.endpointUrl != "" || len(opts.serviceEndpointUrls) > 0 {
+		loadOptions = append(loadOptions, config.WithEndpointResolverWithOptions(endpointResolver(opts.endpointUrl, opts.serviceEndpointUrls)))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
+
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if opts.roleArn == "" {
+		return cfg, nil
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(
+		sts.NewFromConfig(cfg),
+		opts.roleArn,
+		func(o *stscreds.AssumeRoleOptions) {
+			if opts.sessionName != "" {
+				o.RoleSessionName = opts.sessionName
+			}
+
+			if opts.externalId != "" {
+				o.ExternalID = aws.String(opts.externalId)
+			}
+
+			if opts.mfaSerial != "" {
+				o.SerialNumber = aws.String(opts.mfaSerial)
+				o.TokenProvider = tokenProvider
+			}
+		},
+	)
+
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+
+	return cfg, nil
+}
+
+// endpointResolver sends requests to custom endpoints, such as an emulator running locally. A service's own endpoint
+// takes precedence over the default endpoint and services without either use the usual AWS endpoints.
+func endpointResolver(endpointUrl string, serviceEndpointUrls map[string]string) aws.EndpointResolverWithOptions {
+	return aws.EndpointResolverWithOptionsFunc(func(service string, region string, options ...interface{}) (aws.Endpoint, error) {
+		url := serviceEndpointUrls[service]
+
+		if url == "" {
+			url = endpointUrl
+		}
+
+		if url == "" {
+			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+		}
+
+		return aws.Endpoint{
+			URL:               url,
+			SigningRegion:     region,
+			HostnameImmutable: true,
+		}, nil
+	})
+}
+
+// mfaTokenProvider prompts for an MFA token code and reads it from the first line of input.
+// The prompt isn't written to stdout so that it can't end up mixed in with messages.
+func mfaTokenProvider(input io.Reader, prompt io.Writer) func() (string, error) {
+	reader := bufio.NewReader(input)
+
+	return func() (string, error) {
+		fmt.Fprint(prompt, "MFA token code: ")
+
+		code, err := reader.ReadString('\n')
+
+		if err != nil && code == "" {
+			return "", fmt.Errorf("unable to read MFA token code: %w", err)
+		}
+
+		return strings.TrimSpace(code), nil
+	}
+}
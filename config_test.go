@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config")
+	credentialsPath := filepath.Join(dir, "credentials")
+
+	_ = os.WriteFile(configPath, []byte("[profile test]\nregion = eu-west-1\n"), 0600)
+	_ = os.WriteFile(credentialsPath, []byte("[test]\naws_access_key_id = test-key\naws_secret_access_key = test-secret\n"), 0600)
+
+	t.Setenv("AWS_CONFIG_FILE", configPath)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsPath)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	tests := map[string]struct {
+		shouldErr      bool
+		opts           configOptions
+		expectedRegion string
+		expectedKey    string
+	}{
+		"profile":            {false, configOptions{profile: "test"}, "eu-west-1", "test-key"},
+		"profile and region": {false, configOptions{profile: "test", region: "ap-southeast-2"}, "ap-southeast-2", "test-key"},
+		"endpoint":           {false, configOptions{profile: "test", endpointUrl: "http://localhost:4566"}, "eu-west-1", "test-key"},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := loadConfig(ctx, test.opts, strings.NewReader(""), &bytes.Buffer{})
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if cfg.Region != test.expectedRegion {
+					t.Fatalf("Expected region %s but got %s", test.expectedRegion, cfg.Region)
+				}
+
+				credentials, err := cfg.Credentials.Retrieve(ctx)
+
+				if err != nil {
+					t.Fatalf("Expected no error retrieving credentials but got %s", err.Error())
+				}
+
+				if credentials.AccessKeyID != test.expectedKey {
+					t.Fatalf("Expected access key %s but got %s", test.expectedKey, credentials.AccessKeyID)
+				}
+			}
+		})
+	}
+}
+
+func TestMFATokenProvider(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		input     string
+		expected  []string
+	}{
+		"one code":               {false, "123456\n", []string{"123456"}},
+		"code without a newline": {false, "123456", []string{"123456"}},
+		"several codes":          {false, " 123456 \n654321\n", []string{"123456", "654321"}},
+		"no code":                {true, "", []string{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			prompt := &bytes.Buffer{}
+			provider := mfaTokenProvider(strings.NewReader(test.input), prompt)
+
+			for _, expected := range test.expected {
+				code, err := provider()
+
+				if err != nil {
+					t.Fatalf("Expected no error but got %s", err.Error())
+				}
+
+				if code != expected {
+					t.Fatalf("Expected code %s but got %s", expected, code)
+				}
+			}
+
+			if test.shouldErr {
+				_, err := provider()
+
+				if err == nil {
+					t.Fatal("Expected error but got no error")
+				}
+			}
+
+			if !strings.HasPrefix(prompt.String(), "MFA token code: ") && len(test.expected) > 0 {
+				t.Fatalf("Expected a prompt but got %q", prompt.String())
+			}
+		})
+	}
+}
+
+func TestEndpointResolver(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr           bool
+		endpointUrl         string
+		serviceEndpointUrls map[string]string
+		service             string
+		expectedUrl         string
+	}{
+		"default endpoint":              {false, "http://localhost:4566", map[string]string{}, "SNS", "http://localhost:4566"},
+		"service endpoint":              {false, "http://localhost:4566", map[string]string{"SQS": "http://localhost:9324"}, "SQS", "http://localhost:9324"},
+		"other service endpoint":        {false, "http://localhost:4566", map[string]string{"SQS": "http://localhost:9324"}, "SNS", "http://localhost:4566"},
+		"empty service endpoint":        {false, "http://localhost:4566", map[string]string{"SQS": ""}, "SQS", "http://localhost:4566"},
+		"service endpoint only":         {false, "", map[string]string{"SQS": "http://localhost:9324"}, "SQS", "http://localhost:9324"},
+		"no endpoint for other service": {true, "", map[string]string{"SQS": "http://localhost:9324"}, "SNS", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resolver := endpointResolver(test.endpointUrl, test.serviceEndpointUrls)
+			endpoint, err := resolver.ResolveEndpoint(test.service, "us-east-1")
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if endpoint.URL != test.expectedUrl {
+					t.Fatalf("Expected URL %s but got %s", test.expectedUrl, endpoint.URL)
+				}
+
+				if endpoint.SigningRegion != "us-east-1" {
+					t.Fatalf("Expected signing region us-east-1 but got %s", endpoint.SigningRegion)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := configFlags(flags, "SNS", "STS")
+
+	err := flags.Parse([]string{"--profile", "test", "--endpoint-url", "http://localhost:4566", "--sns-endpoint-url", "http://localhost:4575"})
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err.Error())
+	}
+
+	result := opts()
+
+	if result.profile != "test" || result.sessionName != "aws-sns-listener" || result.endpointUrl != "http://localhost:4566" {
+		t.Fatalf("Options %+v did not match the parsed flags", result)
+	}
+
+	if result.serviceEndpointUrls["SNS"] != "http://localhost:4575" || result.serviceEndpointUrls["STS"] != "" {
+		t.Fatalf("Service endpoint URLs %v did not match the parsed flags", result.serviceEndpointUrls)
+	}
+
+	if flags.Lookup("sqs-endpoint-url") != nil {
+		t.Fatal("Expected no flag for the endpoint of a service that wasn't provided")
+	}
+}
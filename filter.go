@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+// readFilterPolicy returns the filter policy provided on the command line.
+// Values prefixed with "@" are treated as a path to a file containing the policy.
+// The policy is parsed so that mistakes are caught before any infrastructure is created.
+func readFilterPolicy(value string, scope filter.Scope) (string, *filter.Policy, error) {
+	policy := value
+
+	if strings.HasPrefix(value, "@") {
+		content, err := os.ReadFile(strings.TrimPrefix(value, "@"))
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		policy = string(content)
+	}
+
+	parsed, err := filter.Parse(policy, scope)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return policy, parsed, nil
+}
+
+// dryRunFilterPolicy reads messages from r and writes whether or not each of them matches the policy to w.
+// Messages are JSON objects in the shape of the SNS envelope, only Message and MessageAttributes are required.
+// This means the output of this utility can be fed back into it.
+func dryRunFilterPolicy(r io.Reader, w io.Writer, policy *filter.Policy) error {
+	decoder := json.NewDecoder(r)
+
+	for i := 1; ; i++ {
+		var n listener.Notification
+
+		err := decoder.Decode(&n)
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to read message %d: %w", i, err)
+		}
+
+		id := n.MessageId
+
+		if id == "" {
+			id = fmt.Sprintf("message %d", i)
+		}
+
+		attributes := make(map[string]filter.Attribute, len(n.MessageAttributes))
+
+		for k, v := range n.MessageAttributes {
+			attributes[k] = filter.Attribute(v)
+		}
+
+		match, err := policy.Match(attributes, n.Message)
+
+		switch {
+		case err != nil:
+			fmt.Fprintf(w, "ERROR\t%s\t%s\n", id, err.Error())
+		case match:
+			fmt.Fprintf(w, "MATCH\t%s\n", id)
+		default:
+			fmt.Fprintf(w, "NO MATCH\t%s\n", id)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
+)
+
+func TestReadFilterPolicy(t *testing.T) {
+	dir := t.TempDir()
+	validPath := filepath.Join(dir, "valid.json")
+	invalidPath := filepath.Join(dir, "invalid.json")
+
+	_ = os.WriteFile(validPath, []byte(`{"colour": ["blue"]}`), 0600)
+	_ = os.WriteFile(invalidPath, []byte(`{"colour": "blue"}`), 0600)
+
+	tests := map[string]struct {
+		shouldErr bool
+		value     string
+		scope     filter.Scope
+		expected  string
+	}{
+		"inline policy":         {false, `{"colour": ["blue"]}`, filter.ScopeMessageAttributes, `{"colour": ["blue"]}`},
+		"inline body policy":    {false, `{"order": {"colour": ["blue"]}}`, filter.ScopeMessageBody, `{"order": {"colour": ["blue"]}}`},
+		"policy file":           {false, "@" + validPath, filter.ScopeMessageAttributes, `{"colour": ["blue"]}`},
+		"invalid inline policy": {true, `{"colour": "blue"}`, filter.ScopeMessageAttributes, ""},
+		"invalid policy file":   {true, "@" + invalidPath, filter.ScopeMessageAttributes, ""},
+		"missing policy file":   {true, "@" + filepath.Join(dir, "missing.json"), filter.ScopeMessageAttributes, ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, _, err := readFilterPolicy(test.value, test.scope)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result != test.expected {
+					t.Fatalf(
+						"Filter policy %s did not match expected policy %s",
+						result,
+						test.expected,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestDryRunFilterPolicy(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		policy    string
+		scope     filter.Scope
+		input     string
+		expected  string
+	}{
+		"attribute policy": {
+			false,
+			`{"colour": ["blue"]}`,
+			filter.ScopeMessageAttributes,
+			`{"MessageId": "foo", "Message": "hello", "MessageAttributes": {"colour": {"Type": "String", "Value": "blue"}}}
+{"MessageId": "bar", "Message": "hello", "MessageAttributes": {"colour": {"Type": "String", "Value": "red"}}}
+{"Message": "hello"}`,
+			"MATCH\tfoo\nNO MATCH\tbar\nNO MATCH\tmessage 3\n",
+		},
+		"body policy": {
+			false,
+			`{"colour": ["blue"]}`,
+			filter.ScopeMessageBody,
+			`{
+				"MessageId": "foo",
+				"Message": "{\"colour\": \"blue\"}"
+			}
+			{"MessageId": "bar", "Message": "hello"}`,
+			"MATCH\tfoo\nERROR\tbar\t",
+		},
+		"invalid input": {
+			true,
+			`{"colour": ["blue"]}`,
+			filter.ScopeMessageAttributes,
+			`{"MessageId": "foo", "Message": "hello"} not json`,
+			"NO MATCH\tfoo\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := filter.Parse(test.policy, test.scope)
+
+			if err != nil {
+				t.Fatalf(
+					"Expected no error parsing policy but got %s",
+					err.Error(),
+				)
+			}
+
+			output := new(bytes.Buffer)
+
+			err = dryRunFilterPolicy(strings.NewReader(test.input), output, policy)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if !strings.HasPrefix(output.String(), test.expected) {
+				t.Fatalf(
+					"Output %q did not start with expected output %q",
+					output.String(),
+					test.expected,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+const sqsTarget = "AmazonSQS"
+
+// A jsonOperation handles an operation in the JSON protocol. It returns the operation's output.
+type jsonOperation func(ctx context.Context, body []byte) (any, error)
+
+// jsonHandler adapts an SQS operation on the Fake by decoding the request body into its input, which shares the
+// shape of the JSON request.
+func jsonHandler[I, O any](op func(context.Context, *I, ...func(*sqs.Options)) (*O, error)) jsonOperation {
+	return func(ctx context.Context, body []byte) (any, error) {
+		params := new(I)
+
+		err := json.Unmarshal(body, params)
+
+		if err != nil {
+			return nil, &smithy.GenericAPIError{Code: "SerializationException", Message: err.Error(), Fault: smithy.FaultClient}
+		}
+
+		return op(ctx, params)
+	}
+}
+
+func (s *Server) serveJSON(w http.ResponseWriter, r *http.Request, target string, requestId string) {
+	operation := s.jsonOperation(target)
+
+	if operation == nil {
+		writeJSONError(w, &smithy.GenericAPIError{
+			Code:    "UnknownOperationException",
+			Message: fmt.Sprintf("The operation %s is not supported.", target),
+			Fault:   smithy.FaultClient,
+		})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+
+	result, err := operation(r.Context(), body)
+
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	output, err := jsonOutput(result)
+
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
+
+// jsonOperation returns the handler for the target, or nil if the target isn't supported.
+func (s *Server) jsonOperation(target string) jsonOperation {
+	if !isNamespaced(target, sqsTarget) {
+		return nil
+	}
+
+	operations := map[string]jsonOperation{
+		"CreateQueue":             jsonHandler(s.fake.CreateQueue),
+		"DeleteQueue":             jsonHandler(s.fake.DeleteQueue),
+		"GetQueueUrl":             jsonHandler(s.fake.GetQueueUrl),
+		"ListQueues":              jsonHandler(s.fake.ListQueues),
+		"PurgeQueue":              jsonHandler(s.fake.PurgeQueue),
+		"GetQueueAttributes":      jsonHandler(s.fake.GetQueueAttributes),
+		"SendMessage":             jsonHandler(s.fake.SendMessage),
+		"ReceiveMessage":          s.receiveMessageJSON,
+		"DeleteMessage":           jsonHandler(s.fake.DeleteMessage),
+		"ChangeMessageVisibility": jsonHandler(s.fake.ChangeMessageVisibility),
+	}
+
+	return operations[strings.TrimPrefix(target, sqsTarget+".")]
+}
+
+// receiveMessageJSON also accepts MessageSystemAttributeNames, which newer clients send in place of AttributeNames.
+func (s *Server) receiveMessageJSON(ctx context.Context, body []byte) (any, error) {
+	params := struct {
+		sqs.ReceiveMessageInput
+		MessageSystemAttributeNames []types.QueueAttributeName
+	}{}
+
+	err := json.Unmarshal(body, &params)
+
+	if err != nil {
+		return nil, &smithy.GenericAPIError{Code: "SerializationException", Message: err.Error(), Fault: smithy.FaultClient}
+	}
+
+	params.AttributeNames = append(params.AttributeNames, params.MessageSystemAttributeNames...)
+
+	return s.fake.ReceiveMessage(ctx, &params.ReceiveMessageInput)
+}
+
+// jsonOutput converts an operation's output to the JSON response, leaving out unset fields and the metadata the
+// SDK attaches to every output.
+func jsonOutput(result any) (any, error) {
+	encoded, err := json.Marshal(result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	output := map[string]any{}
+
+	err = json.Unmarshal(encoded, &output)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delete(output, "ResultMetadata")
+
+	return withoutNulls(output), nil
+}
+
+func withoutNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if child == nil {
+				delete(v, key)
+				continue
+			}
+
+			v[key] = withoutNulls(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = withoutNulls(child)
+		}
+	}
+
+	return value
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeJSONError writes the error along with its query protocol code, which clients that used to speak the query
+// protocol still rely on.
+func writeJSONError(w http.ResponseWriter, err error) {
+	code, message, errorType := errorComponents(err)
+
+	w.Header().Set("x-amzn-query-error", fmt.Sprintf("%s;%s", queryErrorCode(code), errorType))
+
+	writeJSON(w, statusCode(err), map[string]string{
+		"__type":  "com.amazonaws.sqs#" + code,
+		"message": message,
+	})
+}
@@ -0,0 +1,600 @@
+package emulator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	snsNamespace = "http://sns.amazonaws.com/doc/2010-03-31/"
+	sqsNamespace = "http://queue.amazonaws.com/doc/2012-11-05/"
+	stsNamespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+)
+
+// A queryOperation handles an action in the query protocol. It returns the elements of the action's result.
+type queryOperation func(ctx context.Context, form queryForm) ([]element, error)
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request, action string, requestId string) {
+	namespace, operation := s.queryOperation(action)
+
+	if operation == nil {
+		writeQueryError(w, requestId, "", &smithy.GenericAPIError{
+			Code:    "InvalidAction",
+			Message: fmt.Sprintf("The action %s is not valid for this web service.", action),
+			Fault:   smithy.FaultClient,
+		})
+		return
+	}
+
+	form := queryForm{r.Form}
+
+	// Some clients send queue operations to the queue URL instead of providing it as a parameter
+	if namespace == sqsNamespace && form.Get("QueueUrl") == "" && r.URL.Path != "/" && r.URL.Path != "" {
+		form.Set("QueueUrl", s.fake.QueueBaseURL+r.URL.Path)
+	}
+
+	result, err := operation(r.Context(), form)
+
+	if err != nil {
+		if namespace == sqsNamespace {
+			code, _, _ := errorComponents(err)
+			err = &smithy.GenericAPIError{Code: queryErrorCode(code), Message: errorMessage(err), Fault: errorFault(err)}
+		}
+
+		writeQueryError(w, requestId, namespace, err)
+		return
+	}
+
+	response := el(action+"Response", el("ResponseMetadata", text("RequestId", requestId)))
+	response.Xmlns = namespace
+
+	if result != nil {
+		response.Children = append([]element{el(action+"Result", result...)}, response.Children...)
+	}
+
+	writeXML(w, http.StatusOK, response)
+}
+
+// queryOperation returns the namespace and handler for the action, or a nil handler if the action isn't supported.
+func (s *Server) queryOperation(action string) (string, queryOperation) {
+	snsOperations := map[string]queryOperation{
+		"CreateTopic":               s.createTopic,
+		"DeleteTopic":               s.deleteTopic,
+		"ListTopics":                s.listTopics,
+		"GetTopicAttributes":        s.getTopicAttributes,
+		"Subscribe":                 s.subscribe,
+		"ConfirmSubscription":       s.confirmSubscription,
+		"Unsubscribe":               s.unsubscribe,
+		"GetSubscriptionAttributes": s.getSubscriptionAttributes,
+		"Publish":                   s.publish,
+	}
+
+	sqsOperations := map[string]queryOperation{
+		"CreateQueue":             s.createQueue,
+		"DeleteQueue":             s.deleteQueue,
+		"GetQueueUrl":             s.getQueueUrl,
+		"ListQueues":              s.listQueues,
+		"PurgeQueue":              s.purgeQueue,
+		"GetQueueAttributes":      s.getQueueAttributes,
+		"SendMessage":             s.sendMessage,
+		"ReceiveMessage":          s.receiveMessage,
+		"DeleteMessage":           s.deleteMessage,
+		"ChangeMessageVisibility": s.changeMessageVisibility,
+	}
+
+	if operation, ok := snsOperations[action]; ok {
+		return snsNamespace, operation
+	}
+
+	if operation, ok := sqsOperations[action]; ok {
+		return sqsNamespace, operation
+	}
+
+	if action == "GetCallerIdentity" {
+		return stsNamespace, s.getCallerIdentity
+	}
+
+	return "", nil
+}
+
+func (s *Server) createTopic(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.CreateTopic(ctx, &sns.CreateTopicInput{
+		Name:       form.String("Name"),
+		Attributes: form.Map("Attributes", "key", "value"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{text("TopicArn", aws.ToString(result.TopicArn))}, nil
+}
+
+func (s *Server) deleteTopic(ctx context.Context, form queryForm) ([]element, error) {
+	_, err := s.fake.DeleteTopic(ctx, &sns.DeleteTopicInput{TopicArn: form.String("TopicArn")})
+
+	return nil, err
+}
+
+func (s *Server) listTopics(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.ListTopics(ctx, &sns.ListTopicsInput{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	topics := el("Topics")
+
+	for _, topic := range result.Topics {
+		topics.Children = append(topics.Children, el("member", text("TopicArn", aws.ToString(topic.TopicArn))))
+	}
+
+	return []element{topics}, nil
+}
+
+func (s *Server) getTopicAttributes(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: form.String("TopicArn")})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{entries("Attributes", result.Attributes)}, nil
+}
+
+func (s *Server) subscribe(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn:              form.String("TopicArn"),
+		Protocol:              form.String("Protocol"),
+		Endpoint:              form.String("Endpoint"),
+		Attributes:            form.Map("Attributes", "key", "value"),
+		ReturnSubscriptionArn: form.Get("ReturnSubscriptionArn") == "true",
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{text("SubscriptionArn", aws.ToString(result.SubscriptionArn))}, nil
+}
+
+func (s *Server) confirmSubscription(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.ConfirmSubscription(ctx, &sns.ConfirmSubscriptionInput{
+		TopicArn: form.String("TopicArn"),
+		Token:    form.String("Token"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{text("SubscriptionArn", aws.ToString(result.SubscriptionArn))}, nil
+}
+
+func (s *Server) unsubscribe(ctx context.Context, form queryForm) ([]element, error) {
+	_, err := s.fake.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: form.String("SubscriptionArn")})
+
+	return nil, err
+}
+
+func (s *Server) getSubscriptionAttributes(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{SubscriptionArn: form.String("SubscriptionArn")})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{entries("Attributes", result.Attributes)}, nil
+}
+
+func (s *Server) publish(ctx context.Context, form queryForm) ([]element, error) {
+	messageAttributes := map[string]snsTypes.MessageAttributeValue{}
+
+	for name, value := range form.MessageAttributes("MessageAttributes") {
+		messageAttributes[name] = snsTypes.MessageAttributeValue{
+			DataType:    value.DataType,
+			StringValue: value.StringValue,
+			BinaryValue: value.BinaryValue,
+		}
+	}
+
+	result, err := s.fake.Publish(ctx, &sns.PublishInput{
+		TopicArn:               form.String("TopicArn"),
+		Message:                form.String("Message"),
+		Subject:                form.String("Subject"),
+		MessageStructure:       form.String("MessageStructure"),
+		MessageAttributes:      messageAttributes,
+		MessageGroupId:         form.String("MessageGroupId"),
+		MessageDeduplicationId: form.String("MessageDeduplicationId"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	elements := []element{text("MessageId", aws.ToString(result.MessageId))}
+
+	if result.SequenceNumber != nil {
+		elements = append(elements, text("SequenceNumber", *result.SequenceNumber))
+	}
+
+	return elements, nil
+}
+
+func (s *Server) createQueue(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.CreateQueue(ctx, &sqs.CreateQueueInput{
+		QueueName:  form.String("QueueName"),
+		Attributes: form.Map("Attribute", "Name", "Value"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{text("QueueUrl", aws.ToString(result.QueueUrl))}, nil
+}
+
+func (s *Server) deleteQueue(ctx context.Context, form queryForm) ([]element, error) {
+	_, err := s.fake.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: form.String("QueueUrl")})
+
+	return nil, err
+}
+
+func (s *Server) getQueueUrl(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: form.String("QueueName")})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []element{text("QueueUrl", aws.ToString(result.QueueUrl))}, nil
+}
+
+func (s *Server) listQueues(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.ListQueues(ctx, &sqs.ListQueuesInput{QueueNamePrefix: form.String("QueueNamePrefix")})
+
+	if err != nil {
+		return nil, err
+	}
+
+	elements := []element{}
+
+	for _, queueUrl := range result.QueueUrls {
+		elements = append(elements, text("QueueUrl", queueUrl))
+	}
+
+	return elements, nil
+}
+
+func (s *Server) purgeQueue(ctx context.Context, form queryForm) ([]element, error) {
+	_, err := s.fake.PurgeQueue(ctx, &sqs.PurgeQueueInput{QueueUrl: form.String("QueueUrl")})
+
+	return nil, err
+}
+
+func (s *Server) getQueueAttributes(ctx context.Context, form queryForm) ([]element, error) {
+	attributeNames := []types.QueueAttributeName{}
+
+	for _, name := range form.List("AttributeName") {
+		attributeNames = append(attributeNames, types.QueueAttributeName(name))
+	}
+
+	result, err := s.fake.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       form.String("QueueUrl"),
+		AttributeNames: attributeNames,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return attributes("Attribute", result.Attributes), nil
+}
+
+func (s *Server) sendMessage(ctx context.Context, form queryForm) ([]element, error) {
+	result, err := s.fake.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:               form.String("QueueUrl"),
+		MessageBody:            form.String("MessageBody"),
+		DelaySeconds:           form.Int32("DelaySeconds"),
+		MessageAttributes:      form.MessageAttributes("MessageAttribute"),
+		MessageGroupId:         form.String("MessageGroupId"),
+		MessageDeduplicationId: form.String("MessageDeduplicationId"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	elements := []element{
+		text("MessageId", aws.ToString(result.MessageId)),
+		text("MD5OfMessageBody", aws.ToString(result.MD5OfMessageBody)),
+	}
+
+	if result.MD5OfMessageAttributes != nil {
+		elements = append(elements, text("MD5OfMessageAttributes", *result.MD5OfMessageAttributes))
+	}
+
+	if result.SequenceNumber != nil {
+		elements = append(elements, text("SequenceNumber", *result.SequenceNumber))
+	}
+
+	return elements, nil
+}
+
+func (s *Server) receiveMessage(ctx context.Context, form queryForm) ([]element, error) {
+	attributeNames := []types.QueueAttributeName{}
+
+	for _, name := range append(form.List("AttributeName"), form.List("MessageSystemAttributeName")...) {
+		attributeNames = append(attributeNames, types.QueueAttributeName(name))
+	}
+
+	result, err := s.fake.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              form.String("QueueUrl"),
+		AttributeNames:        attributeNames,
+		MessageAttributeNames: form.List("MessageAttributeName"),
+		MaxNumberOfMessages:   form.Int32("MaxNumberOfMessages"),
+		VisibilityTimeout:     form.Int32("VisibilityTimeout"),
+		WaitTimeSeconds:       form.Int32("WaitTimeSeconds"),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	elements := []element{}
+
+	for _, message := range result.Messages {
+		m := el(
+			"Message",
+			text("MessageId", aws.ToString(message.MessageId)),
+			text("ReceiptHandle", aws.ToString(message.ReceiptHandle)),
+			text("MD5OfBody", aws.ToString(message.MD5OfBody)),
+			text("Body", aws.ToString(message.Body)),
+		)
+
+		m.Children = append(m.Children, attributes("Attribute", message.Attributes)...)
+
+		if message.MD5OfMessageAttributes != nil {
+			m.Children = append(m.Children, text("MD5OfMessageAttributes", *message.MD5OfMessageAttributes))
+		}
+
+		for _, name := range sortedKeys(message.MessageAttributes) {
+			value := message.MessageAttributes[name]
+			v := el("Value", text("DataType", aws.ToString(value.DataType)))
+
+			if value.BinaryValue != nil {
+				v.Children = append(v.Children, text("BinaryValue", base64.StdEncoding.EncodeToString(value.BinaryValue)))
+			} else {
+				v.Children = append(v.Children, text("StringValue", aws.ToString(value.StringValue)))
+			}
+
+			m.Children = append(m.Children, el("MessageAttribute", text("Name", name), v))
+		}
+
+		elements = append(elements, m)
+	}
+
+	return elements, nil
+}
+
+func (s *Server) deleteMessage(ctx context.Context, form queryForm) ([]element, error) {
+	_, err := s.fake.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      form.String("QueueUrl"),
+		ReceiptHandle: form.String("ReceiptHandle"),
+	})
+
+	return nil, err
+}
+
+func (s *Server) changeMessageVisibility(ctx context.Context, form queryForm) ([]element, error) {
+	_, err := s.fake.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          form.String("QueueUrl"),
+		ReceiptHandle:     form.String("ReceiptHandle"),
+		VisibilityTimeout: form.Int32("VisibilityTimeout"),
+	})
+
+	return nil, err
+}
+
+// getCallerIdentity answers as the root user of the Fake's account, so that topic names can be resolved to ARNs.
+func (s *Server) getCallerIdentity(ctx context.Context, form queryForm) ([]element, error) {
+	return []element{
+		text("Arn", fmt.Sprintf("arn:aws:iam::%s:root", s.fake.AccountID)),
+		text("UserId", s.fake.AccountID),
+		text("Account", s.fake.AccountID),
+	}, nil
+}
+
+// queryForm reads the parameters of a query protocol request.
+type queryForm struct {
+	url.Values
+}
+
+func (f queryForm) String(key string) *string {
+	if !f.Has(key) {
+		return nil
+	}
+
+	return aws.String(f.Get(key))
+}
+
+func (f queryForm) Int32(key string) int32 {
+	value, _ := strconv.ParseInt(f.Get(key), 10, 32)
+
+	return int32(value)
+}
+
+// List reads a list that's either flattened, e.g. "AttributeName.1", or not, e.g. "AttributeName.member.1".
+func (f queryForm) List(prefix string) []string {
+	values := []string{}
+
+	for i := 1; ; i++ {
+		value, ok := f.first(fmt.Sprintf("%s.%d", prefix, i), fmt.Sprintf("%s.member.%d", prefix, i))
+
+		if !ok {
+			return values
+		}
+
+		values = append(values, value)
+	}
+}
+
+// Map reads a map that's either flattened, e.g. "Attribute.1.Name", or not, e.g. "Attributes.entry.1.key".
+func (f queryForm) Map(prefix string, keyName string, valueName string) map[string]string {
+	values := map[string]string{}
+
+	for _, entry := range f.entries(prefix, keyName) {
+		values[f.Get(entry+"."+keyName)] = f.Get(entry + "." + valueName)
+	}
+
+	return values
+}
+
+// MessageAttributes reads a map of message attributes, in the same shape for SNS and SQS.
+func (f queryForm) MessageAttributes(prefix string) map[string]types.MessageAttributeValue {
+	values := map[string]types.MessageAttributeValue{}
+
+	for _, entry := range f.entries(prefix, "Name") {
+		value := types.MessageAttributeValue{
+			DataType:    f.String(entry + ".Value.DataType"),
+			StringValue: f.String(entry + ".Value.StringValue"),
+		}
+
+		if f.Has(entry + ".Value.BinaryValue") {
+			value.BinaryValue, _ = base64.StdEncoding.DecodeString(f.Get(entry + ".Value.BinaryValue"))
+		}
+
+		values[f.Get(entry+".Name")] = value
+	}
+
+	return values
+}
+
+// entries returns the prefix of every entry in a map.
+func (f queryForm) entries(prefix string, keyName string) []string {
+	entries := []string{}
+
+	for i := 1; ; i++ {
+		flat := fmt.Sprintf("%s.%d", prefix, i)
+		nested := fmt.Sprintf("%s.entry.%d", prefix, i)
+
+		switch {
+		case f.Has(flat + "." + keyName):
+			entries = append(entries, flat)
+		case f.Has(nested + "." + keyName):
+			entries = append(entries, nested)
+		default:
+			return entries
+		}
+	}
+}
+
+func (f queryForm) first(keys ...string) (string, bool) {
+	for _, key := range keys {
+		if f.Has(key) {
+			return f.Get(key), true
+		}
+	}
+
+	return "", false
+}
+
+// An element is an XML element with either text or child elements.
+type element struct {
+	XMLName  xml.Name
+	Xmlns    string `xml:"xmlns,attr,omitempty"`
+	Text     string `xml:",chardata"`
+	Children []element
+}
+
+func el(name string, children ...element) element {
+	return element{XMLName: xml.Name{Local: name}, Children: children}
+}
+
+func text(name string, value string) element {
+	return element{XMLName: xml.Name{Local: name}, Text: value}
+}
+
+// entries returns a map in the non-flattened form SNS uses, e.g. Attributes/entry/key.
+func entries(name string, values map[string]string) element {
+	e := el(name)
+
+	for _, key := range sortedKeys(values) {
+		e.Children = append(e.Children, el("entry", text("key", key), text("value", values[key])))
+	}
+
+	return e
+}
+
+// attributes returns a map in the flattened form SQS uses, e.g. Attribute/Name.
+func attributes(name string, values map[string]string) []element {
+	elements := []element{}
+
+	for _, key := range sortedKeys(values) {
+		elements = append(elements, el(name, text("Name", key), text("Value", values[key])))
+	}
+
+	return elements
+}
+
+func writeXML(w http.ResponseWriter, status int, e element) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(e)
+}
+
+func writeQueryError(w http.ResponseWriter, requestId string, namespace string, err error) {
+	code, message, errorType := errorComponents(err)
+
+	response := el(
+		"ErrorResponse",
+		el("Error", text("Type", errorType), text("Code", code), text("Message", message)),
+		text("RequestId", requestId),
+	)
+	response.Xmlns = namespace
+
+	writeXML(w, statusCode(err), response)
+}
+
+func errorMessage(err error) string {
+	_, message, _ := errorComponents(err)
+
+	return message
+}
+
+func errorFault(err error) smithy.ErrorFault {
+	_, _, errorType := errorComponents(err)
+
+	if errorType == "Receiver" {
+		return smithy.FaultServer
+	}
+
+	return smithy.FaultClient
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
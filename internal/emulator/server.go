@@ -0,0 +1,112 @@
+// Package emulator serves an in-memory SNS and SQS over HTTP so that SDK clients can be pointed at it instead of AWS.
+package emulator
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/listenertest"
+)
+
+// A Server handles SNS, SQS and STS requests in the AWS query protocol, and SQS requests in the AWS JSON protocol,
+// using a listenertest.Fake. Requests aren't authenticated so any credentials can be used to sign them.
+// It should not be instantiated directly, instead the New() function should be used.
+type Server struct {
+	fake   *listenertest.Fake
+	logger *log.Logger
+}
+
+// New returns a Server backed by the provided Fake. Every request is logged to the provided logger.
+func New(fake *listenertest.Fake, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	return &Server{fake, logger}
+}
+
+// ServeHTTP handles a single request. Requests with an X-Amz-Target header use the JSON protocol and every other
+// request uses the query protocol with the operation in the Action parameter.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestId := uuid.NewString()
+	w.Header().Set("x-amzn-RequestId", requestId)
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		s.logger.Printf("%s %s", r.Method, target)
+		s.serveJSON(w, r, target, requestId)
+		return
+	}
+
+	err := r.ParseForm()
+
+	if err != nil {
+		writeQueryError(w, requestId, "", &smithy.GenericAPIError{Code: "MalformedQueryString", Message: err.Error(), Fault: smithy.FaultClient})
+		return
+	}
+
+	action := r.Form.Get("Action")
+	s.logger.Printf("%s %s", r.Method, action)
+
+	s.serveQuery(w, r, action, requestId)
+}
+
+// statusCode returns the HTTP status code AWS responds with for the error.
+func statusCode(err error) int {
+	var apiErr smithy.APIError
+
+	if !errors.As(err, &apiErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch {
+	case apiErr.ErrorCode() == "NotFound":
+		return http.StatusNotFound
+	case apiErr.ErrorCode() == "AuthorizationError" || apiErr.ErrorCode() == "AccessDenied":
+		return http.StatusForbidden
+	case apiErr.ErrorFault() == smithy.FaultServer:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// errorComponents returns the code, message and type of the error. Errors that aren't from the API are treated
+// as internal errors.
+func errorComponents(err error) (string, string, string) {
+	var apiErr smithy.APIError
+
+	if !errors.As(err, &apiErr) {
+		return "InternalFailure", err.Error(), "Receiver"
+	}
+
+	if apiErr.ErrorFault() == smithy.FaultServer {
+		return apiErr.ErrorCode(), apiErr.ErrorMessage(), "Receiver"
+	}
+
+	return apiErr.ErrorCode(), apiErr.ErrorMessage(), "Sender"
+}
+
+// sqsQueryErrorCodes are the codes that SQS uses for some errors in the query protocol, which differ from the
+// names of the errors.
+var sqsQueryErrorCodes = map[string]string{
+	"QueueDoesNotExist":  "AWS.SimpleQueueService.NonExistentQueue",
+	"QueueNameExists":    "QueueAlreadyExists",
+	"MessageNotInflight": "AWS.SimpleQueueService.MessageNotInflight",
+}
+
+func queryErrorCode(code string) string {
+	if queryCode, ok := sqsQueryErrorCodes[code]; ok {
+		return queryCode
+	}
+
+	return code
+}
+
+func isNamespaced(target string, service string) bool {
+	return strings.HasPrefix(target, service+".")
+}
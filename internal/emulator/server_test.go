@@ -0,0 +1,352 @@
+package emulator_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/whatsfordinner/aws-sns-listener/internal/emulator"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/listenertest"
+)
+
+// newServer starts an emulator backed by a new Fake and returns the Fake along with SDK configuration pointed at it.
+func newServer(t *testing.T) (*listenertest.Fake, aws.Config) {
+	server := httptest.NewUnstartedServer(nil)
+	baseUrl := "http://" + server.Listener.Addr().String()
+
+	fake := listenertest.New(listenertest.WithQueueBaseURL(baseUrl))
+	server.Config.Handler = emulator.New(fake, nil)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("test", "test", ""),
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service string, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: baseUrl, SigningRegion: region, HostnameImmutable: true}, nil
+		}),
+	}
+
+	return fake, cfg
+}
+
+// collector is a Handler that records the messages it's given.
+type collector struct {
+	mu       sync.Mutex
+	messages []string
+	done     chan struct{}
+	expected int
+}
+
+func (c *collector) HandleMessage(ctx context.Context, msg listener.MessageContent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, aws.ToString(msg.Message))
+
+	if len(c.messages) == c.expected {
+		close(c.done)
+	}
+
+	return nil
+}
+
+func TestListener(t *testing.T) {
+	tests := map[string]struct {
+		topicName      string
+		topicAttribute map[string]string
+		options        []listener.Option
+		published      []string
+		expected       []string
+	}{
+		"standard topic": {
+			"valid-topic",
+			map[string]string{},
+			[]listener.Option{},
+			[]string{"first", "second"},
+			[]string{"first", "second"},
+		},
+		"filter policy with long polling": {
+			"valid-topic",
+			map[string]string{},
+			[]listener.Option{listener.WithFilterPolicy(`{"colour": ["blue"]}`, filter.ScopeMessageAttributes), listener.WithWaitTime(time.Second)},
+			[]string{"red", "blue"},
+			[]string{"blue"},
+		},
+		"raw message delivery": {
+			"valid-topic",
+			map[string]string{},
+			[]listener.Option{listener.WithRawMessageDelivery(true)},
+			[]string{"first"},
+			[]string{"first"},
+		},
+		"fifo topic": {
+			"valid-topic.fifo",
+			map[string]string{"FifoTopic": "true", "ContentBasedDeduplication": "true"},
+			[]listener.Option{listener.WithBatchSize(10)},
+			[]string{"first", "second", "third"},
+			[]string{"first", "second", "third"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake, cfg := newServer(t)
+			ctx := context.TODO()
+			snsClient := sns.NewFromConfig(cfg)
+
+			topic, err := snsClient.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String(test.topicName), Attributes: test.topicAttribute})
+
+			if err != nil {
+				t.Fatalf("Expected no error creating topic but got %s", err.Error())
+			}
+
+			l := listener.New(*topic.TopicArn, snsClient, sqs.NewFromConfig(cfg), append(test.options, listener.WithPollingInterval(10*time.Millisecond))...)
+
+			err = l.Setup(ctx)
+
+			if err != nil {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			for _, message := range test.published {
+				params := &sns.PublishInput{
+					TopicArn: topic.TopicArn,
+					Message:  aws.String(message),
+					MessageAttributes: map[string]snsTypes.MessageAttributeValue{
+						"colour": {DataType: aws.String("String"), StringValue: aws.String(message)},
+					},
+				}
+
+				if test.topicAttribute["FifoTopic"] == "true" {
+					params.MessageGroupId = aws.String("group")
+				}
+
+				_, err := snsClient.Publish(ctx, params)
+
+				if err != nil {
+					t.Fatalf("Expected no error publishing but got %s", err.Error())
+				}
+			}
+
+			c := &collector{done: make(chan struct{}), expected: len(test.expected)}
+			listenCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+
+			go func() {
+				<-c.done
+				cancel()
+			}()
+
+			_ = l.ListenWithHandler(listenCtx, c)
+			cancel()
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if len(c.messages) != len(test.expected) {
+				t.Fatalf("Received messages %v did not match expected values %v", c.messages, test.expected)
+			}
+
+			for i := range c.messages {
+				if c.messages[i] != test.expected[i] {
+					t.Fatalf("Received messages %v did not match expected values %v", c.messages, test.expected)
+				}
+			}
+
+			err = l.Teardown(ctx)
+
+			if err != nil {
+				t.Fatalf("Expected no error tearing down but got %s", err.Error())
+			}
+
+			if len(fake.Queues()) != 0 || len(fake.Subscriptions()) != 0 {
+				t.Fatalf("Expected Teardown to remove everything but found queues %v and subscriptions %v", fake.Queues(), fake.Subscriptions())
+			}
+		})
+	}
+}
+
+func TestQueue(t *testing.T) {
+	_, cfg := newServer(t)
+	ctx := context.TODO()
+	client := sqs.NewFromConfig(cfg)
+
+	created, err := client.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String("valid-queue")})
+
+	if err != nil {
+		t.Fatalf("Expected no error creating queue but got %s", err.Error())
+	}
+
+	sent, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    created.QueueUrl,
+		MessageBody: aws.String("hello"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"text":   {DataType: aws.String("String"), StringValue: aws.String("value")},
+			"binary": {DataType: aws.String("Binary"), BinaryValue: []byte{0, 1, 2}},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error sending message but got %s", err.Error())
+	}
+
+	// The SDK checks the MD5 digests of the body and attributes in both the send and receive responses
+	received, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              created.QueueUrl,
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{"All"},
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error receiving message but got %s", err.Error())
+	}
+
+	if len(received.Messages) != 1 {
+		t.Fatalf("Expected 1 message but received %d", len(received.Messages))
+	}
+
+	message := received.Messages[0]
+
+	if *message.MessageId != *sent.MessageId || *message.Body != "hello" || message.Attributes["ApproximateReceiveCount"] != "1" {
+		t.Fatalf("Received message %+v did not match the message that was sent", message)
+	}
+
+	if string(message.MessageAttributes["binary"].BinaryValue) != string([]byte{0, 1, 2}) {
+		t.Fatalf("Expected binary attribute to be preserved but got %v", message.MessageAttributes["binary"].BinaryValue)
+	}
+
+	_, err = client.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: created.QueueUrl, ReceiptHandle: message.ReceiptHandle})
+
+	if err != nil {
+		t.Fatalf("Expected no error deleting message but got %s", err.Error())
+	}
+
+	_, err = client.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: created.QueueUrl})
+
+	if err != nil {
+		t.Fatalf("Expected no error deleting queue but got %s", err.Error())
+	}
+
+	var notFound *types.QueueDoesNotExist
+
+	_, err = client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String("valid-queue")})
+
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected QueueDoesNotExist but got %v", err)
+	}
+}
+
+func TestTopicErrors(t *testing.T) {
+	_, cfg := newServer(t)
+	client := sns.NewFromConfig(cfg)
+
+	var notFound *snsTypes.NotFoundException
+
+	_, err := client.GetTopicAttributes(context.TODO(), &sns.GetTopicAttributesInput{TopicArn: aws.String("arn:aws:sns:us-east-1:123456789012:missing-topic")})
+
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected NotFoundException but got %v", err)
+	}
+}
+
+func TestGetCallerIdentity(t *testing.T) {
+	_, cfg := newServer(t)
+
+	result, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err.Error())
+	}
+
+	if *result.Account != "123456789012" {
+		t.Fatalf("Expected account 123456789012 but got %s", *result.Account)
+	}
+}
+
+func TestJSONProtocol(t *testing.T) {
+	tests := map[string]struct {
+		target         string
+		body           string
+		expectedStatus int
+		expectedField  string
+	}{
+		"create queue": {
+			"AmazonSQS.CreateQueue",
+			`{"QueueName": "valid-queue"}`,
+			http.StatusOK,
+			"QueueUrl",
+		},
+		"missing queue": {
+			"AmazonSQS.GetQueueUrl",
+			`{"QueueName": "missing-queue"}`,
+			http.StatusBadRequest,
+			"__type",
+		},
+		"unknown operation": {
+			"AmazonSQS.TagQueue",
+			`{}`,
+			http.StatusBadRequest,
+			"__type",
+		},
+		"malformed body": {
+			"AmazonSQS.CreateQueue",
+			`{"QueueName": 1}`,
+			http.StatusBadRequest,
+			"__type",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := listenertest.New()
+			server := httptest.NewServer(emulator.New(fake, nil))
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+			req.Header.Set("X-Amz-Target", test.target)
+
+			resp, err := http.DefaultClient.Do(req)
+
+			if err != nil {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			defer resp.Body.Close()
+
+			body := map[string]any{}
+			err = json.NewDecoder(resp.Body).Decode(&body)
+
+			if err != nil {
+				t.Fatalf("Expected no error decoding response but got %s", err.Error())
+			}
+
+			if resp.StatusCode != test.expectedStatus {
+				t.Fatalf("Expected status %d but got %d with body %v", test.expectedStatus, resp.StatusCode, body)
+			}
+
+			if _, ok := body[test.expectedField]; !ok {
+				t.Fatalf("Expected field %s in response but got %v", test.expectedField, body)
+			}
+
+			if _, ok := body["ResultMetadata"]; ok {
+				t.Fatalf("Expected no ResultMetadata in response but got %v", body)
+			}
+		})
+	}
+}
@@ -23,6 +23,30 @@ func (c SSMAPIImpl) GetParameter(ctx context.Context,
 			},
 		}, nil
 	}
+	if *params.Name == "/valid/topic/path" {
+		return &ssm.GetParameterOutput{
+			Parameter: &types.Parameter{
+				Name:  aws.String("/valid/topic/path"),
+				Value: aws.String("arn:aws:sns:us-east-1:123456789012:some-topic"),
+			},
+		}, nil
+	}
+	if *params.Name == "/valid/json/path" {
+		return &ssm.GetParameterOutput{
+			Parameter: &types.Parameter{
+				Name:  aws.String("/valid/json/path"),
+				Value: aws.String(`{"topics": {"orders": "arn:aws:sns:us-east-1:123456789012:orders", "count": 1}}`),
+			},
+		}, nil
+	}
+	if *params.Name == "/not/a/topic/path" {
+		return &ssm.GetParameterOutput{
+			Parameter: &types.Parameter{
+				Name:  aws.String("/not/a/topic/path"),
+				Value: aws.String("some-value"),
+			},
+		}, nil
+	}
 	return nil, errors.New("Couldn't find param")
 }
 
@@ -0,0 +1,157 @@
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Schemes that a reference can use to say where its value is stored.
+const (
+	SchemeSSM            string = "ssm"
+	SchemeSecretsManager string = "secretsmanager"
+	SchemeCloudFormation string = "cfn"
+	SchemeExport         string = "cfn-export"
+)
+
+// Reference points to a value stored in another AWS service.
+// References are written as "scheme:name#key", e.g. "ssm:/path/to/param" or "secretsmanager:my-secret#topics.orders".
+// A reference without a scheme is treated as an SSM parameter path.
+type Reference struct {
+	Scheme string
+	// Name is the parameter path, secret name, "stack/Output" or export name depending on the scheme.
+	Name string
+	// Key is an optional dot separated path to a string inside a JSON value.
+	Key string
+}
+
+// ParseReference parses a reference in the form "scheme:name#key".
+func ParseReference(reference string) (Reference, error) {
+	ref := Reference{Scheme: SchemeSSM}
+	name := reference
+
+	if scheme, rest, ok := strings.Cut(reference, ":"); ok && !strings.HasPrefix(reference, "/") {
+		ref.Scheme = scheme
+		name = rest
+	}
+
+	ref.Name, ref.Key, _ = strings.Cut(name, "#")
+
+	switch ref.Scheme {
+	case SchemeSSM, SchemeSecretsManager, SchemeExport:
+	case SchemeCloudFormation:
+		stack, output, _ := strings.Cut(ref.Name, "/")
+
+		if stack == "" || output == "" {
+			return Reference{}, fmt.Errorf("reference %s must be in the form cfn:stack/Output", reference)
+		}
+	default:
+		return Reference{}, fmt.Errorf("reference %s has unknown scheme %s", reference, ref.Scheme)
+	}
+
+	if ref.Name == "" {
+		return Reference{}, fmt.Errorf("reference %s has no name", reference)
+	}
+
+	return ref, nil
+}
+
+// Clients are the AWS clients used to resolve references.
+type Clients struct {
+	SSM SSMAPI
+}
+
+// Resolve parses the provided reference and fetches the topic ARN it points to with the matching client from clients.
+// If the reference has a key then the value is parsed as JSON and the string at that key is used instead.
+// It is an error for the value to not be an SNS topic ARN.
+// Only SSM parameters can currently be resolved, other schemes are parsed but return an error.
+func Resolve(ctx context.Context, clients Clients, reference string) (string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "resolve")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".reference", reference))
+
+	ref, err := ParseReference(reference)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	var value string
+
+	switch ref.Scheme {
+	case SchemeSSM:
+		value, err = GetParameter(ctx, clients.SSM, ref.Name)
+	default:
+		err = fmt.Errorf("resolving %s references is not supported yet", ref.Scheme)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	if ref.Key != "" {
+		value, err = getJSONKey(value, ref.Key)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return "", err
+		}
+	}
+
+	_, err = listener.ParseTopicArn(value)
+
+	if err != nil {
+		err = fmt.Errorf("reference %s: %w", reference, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	span.SetStatus(codes.Ok, "")
+
+	return value, nil
+}
+
+func getJSONKey(value string, key string) (string, error) {
+	var document any
+
+	err := json.Unmarshal([]byte(value), &document)
+
+	if err != nil {
+		return "", fmt.Errorf("value is not valid JSON: %w", err)
+	}
+
+	for _, segment := range strings.Split(key, ".") {
+		object, ok := document.(map[string]any)
+
+		if !ok {
+			return "", fmt.Errorf("key %s is not inside a JSON object", key)
+		}
+
+		document, ok = object[segment]
+
+		if !ok {
+			return "", fmt.Errorf("key %s does not exist", key)
+		}
+	}
+
+	result, ok := document.(string)
+
+	if !ok {
+		return "", fmt.Errorf("key %s is not a string", key)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package resolve
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr     bool
+		reference     string
+		expectedValue Reference
+	}{
+		"bare parameter path":        {false, "/valid/param/path", Reference{SchemeSSM, "/valid/param/path", ""}},
+		"ssm parameter":              {false, "ssm:/valid/param/path", Reference{SchemeSSM, "/valid/param/path", ""}},
+		"secret with key":            {false, "secretsmanager:my-secret#topics.orders", Reference{SchemeSecretsManager, "my-secret", "topics.orders"}},
+		"stack output":               {false, "cfn:my-stack/TopicArn", Reference{SchemeCloudFormation, "my-stack/TopicArn", ""}},
+		"export":                     {false, "cfn-export:my-stack-TopicArn", Reference{SchemeExport, "my-stack-TopicArn", ""}},
+		"stack without output":       {true, "cfn:my-stack", Reference{}},
+		"unknown scheme":             {true, "s3:my-bucket/key", Reference{}},
+		"scheme without a name":      {true, "ssm:", Reference{}},
+		"key without a name":         {true, "secretsmanager:#key", Reference{}},
+		"stack output with no stack": {true, "cfn:/TopicArn", Reference{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ref, err := ParseReference(test.reference)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if test.expectedValue != ref {
+					t.Fatalf("Reference %+v did not match expected value %+v",
+						ref,
+						test.expectedValue,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr     bool
+		reference     string
+		expectedValue string
+	}{
+		"bare parameter path":      {false, "/valid/topic/path", "arn:aws:sns:us-east-1:123456789012:some-topic"},
+		"ssm parameter":            {false, "ssm:/valid/topic/path", "arn:aws:sns:us-east-1:123456789012:some-topic"},
+		"ssm parameter with key":   {false, "ssm:/valid/json/path#topics.orders", "arn:aws:sns:us-east-1:123456789012:orders"},
+		"key is not a string":      {true, "ssm:/valid/json/path#topics.count", ""},
+		"key does not exist":       {true, "ssm:/valid/json/path#topics.payments", ""},
+		"key inside a string":      {true, "ssm:/valid/json/path#topics.orders.arn", ""},
+		"value is not JSON":        {true, "ssm:/valid/param/path#topics", ""},
+		"value is not a topic ARN": {true, "ssm:/not/a/topic/path", ""},
+		"parameter does not exist": {true, "ssm:/invalid/param/path", ""},
+		"unsupported scheme":       {true, "cfn-export:my-stack-TopicArn", ""},
+		"invalid reference":        {true, "s3:my-bucket/key", ""},
+	}
+
+	clients := Clients{SSM: &SSMAPIImpl{}}
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			value, err := Resolve(ctx, clients, test.reference)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if test.expectedValue != value {
+					t.Fatalf("Value %s did not match expected value %s",
+						value,
+						test.expectedValue,
+					)
+				}
+			}
+		})
+	}
+}
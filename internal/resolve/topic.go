@@ -0,0 +1,227 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// STSAPI is a shim over v2 of the AWS SDK's sts client. The sts client provided by
+// github.com/aws/aws-sdk-go-v2/service/sts automatically satisfies this.
+type STSAPI interface {
+	GetCallerIdentity(ctx context.Context,
+		params *sts.GetCallerIdentityInput,
+		optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// SNSAPI is a shim over v2 of the AWS SDK's sns client. The sns client provided by
+// github.com/aws/aws-sdk-go-v2/service/sns automatically satisfies this.
+type SNSAPI interface {
+	ListTopics(ctx context.Context,
+		params *sns.ListTopicsInput,
+		optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error)
+	ListTagsForResource(ctx context.Context,
+		params *sns.ListTagsForResourceInput,
+		optFns ...func(*sns.Options)) (*sns.ListTagsForResourceOutput, error)
+}
+
+// GetTopicArn uses the provided Security Token Service client to build the ARN of the topic with the provided name
+// in the caller's account and the provided region. The ARN is validated but the topic isn't checked for existence.
+func GetTopicArn(ctx context.Context, client STSAPI, region string, topicName string) (string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "getTopicArn")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String(traceNamespace+".topicName", topicName),
+		attribute.String(traceNamespace+".region", region),
+	)
+
+	if region == "" {
+		err := errors.New("a region is required to resolve a topic by name")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	log.Printf("Fetching caller identity to resolve topic %s...", topicName)
+
+	result, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	partition := "aws"
+	callerArn := strings.SplitN(aws.ToString(result.Arn), ":", 3)
+
+	if len(callerArn) == 3 && callerArn[0] == "arn" {
+		partition = callerArn[1]
+	}
+
+	topicArn, err := listener.ParseTopicArn(fmt.Sprintf(
+		"arn:%s:sns:%s:%s:%s",
+		partition,
+		region,
+		aws.ToString(result.Account),
+		topicName,
+	))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	log.Printf("Resolved topic %s to ARN: %s", topicName, topicArn)
+
+	span.SetAttributes(attribute.String(traceNamespace+".topicArn", topicArn.String()))
+	span.SetStatus(codes.Ok, "")
+
+	return topicArn.String(), nil
+}
+
+// FindTopicsByName uses the provided SNS client to find every topic whose name matches the provided pattern.
+// The pattern uses the syntax of path.Match so a prefix can be matched with e.g. "orders-*".
+// It is an error for no topics to match.
+func FindTopicsByName(ctx context.Context, client SNSAPI, pattern string) ([]string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "findTopicsByName")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".topicPattern", pattern))
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	log.Printf("Finding topics with names matching %s...", pattern)
+
+	topicArns, err := listTopics(ctx, client)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	matches := []string{}
+
+	for _, topicArn := range topicArns {
+		// the pattern is known to be valid so Match can't error
+		if ok, _ := path.Match(pattern, topicName(topicArn)); ok {
+			matches = append(matches, topicArn)
+		}
+	}
+
+	if len(matches) == 0 {
+		err := fmt.Errorf("no topics have names matching %s", pattern)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	log.Printf("Found %d topics matching %s", len(matches), pattern)
+
+	span.SetAttributes(attribute.StringSlice(traceNamespace+".topicArns", matches))
+	span.SetStatus(codes.Ok, "")
+
+	return matches, nil
+}
+
+// FindTopicsByTag uses the provided SNS client to find every topic with the provided tag.
+// If the value is empty then any topic with the tag key matches regardless of its value.
+// It is an error for no topics to match.
+func FindTopicsByTag(ctx context.Context, client SNSAPI, key string, value string) ([]string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "findTopicsByTag")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String(traceNamespace+".tagKey", key),
+		attribute.String(traceNamespace+".tagValue", value),
+	)
+
+	log.Printf("Finding topics tagged with %s=%s...", key, value)
+
+	topicArns, err := listTopics(ctx, client)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	matches := []string{}
+
+	for _, topicArn := range topicArns {
+		result, err := client.ListTagsForResource(
+			ctx,
+			&sns.ListTagsForResourceInput{
+				ResourceArn: aws.String(topicArn),
+			},
+		)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+
+		for _, tag := range result.Tags {
+			if aws.ToString(tag.Key) == key && (value == "" || aws.ToString(tag.Value) == value) {
+				matches = append(matches, topicArn)
+				break
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		err := fmt.Errorf("no topics are tagged with %s=%s", key, value)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	log.Printf("Found %d topics tagged with %s=%s", len(matches), key, value)
+
+	span.SetAttributes(attribute.StringSlice(traceNamespace+".topicArns", matches))
+	span.SetStatus(codes.Ok, "")
+
+	return matches, nil
+}
+
+func listTopics(ctx context.Context, client SNSAPI) ([]string, error) {
+	topicArns := []string{}
+	paginator := sns.NewListTopicsPaginator(client, &sns.ListTopicsInput{})
+
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, topic := range result.Topics {
+			topicArns = append(topicArns, aws.ToString(topic.TopicArn))
+		}
+	}
+
+	return topicArns, nil
+}
+
+func topicName(topicArn string) string {
+	return topicArn[strings.LastIndex(topicArn, ":")+1:]
+}
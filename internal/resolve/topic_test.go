@@ -0,0 +1,275 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type STSAPIImpl struct {
+	callerArn string
+}
+
+func (c STSAPIImpl) GetCallerIdentity(ctx context.Context,
+	params *sts.GetCallerIdentityInput,
+	optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if c.callerArn == "" {
+		return nil, errors.New("No credentials")
+	}
+
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+		Arn:     aws.String(c.callerArn),
+	}, nil
+}
+
+type SNSAPIImpl struct {
+	breaksOnTags bool
+}
+
+var topicPages = map[string]struct {
+	topicArns []string
+	nextToken *string
+}{
+	"": {
+		[]string{
+			"arn:aws:sns:us-east-1:123456789012:orders-created",
+			"arn:aws:sns:us-east-1:123456789012:orders-shipped",
+		},
+		aws.String("page-2"),
+	},
+	"page-2": {
+		[]string{
+			"arn:aws:sns:us-east-1:123456789012:payments",
+			"arn:aws:sns:us-east-1:123456789012:orders-cancelled.fifo",
+		},
+		nil,
+	},
+}
+
+var topicTags = map[string][]snsTypes.Tag{
+	"arn:aws:sns:us-east-1:123456789012:orders-created": {
+		{Key: aws.String("team"), Value: aws.String("orders")},
+	},
+	"arn:aws:sns:us-east-1:123456789012:orders-shipped": {
+		{Key: aws.String("team"), Value: aws.String("shipping")},
+	},
+	"arn:aws:sns:us-east-1:123456789012:payments": {
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("team"), Value: aws.String("orders")},
+	},
+}
+
+func (c SNSAPIImpl) ListTopics(ctx context.Context,
+	params *sns.ListTopicsInput,
+	optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error) {
+	page, ok := topicPages[aws.ToString(params.NextToken)]
+
+	if !ok {
+		return nil, errors.New("Invalid token")
+	}
+
+	topics := []snsTypes.Topic{}
+
+	for _, topicArn := range page.topicArns {
+		topics = append(topics, snsTypes.Topic{TopicArn: aws.String(topicArn)})
+	}
+
+	return &sns.ListTopicsOutput{
+		Topics:    topics,
+		NextToken: page.nextToken,
+	}, nil
+}
+
+func (c SNSAPIImpl) ListTagsForResource(ctx context.Context,
+	params *sns.ListTagsForResourceInput,
+	optFns ...func(*sns.Options)) (*sns.ListTagsForResourceOutput, error) {
+	if c.breaksOnTags {
+		return nil, errors.New("Access denied")
+	}
+
+	return &sns.ListTagsForResourceOutput{
+		Tags: topicTags[aws.ToString(params.ResourceArn)],
+	}, nil
+}
+
+func TestGetTopicArn(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr     bool
+		callerArn     string
+		region        string
+		topicName     string
+		expectedValue string
+	}{
+		"topic in aws partition": {
+			false,
+			"arn:aws:iam::123456789012:user/someone",
+			"us-east-1",
+			"orders-created",
+			"arn:aws:sns:us-east-1:123456789012:orders-created",
+		},
+		"topic in china partition": {
+			false,
+			"arn:aws-cn:sts::123456789012:assumed-role/some-role/session",
+			"cn-north-1",
+			"orders-created",
+			"arn:aws-cn:sns:cn-north-1:123456789012:orders-created",
+		},
+		"invalid topic name": {true, "arn:aws:iam::123456789012:user/someone", "us-east-1", "orders created", ""},
+		"no region":          {true, "arn:aws:iam::123456789012:user/someone", "", "orders-created", ""},
+		"no credentials":     {true, "", "us-east-1", "orders-created", ""},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &STSAPIImpl{test.callerArn}
+			topicArn, err := GetTopicArn(ctx, client, test.region, test.topicName)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if test.expectedValue != topicArn {
+					t.Fatalf("Topic ARN %s did not match expected value %s",
+						topicArn,
+						test.expectedValue,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestFindTopicsByName(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr      bool
+		pattern        string
+		expectedValues []string
+	}{
+		"exact name": {
+			false,
+			"payments",
+			[]string{"arn:aws:sns:us-east-1:123456789012:payments"},
+		},
+		"prefix across pages": {
+			false,
+			"orders-*",
+			[]string{
+				"arn:aws:sns:us-east-1:123456789012:orders-created",
+				"arn:aws:sns:us-east-1:123456789012:orders-shipped",
+				"arn:aws:sns:us-east-1:123456789012:orders-cancelled.fifo",
+			},
+		},
+		"glob": {
+			false,
+			"*.fifo",
+			[]string{"arn:aws:sns:us-east-1:123456789012:orders-cancelled.fifo"},
+		},
+		"no matches":      {true, "refunds-*", []string{}},
+		"invalid pattern": {true, "orders-[", []string{}},
+	}
+
+	client := &SNSAPIImpl{}
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			topicArns, err := FindTopicsByName(ctx, client, test.pattern)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if !reflect.DeepEqual(test.expectedValues, topicArns) {
+					t.Fatalf("Topic ARNs %v did not match expected values %v",
+						topicArns,
+						test.expectedValues,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestFindTopicsByTag(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr      bool
+		breaksOnTags   bool
+		key            string
+		value          string
+		expectedValues []string
+	}{
+		"key and value": {
+			false,
+			false,
+			"team",
+			"orders",
+			[]string{
+				"arn:aws:sns:us-east-1:123456789012:orders-created",
+				"arn:aws:sns:us-east-1:123456789012:payments",
+			},
+		},
+		"key only": {
+			false,
+			false,
+			"env",
+			"",
+			[]string{"arn:aws:sns:us-east-1:123456789012:payments"},
+		},
+		"no matches":        {true, false, "team", "refunds", []string{}},
+		"tags inaccessible": {true, true, "team", "orders", []string{}},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &SNSAPIImpl{test.breaksOnTags}
+			topicArns, err := FindTopicsByTag(ctx, client, test.key, test.value)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if !reflect.DeepEqual(test.expectedValues, topicArns) {
+					t.Fatalf("Topic ARNs %v did not match expected values %v",
+						topicArns,
+						test.expectedValues,
+					)
+				}
+			}
+		})
+	}
+}
@@ -1,43 +1,209 @@
 /*
 AWS-SNS-Listener listens to SNS topics.
-It prints the body of any messages published to an SNS topic to stdout.
-It accomplishes this by creating an SQS queue, subscribing it to the topic and then periodically receiving messages.
+It prints the body of any messages published to one or more SNS topics to stdout.
+It accomplishes this by creating an SQS queue, subscribing it to the topics and then periodically receiving messages.
 
 Usage:
 
 	aws-sns-listener [flags]
+	aws-sns-listener serve [flags]
+	aws-sns-listener replay [flags] path/to/recording.ndjson
+	aws-sns-listener publish [flags] [message | @path/to/message.txt | -]
 
 The flags are:
 
 	-t
-		The ARN of the SNS topic to subscribe to.
-		Mutually exclusive with -p
+		The ARN of an SNS topic to subscribe to.
+		Can be repeated to listen to several topics with a single queue.
+		A path to a file with one topic ARN per line can be provided prefixed with "@", e.g. "@topics.txt".
 	-p
-		The Systems Manager Parameter Store parameter to use to resolve the topic ARN.
-		Mutually exclusive with -t
+		A reference to where a topic ARN is stored, in the form "scheme:name#key".
+		The "ssm" scheme reads a Systems Manager Parameter Store parameter, e.g. "ssm:/path/to/param".
+		A reference without a scheme is treated as a parameter path.
+		The optional key is a dot separated path to a string when the value is JSON, e.g. "ssm:/topics#orders.arn".
+		The "secretsmanager", "cfn" and "cfn-export" schemes are recognised but can't be resolved yet.
+		Can be repeated, or a file of parameter paths provided prefixed with "@", the same as -t.
+		Topics resolved from parameters are listened to alongside any provided with -t.
+	-m
+		The name of an SNS topic to subscribe to instead of its ARN.
+		A bare name is resolved to a topic in the caller's account and the configured region.
+		A name containing "*", "?" or "[" is a pattern matched against every topic the caller can list, e.g. "orders-*".
+		Can be repeated, or a file of names provided prefixed with "@", the same as -t.
+	-g
+		A tag in the form "key=value" used to find SNS topics to subscribe to.
+		Every topic the caller can list with the tag is listened to, "key" alone matches any value.
+		Can be repeated, or a file of tags provided prefixed with "@", the same as -t.
+	-k
+		Check that every topic exists before creating anything.
+		Topic ARNs are always checked to be well formed, this also looks each topic up and logs whether it's a FIFO topic.
+	-e
+		The URL or ARN of an existing SQS queue to listen to instead of creating one.
+		The queue is assumed to already be subscribed to the topic unless -s is set.
+		Without -s the topic isn't needed so -t, -p, -m and -g are optional.
+		The queue is never deleted.
+	-s
+		Subscribe the existing queue provided with -e to the topics and unsubscribe it when finished.
+		The queue's policy must already allow the topic to send messages to it.
+	-a
+		The ARN of an existing subscription to attach to without creating, changing or deleting anything.
+		Messages are read from the subscription's queue without being deleted so its real consumer still receives them.
+		Every receive counts towards a message's max receive count if the queue has a dead-letter queue.
+		The topic is looked up from the subscription so -t, -p, -m and -g are optional, if provided they must match it.
+		Cannot be used with -q, -e, -s, -f, -b, -d or -r.
 	-q
 		The desired name for the SQS queue.
 		The queue name does not need to include ".fifo" for FIFO topics.
 		If omitted the queue name wil be a v4 UUID prefixed with "sns-listener-".
-	-p
-		The interval between messages to receive from the queue in miliseconds.
+	-l
+		The region to create the SQS queue in, or of the existing queue provided with -e.
+		If omitted the region of an existing queue is taken from its ARN or URL, and a new queue is created in the region of the first topic.
+		Topics in other regions are subscribed to across regions, each topic is always talked to in the region from its ARN.
+	-i
+		The interval to wait before receiving from the queue again when it was empty in miliseconds.
 		If omitted the value will be 1 second.
+	-n
+		The maximum number of messages to receive from the queue at once, between 1 and 10.
+		If omitted the value will be 1.
+	-c
+		The number of pollers and handlers used to receive and print messages.
+		Messages from the same FIFO message group are always printed in order.
+		If omitted the value will be 1.
+	-w
+		The number of seconds to wait for messages to arrive when receiving from the queue, between 0 and 20.
+		Any value above 0 enables long polling.
+		If omitted the value will be 0.
+	-f
+		The filter policy to apply to the subscription.
+		Either inline JSON or the path to a JSON file prefixed with "@", e.g. "@policy.json".
+		The policy is validated before any infrastructure is created.
+	-b
+		Apply the filter policy to the message body instead of message attributes.
+	-d
+		Evaluate the filter policy against messages read from stdin instead of listening to a topic.
+		Messages are JSON objects in the shape of the SNS envelope, such as those printed by this utility.
+		Each message is reported as a MATCH or NO MATCH on stdout. Requires -f.
+	-r
+		Enable raw message delivery on the subscription.
+		Messages are printed exactly as they were published, without the SNS envelope.
 	-v
 		Enable logging from the listener package used by this utility.
+	--record
+		The path of a file to record messages to as well as printing them, e.g. "messages.ndjson".
+		Each message is written as a line of JSON with the time it was received, the SQS message ID and system attributes,
+		the topic ARN, subject, sequence number, message, message attributes and the full body.
+		The file is gzip compressed if the path ends in ".gz". Existing files are never overwritten.
+	--record-max-size
+		The size in megabytes at which to start a new recording file.
+		Each new file is numbered before the extension, e.g. "messages.1.ndjson", "messages.2.ndjson" and so on.
+		If omitted everything is recorded to a single file.
 	-o
 		Enable the OpenTelemetry gRPC exporter.
 		Uses insecure transport.
 		Destination can be controlled with standard environment variables.
 		See: https://opentelemetry.io/docs/concepts/sdk-configuration/otlp-exporter-configuration/
 
-AWS-SNS-Listener uses v2 of the AWS SDK for interacting with the SNS, SQS and SSM APIs.
-The default credential provider is used and it does not accept named profiles.
+AWS-SNS-Listener uses v2 of the AWS SDK for interacting with the SNS, SQS, SSM and STS APIs.
+The default credential provider is used unless overridden with these flags:
+
+	--profile
+		The named profile to load configuration and credentials from.
+		Profiles that assume a role with mfa_serial set prompt for an MFA token code.
+	--region
+		The AWS region to use instead of the configured region.
+		It's used for SSM parameters, topics found with -m and -g, and a queue when neither -l nor a topic decide its region.
+	--role-arn
+		The ARN of a role to assume with the loaded credentials before doing anything else.
+	--external-id
+		The external ID to provide when assuming the role.
+	--session-name
+		The session name to use when assuming the role.
+		If omitted the value will be "aws-sns-listener".
+	--mfa-serial
+		The serial number or ARN of the MFA device required to assume the role.
+		The MFA token code is prompted for on stderr and read from stdin.
+	--topic-profile
+		The named profile for the account that owns the topics, if it's not the account the queue is in.
+		If omitted with --topic-role-arn the profile from --profile is used to assume the topic role.
+	--topic-role-arn
+		The ARN of a role to assume in the account that owns the topics.
+	--topic-external-id
+		The external ID to provide when assuming the topic role.
+	--endpoint-url
+		The URL to send every AWS request to instead of the usual endpoints, e.g. "http://localhost:4566" for LocalStack.
+	--sns-endpoint-url, --sqs-endpoint-url, --ssm-endpoint-url, --sts-endpoint-url
+		The URL to send requests for a single service to, taking precedence over --endpoint-url.
+		Services without an endpoint URL use the usual AWS endpoints.
+
+When topic credentials are provided the topics are subscribed to, and found with -m and -g, using them while the queue is
+created and received from with the other credentials. A subscription made by the topic's owner to a queue in another
+account has to be confirmed, which is done automatically when the confirmation message arrives in the queue.
+
 See: https://aws.github.io/aws-sdk-go-v2/docs/configuring-sdk/#specifying-credentials
 
+The replay subcommand reads a recording made with --record, following on to each numbered file, and republishes every
+message to a topic with its original subject and message attributes, and its message group ID and deduplication ID when
+the topic is a FIFO topic. Without a topic the messages are printed the same as when they were received.
+Messages are replayed with the same gaps between them as when they were received. Its flags are:
+
+	-t
+		The ARN of the topic to publish messages to.
+		If omitted messages are printed instead.
+	-speed
+		How much faster to replay messages than they were received, e.g. 2 halves the gaps between them.
+		0 replays messages without waiting.
+		If omitted the value will be 1.
+
+Along with --profile, --region, --role-arn, --external-id, --session-name, --mfa-serial, --endpoint-url,
+--sns-endpoint-url and --sts-endpoint-url which work the same as above.
+
+The publish subcommand sends a message to one or more topics and prints the message ID, and the sequence number for
+FIFO topics, of each. The message is the argument, the contents of a file when prefixed with "@", or read from stdin
+when it's "-" or omitted. Every topic is checked before the message is published to any of them. Its flags are:
+
+	-t
+		The ARN of an SNS topic to publish to, the same as -t above.
+	-p
+		A reference to where a topic ARN is stored, the same as -p above.
+	-s
+		The subject of the message.
+	-a
+		A message attribute in the form "name=value" for strings or "name:Type=value" for other types, e.g. "size:Number=5".
+		Binary values are base64 encoded.
+		Can be repeated, or a file of attributes provided prefixed with "@", the same as -t.
+	-j
+		The message is a JSON object with a message for each protocol and a "default" message, e.g. {"default": "hi", "sqs": "hello"}.
+	-g
+		The message group ID, required for FIFO topics.
+	-d
+		The message deduplication ID for FIFO topics, required unless the topic has content-based deduplication.
+
+Along with the same AWS configuration flags as the replay subcommand and --ssm-endpoint-url.
+
+The serve subcommand runs an in-memory SNS and SQS that speaks the same protocols as AWS, for testing offline or in CI.
+It supports creating, publishing to and subscribing to topics and creating, sending to and receiving from queues,
+along with enough of STS for topic names to be resolved. Requests aren't authenticated and nothing is persisted.
+Point this utility, or any other SDK client, at it with --endpoint-url. Its flags are:
+
+	-addr
+		The address to listen on.
+		If omitted the value will be "localhost:4566".
+	-region
+		The region of topics and queues.
+		If omitted the value will be "us-east-1".
+	-account
+		The account ID that owns topics and queues.
+		If omitted the value will be "000000000000".
+	-t
+		The name of a topic to create on start up, names ending in ".fifo" create FIFO topics.
+		Can be repeated, or a file of names provided prefixed with "@", the same as -t above.
+	-v
+		Log every request.
+
 Messages are written to stdout while logs are written to stderr.
 This allows message content to be piped or redirected without pollution by logs.
 
-Only one message at a time is received from the queue so high volume topics may result in a very full queue.
+By default only one message at a time is received from the queue so high volume topics may result in a very full queue.
+Using -n and -w to receive batches of messages with long polling helps keep up with busier topics.
 This utility is not meant for processing high volumes of messages but to help troubleshoot SNS without fussing with email or SMS.
 */
 package main
@@ -49,40 +215,150 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/whatsfordinner/aws-sns-listener/internal/resolve"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
 	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/recording"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
-type consumer struct{}
+// consumer prints messages and, if it has a recorder, records them.
+type consumer struct {
+	recorder *recording.Recorder
+}
 
 func (c consumer) OnMessage(ctx context.Context, m listener.MessageContent) {
 	fmt.Println(*m.Body)
+
+	if c.recorder == nil {
+		return
+	}
+
+	err := c.recorder.Record(m, time.Now())
+
+	if err != nil {
+		log.Printf("Unable to record message %s: %s", aws.ToString(m.Id), err.Error())
+	}
 }
 
 func main() {
 	ctx := context.Background()
 
-	topicArn := flag.String("t", "", "The ARN of the topic to listen to, cannot be set along with parameter path")
-	parameterPath := flag.String("p", "", "The path of the SSM parameter to get the topic ARN from, cannot be set along with topic ARN")
+	subcommands := map[string]func(context.Context, []string) error{
+		"serve":   serve,
+		"replay":  replay,
+		"publish": publish,
+	}
+
+	if len(os.Args) > 1 && subcommands[os.Args[1]] != nil {
+		err := subcommands[os.Args[1]](ctx, os.Args[2:])
+
+		if err != nil {
+			log.Fatalf(
+				"Error running %s: %s",
+				os.Args[1],
+				err.Error(),
+			)
+		}
+
+		return
+	}
+
+	topicArns := listFlag{}
+	flag.Var(&topicArns, "t", "The ARN of a topic to listen to, can be repeated or @path/to/topics.txt")
+	parameterPaths := listFlag{}
+	flag.Var(&parameterPaths, "p", "A reference such as ssm:/path#key to get a topic ARN from, can be repeated or @path/to/references.txt")
+	topicNames := listFlag{}
+	flag.Var(&topicNames, "m", "The name or name pattern of topics to listen to, can be repeated or @path/to/names.txt")
+	topicTags := listFlag{}
+	flag.Var(&topicTags, "g", "A key=value tag of topics to listen to, can be repeated or @path/to/tags.txt")
 	queueName := flag.String("q", "", "Optional name for the queue to create")
+	existingQueue := flag.String("e", "", "Optional URL or ARN of an existing queue to listen to instead of creating one")
+	subscribeExistingQueue := flag.Bool("s", false, "Subscribe the existing queue to the topics")
+	existingSubscription := flag.String("a", "", "Optional ARN of an existing subscription to peek at without creating anything")
+	preflight := flag.Bool("k", false, "Check that every topic exists before creating anything")
+	region := flag.String("l", "", "Optional region for the SQS queue, defaults to the region of the first topic")
 	pollingInterval := flag.Int("i", 0, "Optional duration for delay when polling the SQS queue")
+	batchSize := flag.Int("n", 1, "Optional maximum number of messages to receive from the SQS queue at once")
+	concurrency := flag.Int("c", 1, "Optional number of pollers and handlers receiving messages from the SQS queue")
+	waitTime := flag.Int("w", 0, "Optional number of seconds to wait for messages when receiving from the SQS queue")
+	filterPolicy := flag.String("f", "", "Optional filter policy for the subscription, either inline JSON or @path/to/policy.json")
+	filterBody := flag.Bool("b", false, "Apply the filter policy to the message body instead of message attributes")
+	dryRun := flag.Bool("d", false, "Evaluate the filter policy against messages from stdin instead of listening")
+	rawMessageDelivery := flag.Bool("r", false, "Enable raw message delivery on the subscription")
 	verbose := flag.Bool("v", false, "Log listener package events")
 	enableOtlp := flag.Bool("o", false, "Enable the GRPC OTLP exporter")
+	recordPath := flag.String("record", "", "Optional path of a file to record messages to as newline-delimited JSON, gzip compressed if it ends in .gz")
+	recordMaxSize := flag.Int("record-max-size", 0, "Optional size in megabytes at which to start a new recording file")
+	profile := flag.String("profile", "", "Optional named profile to load AWS configuration and credentials from")
+	awsRegion := flag.String("region", "", "Optional AWS region to use instead of the configured region")
+	roleArn := flag.String("role-arn", "", "Optional ARN of a role to assume")
+	externalId := flag.String("external-id", "", "Optional external ID to use when assuming the role")
+	sessionName := flag.String("session-name", "aws-sns-listener", "Session name to use when assuming the role")
+	mfaSerial := flag.String("mfa-serial", "", "Optional serial number or ARN of the MFA device required to assume the role")
+	endpointUrl := flag.String("endpoint-url", "", "Optional URL to send AWS requests to instead of the usual endpoints")
+	snsEndpointUrl := flag.String("sns-endpoint-url", "", "Optional URL to send SNS requests to, overrides -endpoint-url")
+	sqsEndpointUrl := flag.String("sqs-endpoint-url", "", "Optional URL to send SQS requests to, overrides -endpoint-url")
+	ssmEndpointUrl := flag.String("ssm-endpoint-url", "", "Optional URL to send SSM requests to, overrides -endpoint-url")
+	stsEndpointUrl := flag.String("sts-endpoint-url", "", "Optional URL to send STS requests to, overrides -endpoint-url")
+	topicProfile := flag.String("topic-profile", "", "Optional named profile for the account that owns the topics")
+	topicRoleArn := flag.String("topic-role-arn", "", "Optional ARN of a role to assume in the account that owns the topics")
+	topicExternalId := flag.String("topic-external-id", "", "Optional external ID to use when assuming the topic role")
 
 	flag.Parse()
 
-	if *topicArn == "" && *parameterPath == "" {
+	if *existingSubscription != "" &&
+		(*queueName != "" || *existingQueue != "" || *subscribeExistingQueue || *filterPolicy != "" || *filterBody || *dryRun || *rawMessageDelivery) {
+		log.Fatal("An existing subscription cannot be used with -q, -e, -s, -f, -b, -d or -r")
+	}
+
+	needsTopic := !*dryRun && *existingSubscription == "" && (*existingQueue == "" || *subscribeExistingQueue)
+
+	if (needsTopic && len(topicArns) == 0 && len(parameterPaths) == 0 && len(topicNames) == 0 && len(topicTags) == 0) || (*dryRun && *filterPolicy == "") {
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	filterScope := filter.ScopeMessageAttributes
+
+	if *filterBody {
+		filterScope = filter.ScopeMessageBody
+	}
+
+	if *filterPolicy != "" {
+		policy, parsedPolicy, err := readFilterPolicy(*filterPolicy, filterScope)
+
+		if err != nil {
+			log.Fatalf(
+				"Error reading filter policy: %s",
+				err.Error(),
+			)
+		}
+
+		if *dryRun {
+			err = dryRunFilterPolicy(os.Stdin, os.Stdout, parsedPolicy)
+
+			if err != nil {
+				log.Fatalf(
+					"Error evaluating filter policy: %s",
+					err.Error(),
+				)
+			}
+
+			return
+		}
+
+		*filterPolicy = policy
+	}
+
 	if *enableOtlp {
 		log.Print("Initialising GRPC OTLP exporter...")
 		shutdownTracing, err := initTracing()
@@ -97,7 +373,26 @@ func main() {
 		defer shutdownTracing()
 	}
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+	cfg, err := loadConfig(
+		ctx,
+		configOptions{
+			profile:     *profile,
+			region:      *awsRegion,
+			roleArn:     *roleArn,
+			externalId:  *externalId,
+			sessionName: *sessionName,
+			mfaSerial:   *mfaSerial,
+			endpointUrl: *endpointUrl,
+			serviceEndpointUrls: map[string]string{
+				sns.ServiceID: *snsEndpointUrl,
+				sqs.ServiceID: *sqsEndpointUrl,
+				ssm.ServiceID: *ssmEndpointUrl,
+				sts.ServiceID: *stsEndpointUrl,
+			},
+		},
+		os.Stdin,
+		os.Stderr,
+	)
 
 	otelaws.AppendMiddlewares(&cfg.APIOptions)
 
@@ -108,33 +403,177 @@ func main() {
 		)
 	}
 
-	if *parameterPath != "" {
-		paramTopicArn, err := resolve.GetParameter(
+	// Topics are talked to with the same credentials as the queue unless the topics are in another account
+	topicCfg := cfg
+
+	if *topicProfile != "" || *topicRoleArn != "" {
+		if *topicProfile == "" {
+			*topicProfile = *profile
+		}
+
+		topicCfg, err = loadConfig(
+			ctx,
+			configOptions{
+				profile:     *topicProfile,
+				region:      *awsRegion,
+				roleArn:     *topicRoleArn,
+				externalId:  *topicExternalId,
+				sessionName: *sessionName,
+				endpointUrl: *endpointUrl,
+				serviceEndpointUrls: map[string]string{
+					sns.ServiceID: *snsEndpointUrl,
+					sts.ServiceID: *stsEndpointUrl,
+				},
+			},
+			os.Stdin,
+			os.Stderr,
+		)
+
+		otelaws.AppendMiddlewares(&topicCfg.APIOptions)
+
+		if err != nil {
+			log.Fatalf(
+				"Error loading AWS configuration for topics: %s",
+				err.Error(),
+			)
+		}
+	}
+
+	for _, parameterPath := range parameterPaths {
+		paramTopicArn, err := resolve.Resolve(
+			ctx,
+			resolve.Clients{SSM: ssm.NewFromConfig(cfg)},
+			parameterPath,
+		)
+
+		if err != nil {
+			log.Fatalf(
+				"Error resolving topic ARN from %s: %s",
+				parameterPath,
+				err.Error(),
+			)
+		}
+
+		topicArns = append(topicArns, paramTopicArn)
+	}
+
+	for _, topicName := range topicNames {
+		if !strings.ContainsAny(topicName, "*?[") {
+			nameTopicArn, err := resolve.GetTopicArn(
+				ctx,
+				sts.NewFromConfig(topicCfg),
+				topicCfg.Region,
+				topicName,
+			)
+
+			if err != nil {
+				log.Fatalf(
+					"Error resolving topic with name %s: %s",
+					topicName,
+					err.Error(),
+				)
+			}
+
+			topicArns = append(topicArns, nameTopicArn)
+			continue
+		}
+
+		patternTopicArns, err := resolve.FindTopicsByName(
 			ctx,
-			ssm.NewFromConfig(cfg),
-			*parameterPath,
+			sns.NewFromConfig(topicCfg),
+			topicName,
 		)
 
 		if err != nil {
 			log.Fatalf(
-				"Error reading parameter from path %s: %s",
-				*parameterPath,
+				"Error finding topics matching %s: %s",
+				topicName,
 				err.Error(),
 			)
 		}
 
-		*topicArn = paramTopicArn
+		topicArns = append(topicArns, patternTopicArns...)
+	}
+
+	for _, topicTag := range topicTags {
+		key, value, _ := strings.Cut(topicTag, "=")
+
+		tagTopicArns, err := resolve.FindTopicsByTag(
+			ctx,
+			sns.NewFromConfig(topicCfg),
+			key,
+			value,
+		)
+
+		if err != nil {
+			log.Fatalf(
+				"Error finding topics tagged with %s: %s",
+				topicTag,
+				err.Error(),
+			)
+		}
+
+		topicArns = append(topicArns, tagTopicArns...)
+	}
+
+	topicArn := ""
+	additionalTopicArns := []string{}
+
+	if len(topicArns) > 0 {
+		topicArn = topicArns[0]
+		additionalTopicArns = topicArns[1:]
+	}
+
+	snsClients := snsClientProvider(topicCfg)
+	queue := *existingQueue
+
+	// An existing subscription's queue can be in a different region to the default one
+	if *existingSubscription != "" && *region == "" {
+		queue, err = subscriptionQueue(ctx, snsClients, *existingSubscription)
+
+		if err != nil {
+			log.Fatalf(
+				"Error looking up subscription %s: %s",
+				*existingSubscription,
+				err.Error(),
+			)
+		}
 	}
 
 	topicListener := listener.New(
-		*topicArn,
-		sns.NewFromConfig(cfg),
-		sqs.NewFromConfig(cfg),
+		topicArn,
+		sns.NewFromConfig(topicCfg),
+		sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+			o.Region = queueRegion(*region, queue, topicArns, cfg.Region)
+		}),
+		listener.WithSNSClientProvider(snsClients),
+		listener.WithAdditionalTopics(additionalTopicArns...),
 		listener.WithQueueName(*queueName),
+		listener.WithExistingQueue(*existingQueue, *subscribeExistingQueue),
+		listener.WithExistingSubscription(*existingSubscription),
+		listener.WithPreflight(*preflight),
 		listener.WithPollingInterval(time.Duration(*pollingInterval)*time.Millisecond),
+		listener.WithBatchSize(*batchSize),
+		listener.WithWaitTime(time.Duration(*waitTime)*time.Second),
+		listener.WithConcurrency(*concurrency),
+		listener.WithFilterPolicy(*filterPolicy, filterScope),
+		listener.WithRawMessageDelivery(*rawMessageDelivery),
 		listener.WithVerbose(*verbose),
 	)
 
+	c := consumer{}
+
+	if *recordPath != "" {
+		c.recorder, err = recording.NewRecorder(*recordPath, int64(*recordMaxSize)*1024*1024)
+
+		if err != nil {
+			log.Fatalf(
+				"Error creating recording: %s",
+				err.Error(),
+			)
+		}
+	}
+
 	err = topicListener.Setup(ctx)
 
 	if err != nil {
@@ -149,7 +588,7 @@ func main() {
 	listenCtx, cancel := context.WithCancel(ctx)
 
 	go func() {
-		errCh <- topicListener.Listen(listenCtx, consumer{})
+		errCh <- topicListener.Listen(listenCtx, c)
 	}()
 
 	select {
@@ -166,6 +605,14 @@ func main() {
 		}
 	}
 
+	if c.recorder != nil {
+		err = c.recorder.Close()
+
+		if err != nil {
+			log.Printf("Error closing recording: %s", err.Error())
+		}
+	}
+
 	err = topicListener.Teardown(ctx)
 
 	if err != nil {
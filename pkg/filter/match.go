@@ -0,0 +1,291 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// An Attribute is a single message attribute. Type is one of the SNS data types: String, String.Array,
+// Number or Binary, optionally followed by a custom type such as Number.float. Binary attributes are never
+// matched by SNS filter policies.
+type Attribute struct {
+	Type  string
+	Value string
+}
+
+type valueKind int
+
+const (
+	valueString valueKind = iota
+	valueNumber
+	valueBool
+	valueNull
+)
+
+type value struct {
+	kind    valueKind
+	str     string
+	num     float64
+	boolean bool
+}
+
+// Match reports whether or not a message with the provided attributes and body would be delivered to
+// a subscription using this policy. Only the part of the message corresponding to the policy's scope is
+// evaluated. An error is returned if the scope is ScopeMessageBody and the body isn't a JSON object.
+func (p *Policy) Match(attributes map[string]Attribute, body string) (bool, error) {
+	if p.Scope == ScopeMessageBody {
+		return p.MatchBody(body)
+	}
+
+	return p.MatchAttributes(attributes), nil
+}
+
+// MatchAttributes reports whether or not a message with the provided attributes would match this policy.
+// The policy's scope is ignored.
+func (p *Policy) MatchAttributes(attributes map[string]Attribute) bool {
+	return p.root.matchAttributes(attributes)
+}
+
+// MatchBody reports whether or not a message with the provided body would match this policy.
+// The policy's scope is ignored. An error is returned if the body isn't a JSON object.
+func (p *Policy) MatchBody(body string) (bool, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(body))
+	decoder.UseNumber()
+
+	var raw interface{}
+
+	err := decoder.Decode(&raw)
+
+	if err != nil {
+		return false, err
+	}
+
+	rawObject, ok := raw.(map[string]interface{})
+
+	if !ok {
+		return false, errors.New("message body is not a JSON object")
+	}
+
+	return p.root.matchBody(rawObject), nil
+}
+
+func (o *object) matchAttributes(attributes map[string]Attribute) bool {
+	for k, f := range o.fields {
+		attribute, present := attributes[k]
+
+		if !f.matches(attributeValues(attribute), present) {
+			return false
+		}
+	}
+
+	return o.matchAlternatives(func(alternative *object) bool {
+		return alternative.matchAttributes(attributes)
+	})
+}
+
+func (o *object) matchBody(body map[string]interface{}) bool {
+	for k, f := range o.fields {
+		raw, present := body[k]
+
+		if f.nested != nil {
+			nested, ok := raw.(map[string]interface{})
+
+			if !ok || !f.nested.matchBody(nested) {
+				return false
+			}
+
+			continue
+		}
+
+		if !f.matches(bodyValues(raw), present) {
+			return false
+		}
+	}
+
+	return o.matchAlternatives(func(alternative *object) bool {
+		return alternative.matchBody(body)
+	})
+}
+
+func (o *object) matchAlternatives(match func(*object) bool) bool {
+	if len(o.or) == 0 {
+		return true
+	}
+
+	for _, alternative := range o.or {
+		if match(alternative) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *field) matches(values []value, present bool) bool {
+	for _, c := range f.conditions {
+		if c.kind == conditionExists {
+			if present == c.boolean {
+				return true
+			}
+
+			continue
+		}
+
+		if !present {
+			continue
+		}
+
+		for _, v := range values {
+			if c.matches(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c condition) matches(v value) bool {
+	switch c.kind {
+	case conditionExactString:
+		return v.kind == valueString && v.str == c.str
+	case conditionExactNumber:
+		return v.kind == valueNumber && v.num == c.num
+	case conditionExactBool:
+		return v.kind == valueBool && v.boolean == c.boolean
+	case conditionExactNull:
+		return v.kind == valueNull
+	case conditionPrefix:
+		return v.kind == valueString && strings.HasPrefix(v.str, c.str)
+	case conditionSuffix:
+		return v.kind == valueString && strings.HasSuffix(v.str, c.str)
+	case conditionEqualsIgnoreCase:
+		return v.kind == valueString && strings.EqualFold(v.str, c.str)
+	case conditionAnythingBut:
+		for _, excluded := range c.anythingBut {
+			if excluded.matches(v) {
+				return false
+			}
+		}
+
+		return true
+	case conditionNumeric:
+		if v.kind != valueNumber {
+			return false
+		}
+
+		for _, bound := range c.numeric {
+			if !bound.matches(v.num) {
+				return false
+			}
+		}
+
+		return true
+	case conditionCIDR:
+		if v.kind != valueString {
+			return false
+		}
+
+		ip := net.ParseIP(v.str)
+
+		return ip != nil && c.cidr.Contains(ip)
+	}
+
+	return false
+}
+
+func (b numericBound) matches(num float64) bool {
+	switch b.operator {
+	case "=":
+		return num == b.value
+	case "<":
+		return num < b.value
+	case "<=":
+		return num <= b.value
+	case ">":
+		return num > b.value
+	case ">=":
+		return num >= b.value
+	}
+
+	return false
+}
+
+func attributeValues(attribute Attribute) []value {
+	dataType := attribute.Type
+
+	// Custom data types such as String.custom or Number.float are matched by their base type
+	if dataType != "String.Array" {
+		dataType, _, _ = strings.Cut(dataType, ".")
+	}
+
+	switch dataType {
+	case "String":
+		return []value{{kind: valueString, str: attribute.Value}}
+	case "Number":
+		num, err := strconv.ParseFloat(attribute.Value, 64)
+
+		if err != nil {
+			return nil
+		}
+
+		return []value{{kind: valueNumber, num: num}}
+	case "String.Array":
+		decoder := json.NewDecoder(bytes.NewBufferString(attribute.Value))
+		decoder.UseNumber()
+
+		var raw []interface{}
+
+		if decoder.Decode(&raw) != nil {
+			return nil
+		}
+
+		values := []value{}
+
+		for _, v := range raw {
+			values = append(values, bodyValues(v)...)
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+func bodyValues(raw interface{}) []value {
+	switch v := raw.(type) {
+	case string:
+		return []value{{kind: valueString, str: v}}
+	case json.Number:
+		num, err := v.Float64()
+
+		if err != nil {
+			return nil
+		}
+
+		return []value{{kind: valueNumber, num: num}}
+	case bool:
+		return []value{{kind: valueBool, boolean: v}}
+	case nil:
+		return []value{{kind: valueNull}}
+	case []interface{}:
+		values := []value{}
+
+		for _, element := range v {
+			switch element.(type) {
+			case []interface{}, map[string]interface{}:
+				continue
+			}
+
+			values = append(values, bodyValues(element)...)
+		}
+
+		return values
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestMatchAttributes(t *testing.T) {
+	attributes := map[string]Attribute{
+		"colour":  {"String", "Blue"},
+		"size":    {"Number", "5"},
+		"tags":    {"String.Array", `["fragile", "heavy", 10]`},
+		"source":  {"String", "10.0.0.15"},
+		"payload": {"Binary", "YmF6"},
+		"weight":  {"Number.float", "2.5"},
+		"region":  {"String.custom", "ap-southeast-2"},
+	}
+
+	tests := map[string]struct {
+		policy   string
+		expected bool
+	}{
+		"exact string":                     {`{"colour": ["Red", "Blue"]}`, true},
+		"exact string is case sensitive":   {`{"colour": ["blue"]}`, false},
+		"exact number":                     {`{"size": [5.0]}`, true},
+		"number does not match string":     {`{"size": ["5"]}`, false},
+		"prefix":                           {`{"colour": [{"prefix": "Bl"}]}`, true},
+		"suffix":                           {`{"colour": [{"suffix": "ed"}]}`, false},
+		"equals ignore case":               {`{"colour": [{"equals-ignore-case": "BLUE"}]}`, true},
+		"anything but":                     {`{"colour": [{"anything-but": ["Red", "Green"]}]}`, true},
+		"anything but excluded":            {`{"colour": [{"anything-but": "Blue"}]}`, false},
+		"anything but prefix":              {`{"colour": [{"anything-but": {"prefix": "Bl"}}]}`, false},
+		"anything but missing attribute":   {`{"shape": [{"anything-but": "square"}]}`, false},
+		"numeric range":                    {`{"size": [{"numeric": [">", 0, "<=", 5]}]}`, true},
+		"numeric range excluded":           {`{"size": [{"numeric": [">", 5]}]}`, false},
+		"exists":                           {`{"colour": [{"exists": true}]}`, true},
+		"does not exist":                   {`{"shape": [{"exists": false}]}`, true},
+		"exists when it does not":          {`{"shape": [{"exists": true}]}`, false},
+		"CIDR":                             {`{"source": [{"cidr": "10.0.0.0/24"}]}`, true},
+		"CIDR excluded":                    {`{"source": [{"cidr": "10.0.1.0/24"}]}`, false},
+		"string array element":             {`{"tags": ["heavy"]}`, true},
+		"string array numeric element":     {`{"tags": [{"numeric": [">=", 10]}]}`, true},
+		"string array no element":          {`{"tags": ["light"]}`, false},
+		"binary is never matched":          {`{"payload": [{"prefix": "Ym"}]}`, false},
+		"custom number type":               {`{"weight": [{"numeric": ["<", 3]}]}`, true},
+		"custom string type":               {`{"region": [{"prefix": "ap-"}]}`, true},
+		"missing attribute":                {`{"shape": ["square"]}`, false},
+		"all keys must match":              {`{"colour": ["Blue"], "size": [6]}`, false},
+		"or matches one alternative":       {`{"colour": ["Blue"], "$or": [{"size": [6]}, {"tags": ["fragile"]}]}`, true},
+		"or matches no alternatives":       {`{"colour": ["Blue"], "$or": [{"size": [6]}, {"tags": ["light"]}]}`, false},
+		"or with failing sibling":          {`{"colour": ["Red"], "$or": [{"size": [5]}, {"tags": ["fragile"]}]}`, false},
+		"multiple conditions on one field": {`{"colour": ["Red", {"prefix": "B"}]}`, true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := Parse(test.policy, ScopeMessageAttributes)
+
+			if err != nil {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			result := policy.MatchAttributes(attributes)
+
+			if result != test.expected {
+				t.Fatalf(
+					"Expected %t for policy %s but got %t",
+					test.expected,
+					test.policy,
+					result,
+				)
+			}
+		})
+	}
+}
+
+func TestMatchBody(t *testing.T) {
+	body := `{
+		"order": {
+			"colour": "Blue",
+			"size": 5,
+			"paid": true,
+			"refund": null,
+			"tags": ["fragile", "heavy"],
+			"customer": {"region": "us-east-1"}
+		},
+		"source": "10.0.0.15"
+	}`
+
+	tests := map[string]struct {
+		shouldErr bool
+		policy    string
+		body      string
+		expected  bool
+	}{
+		"nested exact string":         {false, `{"order": {"colour": ["Blue"]}}`, body, true},
+		"deeply nested prefix":        {false, `{"order": {"customer": {"region": [{"prefix": "us-"}]}}}`, body, true},
+		"nested numeric":              {false, `{"order": {"size": [{"numeric": [">", 4]}]}}`, body, true},
+		"boolean":                     {false, `{"order": {"paid": [true]}}`, body, true},
+		"boolean mismatch":            {false, `{"order": {"paid": [false]}}`, body, false},
+		"null":                        {false, `{"order": {"refund": [null]}}`, body, true},
+		"array element":               {false, `{"order": {"tags": ["heavy"]}}`, body, true},
+		"nested key is not object":    {false, `{"source": {"ip": ["10.0.0.15"]}}`, body, false},
+		"missing nested key":          {false, `{"order": {"shape": ["square"]}}`, body, false},
+		"nested does not exist":       {false, `{"order": {"shape": [{"exists": false}]}}`, body, true},
+		"CIDR":                        {false, `{"source": [{"cidr": "10.0.0.0/8"}]}`, body, true},
+		"or across nested keys":       {false, `{"$or": [{"order": {"size": [1]}}, {"source": [{"prefix": "10."}]}]}`, body, true},
+		"body is not JSON":            {true, `{"source": ["foo"]}`, "Hello world!", false},
+		"body is not a JSON object":   {true, `{"source": ["foo"]}`, `["foo"]`, false},
+		"leaf matched against object": {false, `{"order": ["Blue"]}`, body, false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := Parse(test.policy, ScopeMessageBody)
+
+			if err != nil {
+				t.Fatalf(
+					"Expected no error parsing policy but got %s",
+					err.Error(),
+				)
+			}
+
+			result, err := policy.Match(nil, test.body)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result != test.expected {
+					t.Fatalf(
+						"Expected %t for policy %s but got %t",
+						test.expected,
+						test.policy,
+						result,
+					)
+				}
+			}
+		})
+	}
+}
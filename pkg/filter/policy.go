@@ -0,0 +1,430 @@
+// Package filter provides a way of working with Amazon SNS subscription filter policies locally.
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Scope controls which part of a message a filter policy is applied to.
+type Scope string
+
+const (
+	// ScopeMessageAttributes applies the filter policy to the message attributes. This is the SNS default.
+	ScopeMessageAttributes Scope = "MessageAttributes"
+	// ScopeMessageBody applies the filter policy to the message body, which must be a JSON object.
+	ScopeMessageBody Scope = "MessageBody"
+)
+
+// A Policy is a filter policy that has been parsed and validated.
+// It should not be instantiated directly, instead the Parse() function should be used.
+type Policy struct {
+	// Scope is the part of the message the policy is applied to.
+	Scope Scope
+
+	root *object
+}
+
+// object is a set of keys which all have to match. If or is populated then at least one of
+// its objects also has to match.
+type object struct {
+	fields map[string]*field
+	or     []*object
+}
+
+// field is either a nested object or a list of conditions, at least one of which has to match.
+type field struct {
+	nested     *object
+	conditions []condition
+}
+
+type conditionKind int
+
+const (
+	conditionExactString conditionKind = iota
+	conditionExactNumber
+	conditionExactBool
+	conditionExactNull
+	conditionPrefix
+	conditionSuffix
+	conditionEqualsIgnoreCase
+	conditionAnythingBut
+	conditionNumeric
+	conditionExists
+	conditionCIDR
+)
+
+type condition struct {
+	kind        conditionKind
+	str         string
+	num         float64
+	boolean     bool
+	cidr        *net.IPNet
+	numeric     []numericBound
+	anythingBut []condition
+}
+
+type numericBound struct {
+	operator string
+	value    float64
+}
+
+// Parse validates the provided filter policy JSON and returns a Policy that applies to the provided scope.
+// An empty scope is treated as ScopeMessageAttributes.
+func Parse(policy string, scope Scope) (*Policy, error) {
+	if scope == "" {
+		scope = ScopeMessageAttributes
+	}
+
+	if scope != ScopeMessageAttributes && scope != ScopeMessageBody {
+		return nil, fmt.Errorf("invalid filter policy scope %q", scope)
+	}
+
+	decoder := json.NewDecoder(bytes.NewBufferString(policy))
+	decoder.UseNumber()
+
+	var raw interface{}
+
+	err := decoder.Decode(&raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter policy: %w", err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid filter policy: unexpected content after JSON object")
+	}
+
+	rawObject, ok := raw.(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("invalid filter policy: must be a JSON object")
+	}
+
+	root, err := parseObject(rawObject, "", scope)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter policy: %w", err)
+	}
+
+	return &Policy{Scope: scope, root: root}, nil
+}
+
+func parseObject(raw map[string]interface{}, path string, scope Scope) (*object, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s must not be empty", describePath(path))
+	}
+
+	o := &object{fields: map[string]*field{}}
+
+	for k, v := range raw {
+		if k == "$or" {
+			alternatives, ok := v.([]interface{})
+
+			if !ok || len(alternatives) < 2 {
+				return nil, fmt.Errorf("%s must be an array of at least two objects", describePath(joinPath(path, k)))
+			}
+
+			for _, alternative := range alternatives {
+				rawAlternative, ok := alternative.(map[string]interface{})
+
+				if !ok {
+					return nil, fmt.Errorf("%s must only contain objects", describePath(joinPath(path, k)))
+				}
+
+				parsed, err := parseObject(rawAlternative, path, scope)
+
+				if err != nil {
+					return nil, err
+				}
+
+				o.or = append(o.or, parsed)
+			}
+
+			continue
+		}
+
+		switch value := v.(type) {
+		case []interface{}:
+			conditions, err := parseConditions(value, joinPath(path, k), scope)
+
+			if err != nil {
+				return nil, err
+			}
+
+			o.fields[k] = &field{conditions: conditions}
+		case map[string]interface{}:
+			if scope != ScopeMessageBody {
+				return nil, fmt.Errorf("%s is nested but nesting is only supported when the scope is %s", describePath(joinPath(path, k)), ScopeMessageBody)
+			}
+
+			nested, err := parseObject(value, joinPath(path, k), scope)
+
+			if err != nil {
+				return nil, err
+			}
+
+			o.fields[k] = &field{nested: nested}
+		default:
+			return nil, fmt.Errorf("%s must be an array or an object", describePath(joinPath(path, k)))
+		}
+	}
+
+	return o, nil
+}
+
+func parseConditions(raw []interface{}, path string, scope Scope) ([]condition, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%s must not be an empty array", describePath(path))
+	}
+
+	conditions := make([]condition, 0, len(raw))
+
+	for _, v := range raw {
+		c, err := parseCondition(v, path, scope)
+
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, c)
+	}
+
+	return conditions, nil
+}
+
+func parseCondition(raw interface{}, path string, scope Scope) (condition, error) {
+	switch value := raw.(type) {
+	case string:
+		return condition{kind: conditionExactString, str: value}, nil
+	case json.Number:
+		num, err := parseNumber(value, path)
+		return condition{kind: conditionExactNumber, num: num}, err
+	case bool:
+		if scope != ScopeMessageBody {
+			return condition{}, fmt.Errorf("%s can only match booleans when the scope is %s", describePath(path), ScopeMessageBody)
+		}
+
+		return condition{kind: conditionExactBool, boolean: value}, nil
+	case nil:
+		if scope != ScopeMessageBody {
+			return condition{}, fmt.Errorf("%s can only match null when the scope is %s", describePath(path), ScopeMessageBody)
+		}
+
+		return condition{kind: conditionExactNull}, nil
+	case map[string]interface{}:
+		return parseOperator(value, path)
+	}
+
+	return condition{}, fmt.Errorf("%s contains an unsupported value %v", describePath(path), raw)
+}
+
+func parseOperator(raw map[string]interface{}, path string) (condition, error) {
+	if len(raw) != 1 {
+		return condition{}, fmt.Errorf("%s contains an operator object that doesn't have exactly one key", describePath(path))
+	}
+
+	for operator, operand := range raw {
+		switch operator {
+		case "prefix", "suffix", "equals-ignore-case":
+			value, ok := operand.(string)
+
+			if !ok || value == "" {
+				return condition{}, fmt.Errorf("%s operator %q requires a non-empty string", describePath(path), operator)
+			}
+
+			kinds := map[string]conditionKind{
+				"prefix":             conditionPrefix,
+				"suffix":             conditionSuffix,
+				"equals-ignore-case": conditionEqualsIgnoreCase,
+			}
+
+			return condition{kind: kinds[operator], str: value}, nil
+		case "anything-but":
+			return parseAnythingBut(operand, path)
+		case "numeric":
+			return parseNumeric(operand, path)
+		case "exists":
+			value, ok := operand.(bool)
+
+			if !ok {
+				return condition{}, fmt.Errorf("%s operator %q requires a boolean", describePath(path), operator)
+			}
+
+			return condition{kind: conditionExists, boolean: value}, nil
+		case "cidr":
+			value, ok := operand.(string)
+
+			if !ok {
+				return condition{}, fmt.Errorf("%s operator %q requires a string", describePath(path), operator)
+			}
+
+			_, cidr, err := net.ParseCIDR(value)
+
+			if err != nil {
+				ip := net.ParseIP(value)
+
+				if ip == nil {
+					return condition{}, fmt.Errorf("%s operator %q requires an IP address or CIDR block but got %q", describePath(path), operator, value)
+				}
+
+				bits := 8 * net.IPv6len
+
+				if ip.To4() != nil {
+					ip = ip.To4()
+					bits = 8 * net.IPv4len
+				}
+
+				cidr = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+			}
+
+			return condition{kind: conditionCIDR, cidr: cidr}, nil
+		default:
+			return condition{}, fmt.Errorf("%s contains an unsupported operator %q", describePath(path), operator)
+		}
+	}
+
+	return condition{}, nil
+}
+
+func parseAnythingBut(raw interface{}, path string) (condition, error) {
+	c := condition{kind: conditionAnythingBut}
+
+	switch value := raw.(type) {
+	case string:
+		c.anythingBut = []condition{{kind: conditionExactString, str: value}}
+	case json.Number:
+		num, err := parseNumber(value, path)
+
+		if err != nil {
+			return condition{}, err
+		}
+
+		c.anythingBut = []condition{{kind: conditionExactNumber, num: num}}
+	case []interface{}:
+		if len(value) == 0 {
+			return condition{}, fmt.Errorf("%s operator \"anything-but\" requires a non-empty array", describePath(path))
+		}
+
+		for _, v := range value {
+			switch excluded := v.(type) {
+			case string:
+				c.anythingBut = append(c.anythingBut, condition{kind: conditionExactString, str: excluded})
+			case json.Number:
+				num, err := parseNumber(excluded, path)
+
+				if err != nil {
+					return condition{}, err
+				}
+
+				c.anythingBut = append(c.anythingBut, condition{kind: conditionExactNumber, num: num})
+			default:
+				return condition{}, fmt.Errorf("%s operator \"anything-but\" only supports arrays of strings or numbers", describePath(path))
+			}
+		}
+	case map[string]interface{}:
+		nested, err := parseOperator(value, path)
+
+		if err != nil {
+			return condition{}, err
+		}
+
+		if nested.kind != conditionPrefix && nested.kind != conditionSuffix {
+			return condition{}, fmt.Errorf("%s operator \"anything-but\" only supports nested \"prefix\" or \"suffix\" operators", describePath(path))
+		}
+
+		c.anythingBut = []condition{nested}
+	default:
+		return condition{}, fmt.Errorf("%s operator \"anything-but\" requires a string, number, array or operator object", describePath(path))
+	}
+
+	return c, nil
+}
+
+func parseNumeric(raw interface{}, path string) (condition, error) {
+	operands, ok := raw.([]interface{})
+
+	if !ok || (len(operands) != 2 && len(operands) != 4) {
+		return condition{}, fmt.Errorf("%s operator \"numeric\" requires an array of one or two operator and value pairs", describePath(path))
+	}
+
+	c := condition{kind: conditionNumeric}
+
+	for i := 0; i < len(operands); i += 2 {
+		operator, ok := operands[i].(string)
+
+		if !ok {
+			return condition{}, fmt.Errorf("%s operator \"numeric\" has a comparison that isn't a string", describePath(path))
+		}
+
+		value, ok := operands[i+1].(json.Number)
+
+		if !ok {
+			return condition{}, fmt.Errorf("%s operator \"numeric\" has a value that isn't a number", describePath(path))
+		}
+
+		num, err := parseNumber(value, path)
+
+		if err != nil {
+			return condition{}, err
+		}
+
+		c.numeric = append(c.numeric, numericBound{operator: operator, value: num})
+	}
+
+	switch {
+	case len(c.numeric) == 1 && isNumericOperator(c.numeric[0].operator, "=", "<", "<=", ">", ">="):
+	case len(c.numeric) == 2 &&
+		isNumericOperator(c.numeric[0].operator, ">", ">=") &&
+		isNumericOperator(c.numeric[1].operator, "<", "<=") &&
+		c.numeric[0].value < c.numeric[1].value:
+	default:
+		return condition{}, fmt.Errorf("%s operator \"numeric\" has an invalid range", describePath(path))
+	}
+
+	return c, nil
+}
+
+func isNumericOperator(operator string, valid ...string) bool {
+	for _, v := range valid {
+		if operator == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseNumber(value json.Number, path string) (float64, error) {
+	num, err := strconv.ParseFloat(value.String(), 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s contains an invalid number %s", describePath(path), value)
+	}
+
+	// SNS only supports numbers between -1 billion and 1 billion with 5 digits of precision
+	if num < -1e9 || num > 1e9 {
+		return 0, fmt.Errorf("%s contains a number outside of the supported range: %s", describePath(path), value)
+	}
+
+	return num, nil
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "policy"
+	}
+
+	return fmt.Sprintf("key %q", path)
+}
@@ -0,0 +1,70 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		policy    string
+		scope     Scope
+	}{
+		"exact match":                     {false, `{"colour": ["blue", "red"]}`, ScopeMessageAttributes},
+		"default scope":                   {false, `{"colour": ["blue"]}`, ""},
+		"numeric exact match":             {false, `{"size": [5, 10.5]}`, ScopeMessageAttributes},
+		"prefix":                          {false, `{"colour": [{"prefix": "bl"}]}`, ScopeMessageAttributes},
+		"suffix":                          {false, `{"colour": [{"suffix": "ue"}]}`, ScopeMessageAttributes},
+		"equals ignore case":              {false, `{"colour": [{"equals-ignore-case": "BLUE"}]}`, ScopeMessageAttributes},
+		"anything but string":             {false, `{"colour": [{"anything-but": "blue"}]}`, ScopeMessageAttributes},
+		"anything but list":               {false, `{"colour": [{"anything-but": ["blue", 5]}]}`, ScopeMessageAttributes},
+		"anything but prefix":             {false, `{"colour": [{"anything-but": {"prefix": "bl"}}]}`, ScopeMessageAttributes},
+		"numeric range":                   {false, `{"size": [{"numeric": [">", 0, "<=", 10]}]}`, ScopeMessageAttributes},
+		"numeric equals":                  {false, `{"size": [{"numeric": ["=", 5]}]}`, ScopeMessageAttributes},
+		"exists":                          {false, `{"colour": [{"exists": false}]}`, ScopeMessageAttributes},
+		"CIDR":                            {false, `{"source": [{"cidr": "10.0.0.0/24"}]}`, ScopeMessageAttributes},
+		"single IP":                       {false, `{"source": [{"cidr": "10.0.0.1"}]}`, ScopeMessageAttributes},
+		"or":                              {false, `{"colour": ["blue"], "$or": [{"size": [5]}, {"shape": ["square"]}]}`, ScopeMessageAttributes},
+		"nested body keys":                {false, `{"order": {"colour": ["blue"], "size": [{"numeric": [">", 5]}]}}`, ScopeMessageBody},
+		"body booleans and null":          {false, `{"paid": [true], "refund": [null]}`, ScopeMessageBody},
+		"invalid JSON":                    {true, `{"colour": ["blue"]`, ScopeMessageAttributes},
+		"not an object":                   {true, `["blue"]`, ScopeMessageAttributes},
+		"trailing content":                {true, `{"colour": ["blue"]} {}`, ScopeMessageAttributes},
+		"empty policy":                    {true, `{}`, ScopeMessageAttributes},
+		"invalid scope":                   {true, `{"colour": ["blue"]}`, "Body"},
+		"value not an array":              {true, `{"colour": "blue"}`, ScopeMessageAttributes},
+		"empty array":                     {true, `{"colour": []}`, ScopeMessageAttributes},
+		"nested attribute keys":           {true, `{"order": {"colour": ["blue"]}}`, ScopeMessageAttributes},
+		"attribute booleans":              {true, `{"paid": [true]}`, ScopeMessageAttributes},
+		"unknown operator":                {true, `{"colour": [{"contains": "blue"}]}`, ScopeMessageAttributes},
+		"multiple operators":              {true, `{"colour": [{"prefix": "bl", "suffix": "ue"}]}`, ScopeMessageAttributes},
+		"empty prefix":                    {true, `{"colour": [{"prefix": ""}]}`, ScopeMessageAttributes},
+		"numeric range backwards":         {true, `{"size": [{"numeric": ["<", 10, ">", 0]}]}`, ScopeMessageAttributes},
+		"numeric range empty":             {true, `{"size": [{"numeric": [">", 10, "<", 0]}]}`, ScopeMessageAttributes},
+		"numeric unknown operator":        {true, `{"size": [{"numeric": ["!=", 10]}]}`, ScopeMessageAttributes},
+		"numeric out of range":            {true, `{"size": [1000000001]}`, ScopeMessageAttributes},
+		"exists not a boolean":            {true, `{"colour": [{"exists": "true"}]}`, ScopeMessageAttributes},
+		"invalid CIDR":                    {true, `{"source": [{"cidr": "10.0.0.0/33"}]}`, ScopeMessageAttributes},
+		"anything but nested exists":      {true, `{"colour": [{"anything-but": {"exists": true}}]}`, ScopeMessageAttributes},
+		"or with a single alternative":    {true, `{"$or": [{"size": [5]}]}`, ScopeMessageAttributes},
+		"or with an invalid alternative":  {true, `{"$or": [{"size": [5]}, {"shape": "square"}]}`, ScopeMessageAttributes},
+		"or with non-object alternatives": {true, `{"$or": [["foo"], ["bar"]]}`, ScopeMessageAttributes},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(test.policy, test.scope)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package listener
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+var (
+	regionPattern    = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+	accountPattern   = regexp.MustCompile(`^[0-9]{12}$`)
+	topicNamePattern = regexp.MustCompile(`^([A-Za-z0-9_\-]{1,256}|[A-Za-z0-9_\-]{1,251}\.fifo)$`)
+)
+
+// A TopicArn is the parsed ARN of an SNS topic.
+type TopicArn struct {
+	Partition string
+	Region    string
+	AccountID string
+	Name      string
+}
+
+// ParseTopicArn parses the provided ARN and checks that it's for an SNS topic.
+// The partition, region, account ID and topic name are all checked to be well formed.
+func ParseTopicArn(topicArn string) (TopicArn, error) {
+	parsed, err := arn.Parse(topicArn)
+
+	if err != nil {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: %w", topicArn, err)
+	}
+
+	if !strings.HasPrefix(parsed.Partition, "aws") {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: unknown partition %s", topicArn, parsed.Partition)
+	}
+
+	if parsed.Service != "sns" {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: it's for %s not sns", topicArn, parsed.Service)
+	}
+
+	if !regionPattern.MatchString(parsed.Region) {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: invalid region %s", topicArn, parsed.Region)
+	}
+
+	if !accountPattern.MatchString(parsed.AccountID) {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: invalid account ID %s", topicArn, parsed.AccountID)
+	}
+
+	if strings.Contains(parsed.Resource, ":") {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: it looks like a subscription ARN", topicArn)
+	}
+
+	if !topicNamePattern.MatchString(parsed.Resource) {
+		return TopicArn{}, fmt.Errorf("%s is not a valid SNS topic ARN: invalid topic name %s", topicArn, parsed.Resource)
+	}
+
+	return TopicArn{
+		Partition: parsed.Partition,
+		Region:    parsed.Region,
+		AccountID: parsed.AccountID,
+		Name:      parsed.Resource,
+	}, nil
+}
+
+// IsFIFO returns true if the topic's name has the ".fifo" suffix that every FIFO topic must have.
+func (a TopicArn) IsFIFO() bool {
+	return strings.HasSuffix(a.Name, ".fifo")
+}
+
+// String returns the topic ARN in its usual form.
+func (a TopicArn) String() string {
+	return fmt.Sprintf("arn:%s:sns:%s:%s:%s", a.Partition, a.Region, a.AccountID, a.Name)
+}
@@ -0,0 +1,71 @@
+package listener
+
+import "testing"
+
+func TestParseTopicArn(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr    bool
+		topicArn     string
+		expected     TopicArn
+		expectedFIFO bool
+	}{
+		"standard topic": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:my-topic",
+			TopicArn{"aws", "us-east-1", "123456789012", "my-topic"},
+			false,
+		},
+		"FIFO topic": {
+			false,
+			"arn:aws:sns:ap-southeast-2:123456789012:my_topic.fifo",
+			TopicArn{"aws", "ap-southeast-2", "123456789012", "my_topic.fifo"},
+			true,
+		},
+		"other partition": {
+			false,
+			"arn:aws-us-gov:sns:us-gov-west-1:123456789012:my-topic",
+			TopicArn{"aws-us-gov", "us-gov-west-1", "123456789012", "my-topic"},
+			false,
+		},
+		"not an ARN":           {true, "my-topic", TopicArn{}, false},
+		"unknown partition":    {true, "arn:azure:sns:us-east-1:123456789012:my-topic", TopicArn{}, false},
+		"other service":        {true, "arn:aws:sqs:us-east-1:123456789012:my-queue", TopicArn{}, false},
+		"missing region":       {true, "arn:aws:sns::123456789012:my-topic", TopicArn{}, false},
+		"invalid region":       {true, "arn:aws:sns:us-east:123456789012:my-topic", TopicArn{}, false},
+		"invalid account":      {true, "arn:aws:sns:us-east-1:12345:my-topic", TopicArn{}, false},
+		"subscription ARN":     {true, "arn:aws:sns:us-east-1:123456789012:my-topic:8a21d249-4329-4871-acc6-7be709c6ea7f", TopicArn{}, false},
+		"invalid topic name":   {true, "arn:aws:sns:us-east-1:123456789012:my topic", TopicArn{}, false},
+		"fifo not as a suffix": {true, "arn:aws:sns:us-east-1:123456789012:my.fifo-topic", TopicArn{}, false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := ParseTopicArn(test.topicArn)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result != test.expected {
+					t.Fatalf("Parsed ARN %+v did not match expected %+v", result, test.expected)
+				}
+
+				if result.IsFIFO() != test.expectedFIFO {
+					t.Fatalf("Expected FIFO to be %t but got %t", test.expectedFIFO, result.IsFIFO())
+				}
+
+				if result.String() != test.topicArn {
+					t.Fatalf("ARN %s did not match original ARN %s", result.String(), test.topicArn)
+				}
+			}
+		})
+	}
+}
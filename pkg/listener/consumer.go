@@ -2,6 +2,7 @@ package listener
 
 import (
 	"context"
+	"time"
 )
 
 // A Consumer is used by ListenToTopic to process messages and errors during the course of oeprations. Both of its methods are provided
@@ -13,10 +14,74 @@ type Consumer interface {
 	OnMessage(ctx context.Context, msg MessageContent)
 }
 
+// A Handler is used by ListenWithHandler to process messages when the caller needs to decide whether or not a message
+// should be removed from the queue. Like a Consumer it's provided with the existing context used by the package.
+type Handler interface {
+	// HandleMessage is called when a message is received from the SQS queue. The message is only deleted from the queue
+	// if HandleMessage returns nil, otherwise it will be received again once its visibility timeout expires. Returning
+	// an error created with Retry will change the visibility timeout so the message is received again sooner or later.
+	HandleMessage(ctx context.Context, msg MessageContent) error
+}
+
+// HandlerFunc allows an ordinary function to be used as a Handler.
+type HandlerFunc func(ctx context.Context, msg MessageContent) error
+
+// HandleMessage calls f(ctx, msg).
+func (f HandlerFunc) HandleMessage(ctx context.Context, msg MessageContent) error {
+	return f(ctx, msg)
+}
+
+// A RetryError is returned by a Handler to control when a message that wasn't handled successfully will be received again.
+type RetryError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Retry wraps err so that the message will be made visible in the queue again after delay instead of
+// waiting for the visibility timeout to expire. A delay of 0 makes the message visible immediately.
+// SQS only supports whole seconds so the delay is truncated.
+func Retry(err error, delay time.Duration) error {
+	return &RetryError{Err: err, Delay: delay}
+}
+
+// consumerHandler adapts a Consumer so that it can be used wherever a Handler is expected.
+type consumerHandler struct {
+	consumer Consumer
+}
+
+func (h consumerHandler) HandleMessage(ctx context.Context, msg MessageContent) error {
+	h.consumer.OnMessage(ctx, msg)
+	return nil
+}
+
 // A MessageContent maps the message body and message ID of a SQS message to
 // a much more straightforward struct. For the purpose of listening to an SNS
-// topic, the Body contains the full message that was published
+// topic, the Body contains the full message that was published and Notification
+// contains the same message decoded from the SNS envelope.
 type MessageContent struct {
 	Body *string
 	Id   *string
+	// Message is the message that was published to the topic. With raw message delivery it's the
+	// same as Body, otherwise it's taken from the SNS envelope.
+	Message *string
+	// MessageAttributes are the attributes that were published alongside the message. With raw
+	// message delivery SNS passes these on as SQS message attributes.
+	MessageAttributes map[string]MessageAttribute
+	// TopicArn is the topic the message was published to. It's taken from the SNS envelope, so with raw
+	// message delivery it's only known when the Listener is listening to a single topic, otherwise it's nil.
+	TopicArn *string
+	// Notification is nil if the body couldn't be decoded as an SNS notification. This is always
+	// the case with raw message delivery.
+	Notification *Notification
+	// Attributes are the SQS system attributes of the message, such as SentTimestamp and ApproximateReceiveCount,
+	// and MessageGroupId, MessageDeduplicationId and SequenceNumber for messages from FIFO queues.
+	Attributes map[string]string
 }
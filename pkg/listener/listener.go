@@ -4,11 +4,14 @@ package listener
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 )
@@ -25,27 +28,57 @@ var logger *log.Logger = log.New(
 // A Listener manages the resources for listening to a queue.
 // It should not be instantiated directly, instead the New() function should be used.
 type Listener struct {
-	// PollingInterval is the time between attempts to receive messages from the SQS queue
+	// PollingInterval is the time to wait before trying to receive messages again after the SQS queue was found empty
 	PollingInterval time.Duration
+	// BatchSize is the maximum number of messages to receive from the SQS queue at once, between 1 and 10
+	BatchSize int
+	// WaitTime is how long a receive will wait for messages to arrive before returning, between 0 and 20 seconds
+	WaitTime time.Duration
+	// Concurrency is the number of pollers receiving messages and the number of handlers passing them to the Consumer
+	Concurrency int
+	// VisibilityTimeout is how long received messages are hidden from other receivers. It's extended while a message is waiting to be handled and while ListenWithHandler is handling it
+	VisibilityTimeout time.Duration
+	// ExistingQueue is the URL or ARN of an existing SQS queue to listen to instead of creating one. It's never deleted by Teardown
+	ExistingQueue string
+	// SubscribeExistingQueue will subscribe ExistingQueue to the topic when true, otherwise it's assumed to already be subscribed
+	SubscribeExistingQueue bool
+	// ExistingSubscription is the ARN of an existing subscription to an SQS queue. When set the Listener only peeks at messages in that queue and creates nothing
+	ExistingSubscription string
+	// MaxReceiveCount is the number of times a message can be received before it's moved to a dead-letter queue. If 0 no dead-letter queue is created
+	MaxReceiveCount int
 	// QueueName is the desired name for the SQS queue. If blank a v4 UUID prefixed with "sns-listener" will be used
 	QueueName string
 	// TopicArn is the ARN of the SNS topic to be listened to.
 	TopicArn string
+	// AdditionalTopicArns are the ARNs of any other SNS topics to be listened to with the same queue
+	AdditionalTopicArns []string
+	// Preflight will look up every topic with GetTopicAttributes during Setup to confirm it exists before anything is created
+	Preflight bool
+	// FilterPolicy is an optional SNS filter policy applied to the subscription
+	FilterPolicy string
+	// FilterPolicyScope controls whether FilterPolicy is applied to message attributes or the message body
+	FilterPolicyScope filter.Scope
+	// RawMessageDelivery will deliver messages to the SQS queue without the SNS envelope when true
+	RawMessageDelivery bool
 	// Verbose will enable logging to stderr when true, otherwise logs are discarded
 	Verbose bool
 	// SnsClient is a user-provided client used to interact with the SNS API
 	SnsClient SNSAPI
+	// SnsClientProvider returns the client used for topics and subscriptions in a region. If nil SnsClient is used for every region
+	SnsClientProvider SNSClientProvider
 	// SqsClient is a user-provided client used to interact with the SQS API
 	SqsClient SQSAPI
 
-	queueUrl        string
-	subscriptionArn string
+	queueUrl           string
+	deadLetterQueueUrl string
+	subscriptionArns   []string
 }
 
 // An Option allows for the passing of optional parameters when creating a new Listener.
 type Option func(l *Listener)
 
 // WithPollingInterval will set PollingInterval to the provided time.
+// The Listener only waits for the polling interval when a receive returns no messages.
 // Defaults to 1 second if set to 0.
 func WithPollingInterval(pollingInterval time.Duration) Option {
 	return func(l *Listener) {
@@ -58,6 +91,133 @@ func WithPollingInterval(pollingInterval time.Duration) Option {
 	}
 }
 
+// WithBatchSize will set the maximum number of messages received from the SQS queue at once.
+// Defaults to 1 if not between 1 and 10.
+func WithBatchSize(batchSize int) Option {
+	return func(l *Listener) {
+		if batchSize < 1 || batchSize > 10 {
+			log.Printf("Provided batch size invalid: %d. Defaulting to 1", batchSize)
+			l.BatchSize = 1
+		} else {
+			l.BatchSize = batchSize
+		}
+	}
+}
+
+// WithWaitTime enables long polling by setting how long each receive waits for messages to arrive.
+// SQS only supports whole seconds so the wait time is truncated.
+// Defaults to 0, which disables long polling, if not between 0 and 20 seconds.
+func WithWaitTime(waitTime time.Duration) Option {
+	return func(l *Listener) {
+		if waitTime < 0 || waitTime > 20*time.Second {
+			log.Printf("Provided wait time invalid: %s. Defaulting to 0 seconds", waitTime)
+			l.WaitTime = 0
+		} else {
+			l.WaitTime = waitTime
+		}
+	}
+}
+
+// WithConcurrency will set the number of pollers and handlers used to receive and process messages.
+// At most Concurrency messages are passed to the Consumer at once. Messages from the same FIFO message group
+// are always passed to the Consumer one at a time in the order they were received.
+// Defaults to 1 if set to less than 1.
+func WithConcurrency(concurrency int) Option {
+	return func(l *Listener) {
+		if concurrency < 1 {
+			log.Printf("Provided concurrency invalid: %d. Defaulting to 1", concurrency)
+			l.Concurrency = 1
+		} else {
+			l.Concurrency = concurrency
+		}
+	}
+}
+
+// WithVisibilityTimeout will set how long messages are hidden from other receivers once they've been received.
+// The visibility timeout is extended every half a visibility timeout from when a message is received until it's
+// passed to the Consumer or, when using ListenWithHandler, until the Handler has finished with it. So it only needs
+// to be long enough to cover a single heartbeat.
+// Defaults to 60 seconds if not between 1 second and 12 hours.
+func WithVisibilityTimeout(visibilityTimeout time.Duration) Option {
+	return func(l *Listener) {
+		if visibilityTimeout < time.Second || visibilityTimeout > 12*time.Hour {
+			log.Printf("Provided visibility timeout invalid: %s. Defaulting to 60 seconds", visibilityTimeout)
+			l.VisibilityTimeout = 60 * time.Second
+		} else {
+			l.VisibilityTimeout = visibilityTimeout
+		}
+	}
+}
+
+// WithDeadLetterQueue will create a dead-letter queue alongside the SQS queue. Messages that have been received
+// maxReceiveCount times without being deleted are moved to it instead of being received again. The dead-letter queue
+// is named after the SQS queue with "-dlq" appended and is deleted along with it.
+// No dead-letter queue is created if set to 0. Defaults to 5 if not between 0 and 1000.
+func WithDeadLetterQueue(maxReceiveCount int) Option {
+	return func(l *Listener) {
+		if maxReceiveCount < 0 || maxReceiveCount > 1000 {
+			log.Printf("Provided max receive count invalid: %d. Defaulting to 5", maxReceiveCount)
+			l.MaxReceiveCount = 5
+		} else {
+			l.MaxReceiveCount = maxReceiveCount
+		}
+	}
+}
+
+// WithExistingQueue will listen to an existing SQS queue instead of creating a new one. The queue can be identified
+// by either its URL or its ARN. If subscribe is true then Setup subscribes the queue to the topic and Teardown
+// unsubscribes it, otherwise the queue is assumed to already be subscribed. Either way the queue is left alone by Teardown.
+// The queue's policy must already allow the topic to send messages to it.
+func WithExistingQueue(queue string, subscribe bool) Option {
+	return func(l *Listener) {
+		l.ExistingQueue = queue
+		l.SubscribeExistingQueue = subscribe
+	}
+}
+
+// WithExistingSubscription will listen to the SQS queue of an existing subscription without creating, deleting or
+// changing anything. Setup looks up the queue from the subscription and Teardown does nothing. Messages are never
+// deleted from the queue, instead they're made visible again as soon as they've been handled so that the subscription's
+// real consumer still receives them. Each message is only passed to the Consumer or Handler once.
+// Every receive counts towards a message's max receive count, so a message the real consumer leaves in the queue will
+// eventually be moved to the queue's dead-letter queue if it has one. Setup logs a warning when it does. To limit this,
+// the Listener backs off for longer each time it only receives messages it's already seen, up to 5 minutes.
+// It can't be used with WithQueueName, WithExistingQueue, WithDeadLetterQueue, WithFilterPolicy or
+// WithRawMessageDelivery since the subscription already decides those. Message delivery follows the subscription's
+// raw message delivery setting instead. If a topic ARN is provided it must match the subscription's topic.
+func WithExistingSubscription(subscriptionArn string) Option {
+	return func(l *Listener) {
+		l.ExistingSubscription = subscriptionArn
+	}
+}
+
+// WithAdditionalTopics will listen to the provided topics as well as the topic passed to New, using a single queue
+// subscribed to all of them. The queue's policy allows every topic to send messages to it. Either every topic must be
+// a FIFO topic or none of them can be. The topic a message was published to is available from its MessageContent.
+func WithAdditionalTopics(topicArns ...string) Option {
+	return func(l *Listener) {
+		l.AdditionalTopicArns = append(l.AdditionalTopicArns, topicArns...)
+	}
+}
+
+// WithPreflight controls whether or not Setup looks up every topic before creating anything.
+// Topic ARNs are always checked to be well formed, the preflight also confirms that the topics exist and that the
+// caller can see them. It requires permission to call GetTopicAttributes.
+func WithPreflight(preflight bool) Option {
+	return func(l *Listener) {
+		l.Preflight = preflight
+	}
+}
+
+// WithSNSClientProvider will use the clients returned by the provider to talk to SNS about each topic and subscription,
+// based on the region in its ARN. This allows listening to topics in regions other than the SNS client's region.
+// The queue is always created with the SQS client, SNS delivers messages to queues in other regions.
+func WithSNSClientProvider(provider SNSClientProvider) Option {
+	return func(l *Listener) {
+		l.SnsClientProvider = provider
+	}
+}
+
 // WithQueueName will control the name of the SQS queue created by the Listener.
 // When listening to a FIFO topic, the Listener will add ".fifo" to the queue itself.
 func WithQueueName(queueName string) Option {
@@ -66,6 +226,24 @@ func WithQueueName(queueName string) Option {
 	}
 }
 
+// WithFilterPolicy will apply the provided filter policy to the subscription so that only matching messages are delivered to the queue.
+// The policy is validated when Setup is called, before any infrastructure is created.
+// If the scope is empty then the policy is applied to message attributes.
+func WithFilterPolicy(filterPolicy string, scope filter.Scope) Option {
+	return func(l *Listener) {
+		l.FilterPolicy = filterPolicy
+		l.FilterPolicyScope = scope
+	}
+}
+
+// WithRawMessageDelivery controls whether or not the subscription is created with raw message delivery enabled.
+// When enabled the message body is exactly what was published to the topic and message attributes are delivered as SQS message attributes.
+func WithRawMessageDelivery(rawMessageDelivery bool) Option {
+	return func(l *Listener) {
+		l.RawMessageDelivery = rawMessageDelivery
+	}
+}
+
 // WithVerbose controls whether or not logs will be printed to stderr.
 func WithVerbose(verbose bool) Option {
 	return func(l *Listener) {
@@ -80,6 +258,10 @@ func New(topicArn string, snsClient SNSAPI, sqsClient SQSAPI, opts ...Option) *L
 	l.TopicArn = topicArn
 	l.SnsClient = snsClient
 	l.SqsClient = sqsClient
+	l.PollingInterval = time.Second
+	l.BatchSize = 1
+	l.Concurrency = 1
+	l.VisibilityTimeout = 60 * time.Second
 
 	for _, opt := range opts {
 		opt(l)
@@ -88,9 +270,14 @@ func New(topicArn string, snsClient SNSAPI, sqsClient SQSAPI, opts ...Option) *L
 	return l
 }
 
-// Setup will create an SQS queue and subscribe it to that queue.
-// The queue is given a policy that allows the SNS topic to subscribe to it.
-// Once the queue is subscribed to the SNS topic it will start receiving published messages.
+// Setup will create an SQS queue and subscribe it to the topic, or to every topic if additional topics have been provided.
+// If an existing queue has been provided then it's used instead and only subscribed if requested.
+// If an existing subscription has been provided then its queue is looked up and nothing is created.
+// Every topic ARN is checked to be well formed, and if a preflight was requested looked up, before anything is created.
+// If a filter policy has been provided it's validated before anything is created.
+// If a max receive count has been provided a dead-letter queue is created first and the queue redrives to it.
+// The queue is given a policy that allows the SNS topics to subscribe to it.
+// Once the queue is subscribed to an SNS topic it will start receiving messages published to it.
 func (l *Listener) Setup(ctx context.Context) error {
 	ctx, span := otel.Tracer(name).Start(ctx, "Listener.Setup")
 	defer span.End()
@@ -99,7 +286,7 @@ func (l *Listener) Setup(ctx context.Context) error {
 		logger.SetOutput(os.Stderr)
 	}
 
-	queueUrl, err := createQueue(ctx, l.SqsClient, l.QueueName, l.TopicArn)
+	err := l.checkTopics(ctx)
 
 	if err != nil {
 		span.RecordError(err)
@@ -107,9 +294,34 @@ func (l *Listener) Setup(ctx context.Context) error {
 		return err
 	}
 
-	l.queueUrl = queueUrl
+	if l.ExistingSubscription != "" {
+		err := l.attachToSubscription(ctx)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+
+		span.SetStatus(codes.Ok, "")
+		return nil
+	}
+
+	if l.FilterPolicy != "" {
+		_, err := filter.Parse(l.FilterPolicy, l.FilterPolicyScope)
 
-	queueArn, err := getQueueArn(ctx, l.SqsClient, queueUrl)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+
+	if l.ExistingQueue != "" {
+		l.queueUrl, err = resolveQueueUrl(ctx, l.SqsClient, l.ExistingQueue)
+	} else {
+		err = l.createQueues(ctx)
+	}
 
 	if err != nil {
 		span.RecordError(err)
@@ -117,7 +329,14 @@ func (l *Listener) Setup(ctx context.Context) error {
 		return err
 	}
 
-	subscriptionArn, err := subscribeToTopic(ctx, l.SnsClient, l.TopicArn, queueArn)
+	if l.ExistingQueue != "" && !l.SubscribeExistingQueue {
+		logger.Printf("Using existing queue %s, assuming it's already subscribed to the topic", l.queueUrl)
+
+		span.SetStatus(codes.Ok, "")
+		return nil
+	}
+
+	queueArn, err := getQueueArn(ctx, l.SqsClient, l.queueUrl)
 
 	if err != nil {
 		span.RecordError(err)
@@ -125,25 +344,310 @@ func (l *Listener) Setup(ctx context.Context) error {
 		return err
 	}
 
-	l.subscriptionArn = subscriptionArn
+	for _, topicArn := range l.topicArns() {
+		subscriptionArn, err := subscribeToTopic(ctx, l.snsClient(), topicArn, queueArn, l.subscriptionAttributes())
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+
+		// Subscriptions are tracked as they're made so Teardown can remove them if a later one fails
+		l.subscriptionArns = append(l.subscriptionArns, subscriptionArn)
+
+		if isCrossAccount(topicArn, queueArn) {
+			l.checkPendingConfirmation(ctx, subscriptionArn)
+		}
+	}
 
+	span.SetStatus(codes.Ok, "")
 	return nil
 }
 
+// topicArns returns every topic the Listener is listening to, skipping blanks and duplicates.
+func (l *Listener) topicArns() []string {
+	topicArns := []string{}
+	seen := map[string]bool{}
+
+	for _, topicArn := range append([]string{l.TopicArn}, l.AdditionalTopicArns...) {
+		if topicArn == "" || seen[topicArn] {
+			continue
+		}
+
+		seen[topicArn] = true
+		topicArns = append(topicArns, topicArn)
+	}
+
+	return topicArns
+}
+
+// checkPendingConfirmation warns when a subscription to a topic in another account is waiting to be confirmed.
+// It's confirmed once Listen receives the confirmation message from the queue, until then no messages are delivered.
+func (l *Listener) checkPendingConfirmation(ctx context.Context, subscriptionArn string) {
+	attributes, err := getSubscriptionAttributes(ctx, l.snsClient(), subscriptionArn)
+
+	if err != nil {
+		logger.Printf("Unable to check whether subscription %s is pending confirmation: %s", subscriptionArn, err.Error())
+		return
+	}
+
+	if attributes["PendingConfirmation"] == "true" {
+		log.Printf("Subscription %s is pending confirmation, it will be confirmed when the confirmation message is received", subscriptionArn)
+	}
+}
+
+// snsClient returns the client to use for SNS, which sends requests to the right region if there's a provider.
+func (l *Listener) snsClient() SNSAPI {
+	if l.SnsClientProvider == nil {
+		return l.SnsClient
+	}
+
+	return regionalSNSClient{l.SnsClient, l.SnsClientProvider}
+}
+
+// checkTopics checks that every topic ARN is well formed and, if a preflight was requested, that every topic exists.
+func (l *Listener) checkTopics(ctx context.Context) error {
+	for _, topicArn := range l.topicArns() {
+		_, err := ParseTopicArn(topicArn)
+
+		if err != nil {
+			return err
+		}
+
+		if !l.Preflight {
+			continue
+		}
+
+		_, err = getTopicAttributes(ctx, l.snsClient(), topicArn)
+
+		if err != nil {
+			return fmt.Errorf("unable to find topic %s: %w", topicArn, err)
+		}
+	}
+
+	return nil
+}
+
+// attachToSubscription finds the queue for an existing subscription and matches the subscription's message delivery.
+func (l *Listener) attachToSubscription(ctx context.Context) error {
+	err := l.checkExistingSubscriptionOptions()
+
+	if err != nil {
+		return err
+	}
+
+	attributes, err := getSubscriptionAttributes(ctx, l.snsClient(), l.ExistingSubscription)
+
+	if err != nil {
+		return err
+	}
+
+	if attributes["Protocol"] != "sqs" {
+		return fmt.Errorf("subscription %s delivers to %s, not an SQS queue", l.ExistingSubscription, attributes["Protocol"])
+	}
+
+	if l.TopicArn != "" && l.TopicArn != attributes["TopicArn"] {
+		return fmt.Errorf("subscription %s is for topic %s, not %s", l.ExistingSubscription, attributes["TopicArn"], l.TopicArn)
+	}
+
+	queueUrl, err := resolveQueueUrl(ctx, l.SqsClient, attributes["Endpoint"])
+
+	if err != nil {
+		return err
+	}
+
+	maxReceiveCount, err := getMaxReceiveCount(ctx, l.SqsClient, queueUrl)
+
+	if err != nil {
+		return err
+	}
+
+	if maxReceiveCount > 0 {
+		log.Printf(
+			"WARNING: queue %s moves messages to a dead-letter queue after %d receives. Peeking counts as a receive so messages its consumer doesn't delete will be moved sooner",
+			queueUrl,
+			maxReceiveCount,
+		)
+	}
+
+	l.queueUrl = queueUrl
+	l.TopicArn = attributes["TopicArn"]
+	l.RawMessageDelivery = attributes["RawMessageDelivery"] == "true"
+
+	logger.Printf("Peeking at messages in queue %s without deleting them", queueUrl)
+
+	return nil
+}
+
+// checkExistingSubscriptionOptions returns an error if any options have been provided that an existing subscription would ignore.
+func (l *Listener) checkExistingSubscriptionOptions() error {
+	conflicts := []string{}
+
+	if l.QueueName != "" {
+		conflicts = append(conflicts, "a queue name")
+	}
+
+	if l.ExistingQueue != "" {
+		conflicts = append(conflicts, "an existing queue")
+	}
+
+	if len(l.AdditionalTopicArns) > 0 {
+		conflicts = append(conflicts, "additional topics")
+	}
+
+	if l.MaxReceiveCount > 0 {
+		conflicts = append(conflicts, "a dead-letter queue")
+	}
+
+	if l.FilterPolicy != "" {
+		conflicts = append(conflicts, "a filter policy")
+	}
+
+	if l.RawMessageDelivery {
+		conflicts = append(conflicts, "raw message delivery")
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("existing subscription %s can't be used with %s", l.ExistingSubscription, strings.Join(conflicts, ", "))
+	}
+
+	return nil
+}
+
+// createQueues creates the queue, and the dead-letter queue if there is one, keeping track of them for Teardown.
+func (l *Listener) createQueues(ctx context.Context) error {
+	queueName := l.QueueName
+	redrivePolicy := ""
+
+	settings, err := getTopicsSettings(ctx, l.snsClient(), l.topicArns())
+
+	if err != nil {
+		return err
+	}
+
+	if l.MaxReceiveCount > 0 {
+		// The dead-letter queue is named after the queue so the name needs to be decided up front
+		queueName = defaultQueueName(queueName)
+
+		deadLetterQueueUrl, err := createDeadLetterQueue(ctx, l.SqsClient, queueName, settings)
+
+		if err != nil {
+			return err
+		}
+
+		l.deadLetterQueueUrl = deadLetterQueueUrl
+
+		deadLetterQueueArn, err := getQueueArn(ctx, l.SqsClient, deadLetterQueueUrl)
+
+		if err != nil {
+			return l.removeDeadLetterQueue(ctx, err)
+		}
+
+		redrivePolicy = newRedrivePolicy(deadLetterQueueArn, l.MaxReceiveCount)
+	}
+
+	queueUrl, err := createQueue(ctx, l.SqsClient, queueName, l.topicArns(), settings, redrivePolicy)
+
+	if err != nil {
+		if l.deadLetterQueueUrl != "" {
+			return l.removeDeadLetterQueue(ctx, err)
+		}
+
+		return err
+	}
+
+	l.queueUrl = queueUrl
+
+	return nil
+}
+
+// removeDeadLetterQueue deletes the dead-letter queue when the queue couldn't be created, since Teardown won't know about it.
+func (l *Listener) removeDeadLetterQueue(ctx context.Context, err error) error {
+	logger.Printf("Unable to create queue, deleting dead-letter queue %s", l.deadLetterQueueUrl)
+
+	err = errors.Join(err, deleteQueue(ctx, l.SqsClient, l.deadLetterQueueUrl))
+	l.deadLetterQueueUrl = ""
+
+	return err
+}
+
+func (l *Listener) subscriptionAttributes() map[string]string {
+	attributes := map[string]string{}
+
+	if l.FilterPolicy != "" {
+		attributes["FilterPolicy"] = l.FilterPolicy
+
+		if l.FilterPolicyScope != "" {
+			attributes["FilterPolicyScope"] = string(l.FilterPolicyScope)
+		}
+	}
+
+	if l.RawMessageDelivery {
+		attributes["RawMessageDelivery"] = "true"
+	}
+
+	return attributes
+}
+
 // Listen is a blocking function that processes messages from the SQS queue as they arrive.
+// Messages are received in batches of up to BatchSize and the next batch is received straight away
+// unless the queue was empty, in which case Listen waits for PollingInterval first.
+// With a Concurrency above 1 the Consumer's OnMessage method will be called from multiple goroutines at once.
 // Listen will block until the context provided to it is cancelled.
-// Messages will be passed to the provided Consumer's OnMessage method then deleted from the queue.
+// Messages are deleted from the queue and then passed to the provided Consumer's OnMessage method.
+// Use ListenWithHandler if messages should only be deleted once they've been processed.
 // Do not pass the same context as provided to Teardown otherwise resources will not be destroyed.
 func (l *Listener) Listen(ctx context.Context, c Consumer) error {
+	return l.listen(ctx, consumerHandler{consumer: c}, true)
+}
+
+// ListenWithHandler behaves like Listen except that messages are passed to the provided Handler's HandleMessage
+// method first and only deleted from the queue if it returns nil. Messages that aren't handled successfully are
+// received again once their visibility timeout expires, or sooner if the Handler returns an error created with Retry.
+// The visibility timeout of a message is extended for as long as the Handler is still running.
+// When a message from a FIFO message group isn't handled successfully, the rest of that group's messages received
+// alongside it are made visible again without being handled so that the group is received again in order.
+func (l *Listener) ListenWithHandler(ctx context.Context, h Handler) error {
+	return l.listen(ctx, h, false)
+}
+
+func (l *Listener) listen(ctx context.Context, h Handler, deleteBeforeHandling bool) error {
 	// This function deliberately doesn't create a span because it's a shim around listenToQueue.
 	// listenToQueue is a blocking function so any span created here will last the life of the method call.
 
+	// Raw messages don't say which topic they came from, which only matters when there's more than one
+	topicArn := ""
+
+	if topicArns := l.topicArns(); len(topicArns) == 1 {
+		topicArn = topicArns[0]
+	}
+
+	// Subscriptions that belong to someone else are never confirmed by the Listener
+	var confirmer *subscriptionConfirmer
+
+	if l.ExistingSubscription == "" {
+		confirmer = newSubscriptionConfirmer(l.snsClient(), l.topicArns())
+	}
+
 	err := listenToQueue(
 		ctx,
 		l.SqsClient,
 		l.queueUrl,
-		c,
-		l.PollingInterval,
+		h,
+		receiveOptions{
+			pollingInterval:      l.PollingInterval,
+			batchSize:            int32(l.BatchSize),
+			waitTime:             l.WaitTime,
+			concurrency:          l.Concurrency,
+			visibilityTimeout:    l.VisibilityTimeout,
+			rawMessageDelivery:   l.RawMessageDelivery,
+			topicArn:             topicArn,
+			deleteBeforeHandling: deleteBeforeHandling,
+			peek:                 l.ExistingSubscription != "",
+			seen:                 newSeenMessages(10000),
+			confirmer:            confirmer,
+		},
 	)
 
 	if err != nil {
@@ -153,16 +657,36 @@ func (l *Listener) Listen(ctx context.Context, c Consumer) error {
 	return nil
 }
 
-// Teardown unsubscribes the queue from the topic and then deletes the queue.
+// Teardown unsubscribes the queue from the topics and then deletes the queue and the dead-letter queue if there is one.
+// Existing queues are never deleted and are only unsubscribed if Setup subscribed them.
+// Nothing is done for existing subscriptions.
 // It will attempt to do both regardless of the existing state of the infrastructure.
 func (l *Listener) Teardown(ctx context.Context) error {
 	ctx, span := otel.Tracer(name).Start(ctx, "Listener.Teardown")
 	defer span.End()
 
-	err := errors.Join(
-		unsubscribeFromTopic(ctx, l.SnsClient, l.subscriptionArn),
-		deleteQueue(ctx, l.SqsClient, l.queueUrl),
-	)
+	var err error
+
+	if l.ExistingSubscription != "" {
+		logger.Printf("Leaving existing subscription %s and its queue in place", l.ExistingSubscription)
+
+		span.SetStatus(codes.Ok, "")
+		return nil
+	}
+
+	for _, subscriptionArn := range l.subscriptionArns {
+		err = errors.Join(err, unsubscribeFromTopic(ctx, l.snsClient(), subscriptionArn))
+	}
+
+	if l.ExistingQueue != "" {
+		logger.Printf("Leaving existing queue %s in place", l.ExistingQueue)
+	} else {
+		err = errors.Join(err, deleteQueue(ctx, l.SqsClient, l.queueUrl))
+
+		if l.deadLetterQueueUrl != "" {
+			err = errors.Join(err, deleteQueue(ctx, l.SqsClient, l.deadLetterQueueUrl))
+		}
+	}
 
 	if err != nil {
 		span.RecordError(err)
@@ -0,0 +1,306 @@
+package listener
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// DeletingSQSAPIImpl records the URLs of the queues it's asked to delete.
+type DeletingSQSAPIImpl struct {
+	SQSAPIImpl
+
+	deleted []string
+}
+
+func (c *DeletingSQSAPIImpl) DeleteQueue(ctx context.Context,
+	params *sqs.DeleteQueueInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	c.deleted = append(c.deleted, *params.QueueUrl)
+
+	return c.SQSAPIImpl.DeleteQueue(ctx, params, optFns...)
+}
+
+func TestSetupAndTeardown(t *testing.T) {
+	tests := map[string]struct {
+		shouldErrOnSetup    bool
+		shouldErrOnTeardown bool
+		topicArn            string
+		opts                []Option
+	}{
+		"created queue": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue")},
+		},
+		"created queue fails teardown": {
+			false,
+			true,
+			"arn:aws:sns:us-east-1:123456789012:breaks-on-teardown",
+			[]Option{WithQueueName("breaks-on-teardown")},
+		},
+		"created queue with multiple topics": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:other-valid-topic", "arn:aws:sns:us-east-1:123456789012:valid-topic")},
+		},
+		"created queue with multiple topics fails teardown": {
+			false,
+			true,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:breaks-on-teardown")},
+		},
+		"created queue with a topic that can't be subscribed": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:invalid-topic")},
+		},
+		"existing queue URL": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:invalid-topic",
+			[]Option{WithExistingQueue("https://sqs.us-east-1.amazonaws.com/123456789012/breaks-on-teardown", false)},
+		},
+		"existing queue ARN with subscription": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithExistingQueue("arn:aws:sqs:us-east-1:123456789012:breaks-on-teardown", true)},
+		},
+		"existing queue that can't be subscribed": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:invalid-topic",
+			[]Option{WithExistingQueue("arn:aws:sqs:us-east-1:123456789012:valid-queue", true)},
+		},
+		"existing queue that doesn't exist": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithExistingQueue("arn:aws:sqs:us-east-1:123456789012:invalid-queue", false)},
+		},
+		"existing subscription": {
+			false,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn")},
+		},
+		"existing subscription with its topic": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithExistingSubscription("valid:arn")},
+		},
+		"existing subscription with a different topic": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:breaks-on-teardown",
+			[]Option{WithExistingSubscription("valid:arn")},
+		},
+		"existing subscription with additional topics": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:valid-topic")},
+		},
+		"existing subscription with a queue name": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn"), WithQueueName("valid-queue")},
+		},
+		"existing subscription with an existing queue": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn"), WithExistingQueue("arn:aws:sqs:us-east-1:123456789012:valid-queue", false)},
+		},
+		"existing subscription with a dead-letter queue": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn"), WithDeadLetterQueue(5)},
+		},
+		"existing subscription with a filter policy": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn"), WithFilterPolicy(`{"colour": ["blue"]}`, "")},
+		},
+		"existing subscription with raw message delivery": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("valid:arn"), WithRawMessageDelivery(true)},
+		},
+		"existing subscription that isn't to a queue": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("email:arn")},
+		},
+		"existing subscription that doesn't exist": {
+			true,
+			false,
+			"",
+			[]Option{WithExistingSubscription("invalid:arn")},
+		},
+		"created queue with dead-letter queue": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithDeadLetterQueue(5)},
+		},
+		"created queue with a topic in another account": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:210987654321:other-account-topic",
+			[]Option{WithQueueName("valid-queue")},
+		},
+		"invalid topic ARN": {
+			true,
+			false,
+			"valid-topic",
+			[]Option{WithQueueName("valid-queue")},
+		},
+		"invalid additional topic ARN": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:valid-topic:subscription-id")},
+		},
+		"preflight": {
+			false,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:other-valid-topic"), WithPreflight(true)},
+		},
+		"preflight with a topic that doesn't exist": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithAdditionalTopics("arn:aws:sns:us-east-1:123456789012:invalid-topic"), WithPreflight(true)},
+		},
+		"invalid filter policy": {
+			true,
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			[]Option{WithQueueName("valid-queue"), WithFilterPolicy(`{"colour": "blue"}`, "")},
+		},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := New(test.topicArn, &SNSAPIImpl{}, &SQSAPIImpl{}, test.opts...)
+
+			err := l.Setup(ctx)
+
+			if err != nil && !test.shouldErrOnSetup {
+				t.Fatalf(
+					"Expected no error on setup but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErrOnSetup {
+				t.Fatal("Expected error on setup but got no error")
+			}
+
+			if err != nil {
+				return
+			}
+
+			err = l.Teardown(ctx)
+
+			if err != nil && !test.shouldErrOnTeardown {
+				t.Fatalf(
+					"Expected no error on teardown but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErrOnTeardown {
+				t.Fatal("Expected error on teardown but got no error")
+			}
+		})
+	}
+}
+
+func TestDeadLetterQueueRemoved(t *testing.T) {
+	tests := map[string]struct {
+		shouldErrOnSetup bool
+		queueName        string
+		expectedDeleted  []string
+	}{
+		"queue created": {
+			false,
+			"valid-queue",
+			[]string{
+				"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+				"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue-dlq",
+			},
+		},
+		"dead-letter queue ARN not found": {
+			true,
+			"missing-queue",
+			[]string{"https://sqs.us-east-1.amazonaws.com/123456789012/missing-queue-dlq"},
+		},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &DeletingSQSAPIImpl{}
+			l := New("arn:aws:sns:us-east-1:123456789012:valid-topic", &SNSAPIImpl{}, client, WithQueueName(test.queueName), WithDeadLetterQueue(5))
+
+			err := l.Setup(ctx)
+
+			if err != nil && !test.shouldErrOnSetup {
+				t.Fatalf(
+					"Expected no error on setup but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErrOnSetup {
+				t.Fatal("Expected error on setup but got no error")
+			}
+
+			if err == nil {
+				err = l.Teardown(ctx)
+
+				if err != nil {
+					t.Fatalf(
+						"Expected no error on teardown but got %s",
+						err.Error(),
+					)
+				}
+			}
+
+			if len(client.deleted) != len(test.expectedDeleted) {
+				t.Fatalf(
+					"Expected queues %v to be deleted but got %v",
+					test.expectedDeleted,
+					client.deleted,
+				)
+			}
+
+			for i, queueUrl := range test.expectedDeleted {
+				if client.deleted[i] != queueUrl {
+					t.Fatalf(
+						"Expected queues %v to be deleted but got %v",
+						test.expectedDeleted,
+						client.deleted,
+					)
+				}
+			}
+		})
+	}
+}
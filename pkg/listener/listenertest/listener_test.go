@@ -0,0 +1,190 @@
+package listenertest_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/listenertest"
+)
+
+// collector is a Handler that records messages, failing the first attempt at any message listed in failOnce.
+type collector struct {
+	mu       sync.Mutex
+	messages []string
+	failOnce map[string]bool
+	done     chan struct{}
+	expected int
+}
+
+func (c *collector) HandleMessage(ctx context.Context, msg listener.MessageContent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	message := aws.ToString(msg.Message)
+
+	if c.failOnce[message] {
+		delete(c.failOnce, message)
+		return listener.Retry(errors.New("not yet"), 0)
+	}
+
+	c.messages = append(c.messages, message)
+
+	if len(c.messages) == c.expected {
+		close(c.done)
+	}
+
+	return nil
+}
+
+func TestListener(t *testing.T) {
+	tests := map[string]struct {
+		topicName      string
+		topicAttribute map[string]string
+		options        []listener.Option
+		published      []string
+		failOnce       map[string]bool
+		expected       []string
+	}{
+		"standard topic": {
+			"valid-topic",
+			map[string]string{},
+			[]listener.Option{},
+			[]string{"first", "second"},
+			map[string]bool{},
+			[]string{"first", "second"},
+		},
+		"filter policy": {
+			"valid-topic",
+			map[string]string{},
+			[]listener.Option{listener.WithFilterPolicy(`{"colour": ["blue"]}`, filter.ScopeMessageAttributes)},
+			[]string{"red", "blue"},
+			map[string]bool{},
+			[]string{"blue"},
+		},
+		"raw message delivery": {
+			"valid-topic",
+			map[string]string{},
+			[]listener.Option{listener.WithRawMessageDelivery(true)},
+			[]string{"first"},
+			map[string]bool{},
+			[]string{"first"},
+		},
+		"fifo topic with a retried message": {
+			"valid-topic.fifo",
+			map[string]string{"FifoTopic": "true", "ContentBasedDeduplication": "true"},
+			[]listener.Option{listener.WithBatchSize(10)},
+			[]string{"first", "second", "third"},
+			map[string]bool{"first": true},
+			[]string{"first", "second", "third"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := listenertest.New()
+			ctx := context.TODO()
+
+			topic, err := f.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String(test.topicName), Attributes: test.topicAttribute})
+
+			if err != nil {
+				t.Fatalf("Expected no error creating topic but got %s", err.Error())
+			}
+
+			l := listener.New(*topic.TopicArn, f, f, append(test.options, listener.WithPollingInterval(10*time.Millisecond))...)
+
+			err = l.Setup(ctx)
+
+			if err != nil {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			for _, message := range test.published {
+				params := &sns.PublishInput{
+					TopicArn: topic.TopicArn,
+					Message:  aws.String(message),
+					MessageAttributes: map[string]snsTypes.MessageAttributeValue{
+						"colour": {DataType: aws.String("String"), StringValue: aws.String(message)},
+					},
+				}
+
+				if test.topicAttribute["FifoTopic"] == "true" {
+					params.MessageGroupId = aws.String("group")
+				}
+
+				_, err := f.Publish(ctx, params)
+
+				if err != nil {
+					t.Fatalf("Expected no error publishing but got %s", err.Error())
+				}
+			}
+
+			c := &collector{failOnce: test.failOnce, done: make(chan struct{}), expected: len(test.expected)}
+			listenCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+
+			go func() {
+				<-c.done
+				cancel()
+			}()
+
+			_ = l.ListenWithHandler(listenCtx, c)
+			cancel()
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if len(c.messages) != len(test.expected) {
+				t.Fatalf("Received messages %v did not match expected values %v", c.messages, test.expected)
+			}
+
+			for i := range c.messages {
+				if c.messages[i] != test.expected[i] {
+					t.Fatalf("Received messages %v did not match expected values %v", c.messages, test.expected)
+				}
+			}
+
+			err = l.Teardown(ctx)
+
+			if err != nil {
+				t.Fatalf("Expected no error tearing down but got %s", err.Error())
+			}
+
+			if len(f.Queues()) != 0 || len(f.Subscriptions()) != 0 {
+				t.Fatalf("Expected Teardown to remove everything but found queues %v and subscriptions %v", f.Queues(), f.Subscriptions())
+			}
+		})
+	}
+}
+
+func TestListenerSetupFault(t *testing.T) {
+	f := listenertest.New()
+	ctx := context.TODO()
+
+	topic, _ := f.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String("valid-topic")})
+	f.InjectFault("Subscribe", 1, errors.New("Access denied"))
+
+	l := listener.New(*topic.TopicArn, f, f)
+
+	err := l.Setup(ctx)
+
+	if err == nil {
+		t.Fatal("Expected error but got no error")
+	}
+
+	err = l.Teardown(ctx)
+
+	if err != nil {
+		t.Fatalf("Expected no error tearing down but got %s", err.Error())
+	}
+
+	if len(f.Queues()) != 0 {
+		t.Fatalf("Expected Teardown to delete the queue but found %v", f.Queues())
+	}
+}
@@ -0,0 +1,204 @@
+// Package listenertest provides an in-memory implementation of the SNS and SQS APIs used by the listener package,
+// for testing code built on a Listener without AWS.
+package listenertest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+)
+
+// A Fake is an in-memory SNS and SQS. It satisfies both listener.SNSAPI and listener.SQSAPI so the same Fake
+// can be provided as both clients. Topics fan out messages published to them to the queues subscribed to them,
+// applying filter policies and raw message delivery. Queues hide received messages until their visibility timeout
+// expires, count receives, move messages to a dead-letter queue after their redrive policy's max receive count,
+// keep messages in the same FIFO message group in order and deduplicate FIFO messages.
+// Queue policies aren't enforced.
+// It should not be instantiated directly, instead the New() function should be used.
+type Fake struct {
+	// Region is the region of topics and queues created by the Fake
+	Region string
+	// AccountID is the account that owns topics and queues created by the Fake
+	AccountID string
+	// QueueBaseURL is the start of the URL of every queue, which is followed by the account and the queue's name.
+	// Defaults to the regional SQS endpoint
+	QueueBaseURL string
+	// Clock returns the current time. It's used for visibility timeouts, delays and deduplication but long polling
+	// always waits in real time
+	Clock func() time.Time
+
+	mu            sync.Mutex
+	topics        map[string]*topic
+	subscriptions map[string]*subscription
+	queues        map[string]*queue
+	faults        map[string]*fault
+	sequence      uint64
+	// changed is closed and replaced whenever messages might have become available to receive
+	changed chan struct{}
+}
+
+// An Option is a function that changes the configuration of a Fake.
+type Option func(*Fake)
+
+// WithRegion sets the region of topics and queues created by the Fake.
+// Defaults to us-east-1.
+func WithRegion(region string) Option {
+	return func(f *Fake) {
+		f.Region = region
+	}
+}
+
+// WithAccountID sets the account that owns topics and queues created by the Fake.
+// Defaults to 123456789012.
+func WithAccountID(accountId string) Option {
+	return func(f *Fake) {
+		f.AccountID = accountId
+	}
+}
+
+// WithQueueBaseURL sets the start of the URL of every queue, e.g. "http://localhost:4566" for queues that are
+// served by an emulator. It's followed by the account and the queue's name.
+// Defaults to the regional SQS endpoint.
+func WithQueueBaseURL(queueBaseUrl string) Option {
+	return func(f *Fake) {
+		f.QueueBaseURL = strings.TrimSuffix(queueBaseUrl, "/")
+	}
+}
+
+// WithClock sets the function the Fake uses to tell the time, so that tests can control when visibility timeouts,
+// delays and deduplication windows expire.
+// Defaults to time.Now.
+func WithClock(clock func() time.Time) Option {
+	return func(f *Fake) {
+		f.Clock = clock
+	}
+}
+
+// New returns an empty Fake.
+func New(opts ...Option) *Fake {
+	f := new(Fake)
+
+	f.Region = "us-east-1"
+	f.AccountID = "123456789012"
+	f.Clock = time.Now
+	f.topics = map[string]*topic{}
+	f.subscriptions = map[string]*subscription{}
+	f.queues = map[string]*queue{}
+	f.faults = map[string]*fault{}
+	f.changed = make(chan struct{})
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+type fault struct {
+	remaining int
+	err       error
+}
+
+// InjectFault makes the next n calls to the named operation return err without doing anything else.
+// Operations are named after the method, e.g. "ReceiveMessage". A negative n makes every call fail until the
+// fault is cleared with ClearFaults. Injecting a fault for an operation replaces any fault already injected for it.
+func (f *Fake) InjectFault(operation string, n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults[operation] = &fault{n, err}
+}
+
+// ClearFaults removes every injected fault.
+func (f *Fake) ClearFaults() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = map[string]*fault{}
+}
+
+// fault returns the error injected for the operation, if there is one. It must be called with the lock held.
+func (f *Fake) fault(operation string) error {
+	injected, ok := f.faults[operation]
+
+	if !ok {
+		return nil
+	}
+
+	if injected.remaining > 0 {
+		injected.remaining--
+
+		if injected.remaining == 0 {
+			delete(f.faults, operation)
+		}
+	}
+
+	return injected.err
+}
+
+// Topics returns the ARNs of every topic in the Fake, in order.
+func (f *Fake) Topics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return sortedKeys(f.topics)
+}
+
+// Subscriptions returns the ARNs of every subscription in the Fake, including subscriptions pending confirmation, in order.
+func (f *Fake) Subscriptions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return sortedKeys(f.subscriptions)
+}
+
+// Queues returns the URLs of every queue in the Fake, in order.
+func (f *Fake) Queues() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return sortedKeys(f.queues)
+}
+
+// notify wakes any receives waiting for messages. It must be called with the lock held.
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// nextSequenceNumber returns a sequence number for a FIFO message. It must be called with the lock held.
+func (f *Fake) nextSequenceNumber() string {
+	f.sequence++
+
+	return fmt.Sprintf("%020d", f.sequence)
+}
+
+func newId() string {
+	return uuid.NewString()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// invalidParameterValue returns the error SQS responds with when a request has a bad value.
+func invalidParameterValue(format string, a ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "InvalidParameterValue",
+		Message: fmt.Sprintf(format, a...),
+		Fault:   smithy.FaultClient,
+	}
+}
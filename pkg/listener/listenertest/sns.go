@@ -0,0 +1,659 @@
+package listenertest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/filter"
+)
+
+var topicNamePattern = regexp.MustCompile(`^([A-Za-z0-9_\-]{1,256}|[A-Za-z0-9_\-]{1,251}\.fifo)$`)
+
+type topic struct {
+	arn        string
+	attributes map[string]string
+	// deduplicated is when each message deduplication ID was last accepted and the ID of the message it was for
+	deduplicated map[string]deduplicatedMessage
+}
+
+type subscription struct {
+	arn                 string
+	topicArn            string
+	queueArn            string
+	attributes          map[string]string
+	policy              *filter.Policy
+	token               string
+	pendingConfirmation bool
+}
+
+func (t *topic) isFIFO() bool {
+	return t.attributes["FifoTopic"] == "true"
+}
+
+// CreateTopic creates a topic in the Fake's region and account, or returns the ARN of the existing topic with the
+// same name. FIFO topics need the FifoTopic attribute and a name ending in ".fifo".
+func (f *Fake) CreateTopic(ctx context.Context,
+	params *sns.CreateTopicInput,
+	optFns ...func(*sns.Options)) (*sns.CreateTopicOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateTopic"); err != nil {
+		return nil, err
+	}
+
+	topicArn := fmt.Sprintf("arn:aws:sns:%s:%s:%s", f.Region, f.AccountID, aws.ToString(params.Name))
+
+	err := f.addTopic(topicArn, params.Attributes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sns.CreateTopicOutput{TopicArn: aws.String(topicArn)}, nil
+}
+
+// AddTopic adds a topic with any ARN, so that topics in other regions and accounts can be listened to.
+func (f *Fake) AddTopic(topicArn string, attributes map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.addTopic(topicArn, attributes)
+}
+
+// addTopic adds a topic unless it already exists. It must be called with the lock held.
+func (f *Fake) addTopic(topicArn string, attributes map[string]string) error {
+	parsed, err := arn.Parse(topicArn)
+
+	if err != nil || parsed.Service != "sns" || !topicNamePattern.MatchString(parsed.Resource) {
+		return invalidParameter("Invalid parameter: Topic Name")
+	}
+
+	isFIFO := attributes["FifoTopic"] == "true"
+
+	if isFIFO != strings.HasSuffix(parsed.Resource, ".fifo") {
+		return invalidParameter("Invalid parameter: Fifo Topic names must end with .fifo and must be made up of only uppercase and lowercase ASCII letters, numbers, underscores, and hyphens, and must be between 1 and 256 characters long.")
+	}
+
+	if attributes["ContentBasedDeduplication"] == "true" && !isFIFO {
+		return invalidParameter("Invalid parameter: Attributes Reason: ContentBasedDeduplication is only valid for FIFO topics")
+	}
+
+	if _, ok := f.topics[topicArn]; ok {
+		return nil
+	}
+
+	t := &topic{
+		arn:          topicArn,
+		attributes:   map[string]string{},
+		deduplicated: map[string]deduplicatedMessage{},
+	}
+
+	for k, v := range attributes {
+		t.attributes[k] = v
+	}
+
+	f.topics[topicArn] = t
+
+	return nil
+}
+
+// DeleteTopic deletes a topic and every subscription to it.
+func (f *Fake) DeleteTopic(ctx context.Context,
+	params *sns.DeleteTopicInput,
+	optFns ...func(*sns.Options)) (*sns.DeleteTopicOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteTopic"); err != nil {
+		return nil, err
+	}
+
+	t, err := f.topic(params.TopicArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for subscriptionArn, s := range f.subscriptions {
+		if s.topicArn == t.arn {
+			delete(f.subscriptions, subscriptionArn)
+		}
+	}
+
+	delete(f.topics, t.arn)
+
+	return &sns.DeleteTopicOutput{}, nil
+}
+
+// ListTopics lists every topic in the Fake in a single page.
+func (f *Fake) ListTopics(ctx context.Context,
+	params *sns.ListTopicsInput,
+	optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ListTopics"); err != nil {
+		return nil, err
+	}
+
+	topics := []snsTypes.Topic{}
+
+	for _, topicArn := range sortedKeys(f.topics) {
+		topics = append(topics, snsTypes.Topic{TopicArn: aws.String(topicArn)})
+	}
+
+	return &sns.ListTopicsOutput{Topics: topics}, nil
+}
+
+// GetTopicAttributes returns the attributes the topic was created with alongside its ARN, owner and subscription counts.
+func (f *Fake) GetTopicAttributes(ctx context.Context,
+	params *sns.GetTopicAttributesInput,
+	optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("GetTopicAttributes"); err != nil {
+		return nil, err
+	}
+
+	t, err := f.topic(params.TopicArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, _ := arn.Parse(t.arn)
+	confirmed, pending := 0, 0
+
+	for _, s := range f.subscriptions {
+		if s.topicArn != t.arn {
+			continue
+		}
+
+		if s.pendingConfirmation {
+			pending++
+		} else {
+			confirmed++
+		}
+	}
+
+	attributes := map[string]string{
+		"TopicArn":                t.arn,
+		"Owner":                   parsed.AccountID,
+		"DisplayName":             "",
+		"SubscriptionsConfirmed":  fmt.Sprint(confirmed),
+		"SubscriptionsPending":    fmt.Sprint(pending),
+		"SubscriptionsDeleted":    "0",
+		"EffectiveDeliveryPolicy": `{"http":{"defaultHealthyRetryPolicy":{"minDelayTarget":20,"maxDelayTarget":20,"numRetries":3}}}`,
+	}
+
+	for k, v := range t.attributes {
+		attributes[k] = v
+	}
+
+	return &sns.GetTopicAttributesOutput{Attributes: attributes}, nil
+}
+
+// Subscribe subscribes an SQS queue to a topic. Only the sqs protocol is supported and FIFO topics can only be
+// subscribed to by FIFO queues. The filter policy is validated and the subscription is created straight away unless
+// the queue is in a different account to the topic, in which case it's pending until the confirmation message sent
+// to the queue is used to confirm it.
+func (f *Fake) Subscribe(ctx context.Context,
+	params *sns.SubscribeInput,
+	optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("Subscribe"); err != nil {
+		return nil, err
+	}
+
+	t, err := f.topic(params.TopicArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(params.Protocol) != "sqs" {
+		return nil, invalidParameter("Invalid parameter: Protocol %s isn't supported", aws.ToString(params.Protocol))
+	}
+
+	queueArn := aws.ToString(params.Endpoint)
+	parsedQueueArn, err := arn.Parse(queueArn)
+
+	if err != nil || parsedQueueArn.Service != "sqs" {
+		return nil, invalidParameter("Invalid parameter: SQS endpoint ARN")
+	}
+
+	if t.isFIFO() != strings.HasSuffix(queueArn, ".fifo") {
+		return nil, invalidParameter("Invalid parameter: Invalid parameter: Endpoint Reason: FIFO SQS Queues can only be subscribed to FIFO SNS Topics and vice versa")
+	}
+
+	var policy *filter.Policy
+
+	if filterPolicy, ok := params.Attributes["FilterPolicy"]; ok {
+		policy, err = filter.Parse(filterPolicy, filter.Scope(params.Attributes["FilterPolicyScope"]))
+
+		if err != nil {
+			return nil, invalidParameter("Invalid parameter: FilterPolicy: %s", err.Error())
+		}
+	}
+
+	for _, s := range f.subscriptions {
+		if s.topicArn == t.arn && s.queueArn == queueArn {
+			return &sns.SubscribeOutput{SubscriptionArn: aws.String(s.arnFor(params.ReturnSubscriptionArn))}, nil
+		}
+	}
+
+	parsedTopicArn, _ := arn.Parse(t.arn)
+
+	s := &subscription{
+		arn:        t.arn + ":" + newId(),
+		topicArn:   t.arn,
+		queueArn:   queueArn,
+		attributes: map[string]string{},
+		policy:     policy,
+	}
+
+	for k, v := range params.Attributes {
+		s.attributes[k] = v
+	}
+
+	f.subscriptions[s.arn] = s
+
+	if parsedTopicArn.AccountID != parsedQueueArn.AccountID {
+		s.pendingConfirmation = true
+		s.token = newId()
+
+		f.sendConfirmation(t, s)
+	}
+
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String(s.arnFor(params.ReturnSubscriptionArn))}, nil
+}
+
+// arnFor returns what Subscribe responds with for the subscription.
+func (s *subscription) arnFor(returnSubscriptionArn bool) string {
+	if s.pendingConfirmation && !returnSubscriptionArn {
+		return "pending confirmation"
+	}
+
+	return s.arn
+}
+
+// sendConfirmation sends the message asking for the subscription to be confirmed to its queue.
+// It must be called with the lock held.
+func (f *Fake) sendConfirmation(t *topic, s *subscription) {
+	q, ok := f.queueByArn(s.queueArn)
+
+	if !ok {
+		return
+	}
+
+	parsed, _ := arn.Parse(t.arn)
+
+	envelope := notification{
+		Type:             "SubscriptionConfirmation",
+		MessageId:        newId(),
+		Token:            s.token,
+		TopicArn:         t.arn,
+		Message:          fmt.Sprintf("You have chosen to subscribe to the topic %s.\nTo confirm the subscription, visit the SubscribeURL included in this message.", t.arn),
+		SubscribeURL:     fmt.Sprintf("https://sns.%s.amazonaws.com/?Action=ConfirmSubscription&TopicArn=%s&Token=%s", parsed.Region, t.arn, s.token),
+		Timestamp:        f.Clock().UTC().Format(timestampFormat),
+		SignatureVersion: "1",
+		Signature:        "EXAMPLE",
+		SigningCertURL:   fmt.Sprintf("https://sns.%s.amazonaws.com/SimpleNotificationService-0000000000000000000000.pem", parsed.Region),
+	}
+
+	body, _ := json.Marshal(envelope)
+	groupId, deduplicationId := "", ""
+
+	if q.isFIFO() {
+		groupId, deduplicationId = s.arn, envelope.MessageId
+	}
+
+	_, _ = f.enqueue(q, string(body), nil, groupId, deduplicationId, 0)
+}
+
+// ConfirmSubscription confirms a pending subscription with the token from its confirmation message.
+func (f *Fake) ConfirmSubscription(ctx context.Context,
+	params *sns.ConfirmSubscriptionInput,
+	optFns ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ConfirmSubscription"); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.topic(params.TopicArn); err != nil {
+		return nil, err
+	}
+
+	for _, s := range f.subscriptions {
+		if s.topicArn == aws.ToString(params.TopicArn) && s.token != "" && s.token == aws.ToString(params.Token) {
+			s.pendingConfirmation = false
+
+			return &sns.ConfirmSubscriptionOutput{SubscriptionArn: aws.String(s.arn)}, nil
+		}
+	}
+
+	return nil, invalidParameter("Invalid token")
+}
+
+// Unsubscribe deletes a subscription.
+func (f *Fake) Unsubscribe(ctx context.Context,
+	params *sns.UnsubscribeInput,
+	optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("Unsubscribe"); err != nil {
+		return nil, err
+	}
+
+	s, err := f.subscription(params.SubscriptionArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delete(f.subscriptions, s.arn)
+
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+// GetSubscriptionAttributes returns the attributes the subscription was created with alongside its topic, endpoint
+// and whether it's pending confirmation.
+func (f *Fake) GetSubscriptionAttributes(ctx context.Context,
+	params *sns.GetSubscriptionAttributesInput,
+	optFns ...func(*sns.Options)) (*sns.GetSubscriptionAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("GetSubscriptionAttributes"); err != nil {
+		return nil, err
+	}
+
+	s, err := f.subscription(params.SubscriptionArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, _ := arn.Parse(s.topicArn)
+
+	attributes := map[string]string{
+		"SubscriptionArn":              s.arn,
+		"TopicArn":                     s.topicArn,
+		"Protocol":                     "sqs",
+		"Endpoint":                     s.queueArn,
+		"Owner":                        parsed.AccountID,
+		"PendingConfirmation":          fmt.Sprint(s.pendingConfirmation),
+		"ConfirmationWasAuthenticated": fmt.Sprint(!s.pendingConfirmation),
+		"RawMessageDelivery":           "false",
+	}
+
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+
+	return &sns.GetSubscriptionAttributesOutput{Attributes: attributes}, nil
+}
+
+// Publish publishes a message to a topic, delivering it to every confirmed subscription whose filter policy it
+// matches. Messages published to a FIFO topic need a message group ID and either a message deduplication ID or a
+// topic with content-based deduplication. Duplicates are accepted but aren't delivered again.
+func (f *Fake) Publish(ctx context.Context,
+	params *sns.PublishInput,
+	optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("Publish"); err != nil {
+		return nil, err
+	}
+
+	if params.TopicArn == nil {
+		return nil, invalidParameter("Invalid parameter: TopicArn. Only publishing to topics is supported")
+	}
+
+	t, err := f.topic(params.TopicArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	message := aws.ToString(params.Message)
+
+	if message == "" {
+		return nil, invalidParameter("Invalid parameter: Empty message")
+	}
+
+	if len(aws.ToString(params.Subject)) > 100 {
+		return nil, invalidParameter("Invalid parameter: Subject")
+	}
+
+	messages := map[string]string{"default": message}
+
+	if aws.ToString(params.MessageStructure) == "json" {
+		messages = map[string]string{}
+
+		if err := json.Unmarshal([]byte(message), &messages); err != nil {
+			return nil, invalidParameter("Invalid parameter: Message Structure - JSON message body failed to parse")
+		}
+
+		if _, ok := messages["default"]; !ok {
+			return nil, invalidParameter("Invalid parameter: Message Structure - No default entry in JSON message body")
+		}
+	}
+
+	groupId := aws.ToString(params.MessageGroupId)
+	deduplicationId := aws.ToString(params.MessageDeduplicationId)
+	now := f.Clock()
+	sequenceNumber := ""
+
+	if !t.isFIFO() && (groupId != "" || deduplicationId != "") {
+		return nil, invalidParameter("Invalid parameter: MessageGroupId and MessageDeduplicationId are only valid for FIFO topics")
+	}
+
+	if t.isFIFO() {
+		if groupId == "" {
+			return nil, invalidParameter("Invalid parameter: The MessageGroupId parameter is required for FIFO topics")
+		}
+
+		if deduplicationId == "" && t.attributes["ContentBasedDeduplication"] != "true" {
+			return nil, invalidParameter("Invalid parameter: The topic should either have ContentBasedDeduplication enabled or MessageDeduplicationId provided explicitly")
+		}
+
+		if deduplicationId == "" {
+			deduplicationId = sha256Hex([]byte(message))
+		}
+
+		if previous, ok := t.deduplicated[deduplicationId]; ok && now.Sub(previous.sentAt) < deduplicationInterval {
+			return &sns.PublishOutput{MessageId: aws.String(previous.messageId), SequenceNumber: aws.String(previous.sequenceNumber)}, nil
+		}
+
+		sequenceNumber = f.nextSequenceNumber()
+	}
+
+	published := deduplicatedMessage{newId(), sequenceNumber, now}
+
+	if t.isFIFO() {
+		t.deduplicated[deduplicationId] = published
+	}
+
+	for _, subscriptionArn := range sortedKeys(f.subscriptions) {
+		s := f.subscriptions[subscriptionArn]
+
+		if s.topicArn != t.arn || s.pendingConfirmation {
+			continue
+		}
+
+		sqsMessage, ok := messages["sqs"]
+
+		if !ok {
+			sqsMessage = messages["default"]
+		}
+
+		if !s.matches(sqsMessage, params.MessageAttributes) {
+			continue
+		}
+
+		q, ok := f.queueByArn(s.queueArn)
+
+		// SNS doesn't tell the publisher when a message can't be delivered
+		if !ok {
+			continue
+		}
+
+		body, attributes := s.deliver(t, published, sqsMessage, params)
+
+		_, _ = f.enqueue(q, body, attributes, groupId, deduplicationId, 0)
+	}
+
+	output := &sns.PublishOutput{MessageId: aws.String(published.messageId)}
+
+	if sequenceNumber != "" {
+		output.SequenceNumber = aws.String(sequenceNumber)
+	}
+
+	return output, nil
+}
+
+// matches reports whether the message passes the subscription's filter policy.
+func (s *subscription) matches(message string, messageAttributes map[string]snsTypes.MessageAttributeValue) bool {
+	if s.policy == nil {
+		return true
+	}
+
+	attributes := map[string]filter.Attribute{}
+
+	for k, v := range messageAttributes {
+		attribute := filter.Attribute{Type: aws.ToString(v.DataType), Value: aws.ToString(v.StringValue)}
+
+		if v.BinaryValue != nil {
+			attribute.Value = base64.StdEncoding.EncodeToString(v.BinaryValue)
+		}
+
+		attributes[k] = attribute
+	}
+
+	match, err := s.policy.Match(attributes, message)
+
+	return err == nil && match
+}
+
+// deliver returns the body and message attributes of the SQS message for the published message, which is wrapped
+// in an SNS envelope unless the subscription has raw message delivery enabled.
+func (s *subscription) deliver(t *topic, published deduplicatedMessage, message string, params *sns.PublishInput) (string, map[string]types.MessageAttributeValue) {
+	if s.attributes["RawMessageDelivery"] == "true" {
+		attributes := map[string]types.MessageAttributeValue{}
+
+		for k, v := range params.MessageAttributes {
+			attributes[k] = types.MessageAttributeValue{
+				DataType:    v.DataType,
+				StringValue: v.StringValue,
+				BinaryValue: v.BinaryValue,
+			}
+		}
+
+		return message, attributes
+	}
+
+	parsed, _ := arn.Parse(t.arn)
+
+	envelope := notification{
+		Type:             "Notification",
+		MessageId:        published.messageId,
+		TopicArn:         t.arn,
+		Subject:          aws.ToString(params.Subject),
+		Message:          message,
+		Timestamp:        published.sentAt.UTC().Format(timestampFormat),
+		SequenceNumber:   published.sequenceNumber,
+		SignatureVersion: "1",
+		Signature:        "EXAMPLE",
+		SigningCertURL:   fmt.Sprintf("https://sns.%s.amazonaws.com/SimpleNotificationService-0000000000000000000000.pem", parsed.Region),
+		UnsubscribeURL:   fmt.Sprintf("https://sns.%s.amazonaws.com/?Action=Unsubscribe&SubscriptionArn=%s", parsed.Region, s.arn),
+	}
+
+	if len(params.MessageAttributes) > 0 {
+		envelope.MessageAttributes = map[string]notificationAttribute{}
+
+		for k, v := range params.MessageAttributes {
+			attribute := notificationAttribute{Type: aws.ToString(v.DataType), Value: aws.ToString(v.StringValue)}
+
+			if v.BinaryValue != nil {
+				attribute.Value = base64.StdEncoding.EncodeToString(v.BinaryValue)
+			}
+
+			envelope.MessageAttributes[k] = attribute
+		}
+	}
+
+	body, _ := json.Marshal(envelope)
+
+	return string(body), nil
+}
+
+// timestampFormat is the format of timestamps in SNS envelopes.
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// notification is the SNS envelope, in the same order and leaving out the same empty fields as SNS does.
+type notification struct {
+	Type              string
+	MessageId         string
+	Token             string `json:",omitempty"`
+	TopicArn          string
+	Subject           string `json:",omitempty"`
+	Message           string
+	Timestamp         string
+	SequenceNumber    string `json:",omitempty"`
+	SignatureVersion  string
+	Signature         string
+	SigningCertURL    string
+	SubscribeURL      string                           `json:",omitempty"`
+	UnsubscribeURL    string                           `json:",omitempty"`
+	MessageAttributes map[string]notificationAttribute `json:",omitempty"`
+}
+
+type notificationAttribute struct {
+	Type  string
+	Value string
+}
+
+// topic returns the topic with the provided ARN. It must be called with the lock held.
+func (f *Fake) topic(topicArn *string) (*topic, error) {
+	t, ok := f.topics[aws.ToString(topicArn)]
+
+	if !ok {
+		return nil, &snsTypes.NotFoundException{Message: aws.String("Topic does not exist")}
+	}
+
+	return t, nil
+}
+
+// subscription returns the subscription with the provided ARN. It must be called with the lock held.
+func (f *Fake) subscription(subscriptionArn *string) (*subscription, error) {
+	s, ok := f.subscriptions[aws.ToString(subscriptionArn)]
+
+	if !ok {
+		return nil, &snsTypes.NotFoundException{Message: aws.String("Subscription does not exist")}
+	}
+
+	return s, nil
+}
+
+// invalidParameter returns the error SNS responds with when a request has a bad value.
+func invalidParameter(format string, a ...any) error {
+	return &snsTypes.InvalidParameterException{Message: aws.String(fmt.Sprintf(format, a...))}
+}
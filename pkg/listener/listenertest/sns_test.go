@@ -0,0 +1,356 @@
+package listenertest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+func createTopic(t *testing.T, f *Fake, name string, attributes map[string]string) string {
+	result, err := f.CreateTopic(context.TODO(), &sns.CreateTopicInput{Name: aws.String(name), Attributes: attributes})
+
+	if err != nil {
+		t.Fatalf("Expected no error creating topic but got %s", err.Error())
+	}
+
+	return *result.TopicArn
+}
+
+func subscribe(t *testing.T, f *Fake, topicArn string, queueArn string, attributes map[string]string) string {
+	result, err := f.Subscribe(context.TODO(), &sns.SubscribeInput{
+		TopicArn:              aws.String(topicArn),
+		Protocol:              aws.String("sqs"),
+		Endpoint:              aws.String(queueArn),
+		Attributes:            attributes,
+		ReturnSubscriptionArn: true,
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error subscribing but got %s", err.Error())
+	}
+
+	return *result.SubscriptionArn
+}
+
+func decode(t *testing.T, body string) notification {
+	var n notification
+
+	err := json.Unmarshal([]byte(body), &n)
+
+	if err != nil {
+		t.Fatalf("Expected an SNS envelope but got %s", body)
+	}
+
+	return n
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr  bool
+		topicArn   string
+		protocol   string
+		endpoint   string
+		attributes map[string]string
+	}{
+		"standard topic": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			"sqs",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+			map[string]string{},
+		},
+		"fifo topic": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic.fifo",
+			"sqs",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue.fifo",
+			map[string]string{},
+		},
+		"filter policy": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			"sqs",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+			map[string]string{"FilterPolicy": `{"colour": ["blue"]}`, "FilterPolicyScope": "MessageAttributes"},
+		},
+		"invalid filter policy": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			"sqs",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+			map[string]string{"FilterPolicy": `{"colour": "blue"}`},
+		},
+		"fifo topic and standard queue": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic.fifo",
+			"sqs",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+			map[string]string{},
+		},
+		"unsupported protocol": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:valid-topic",
+			"https",
+			"https://example.com",
+			map[string]string{},
+		},
+		"missing topic": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:invalid-topic",
+			"sqs",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+			map[string]string{},
+		},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := New()
+			createTopic(t, f, "valid-topic", map[string]string{})
+			createTopic(t, f, "valid-topic.fifo", map[string]string{"FifoTopic": "true"})
+
+			result, err := f.Subscribe(ctx, &sns.SubscribeInput{
+				TopicArn:              aws.String(test.topicArn),
+				Protocol:              aws.String(test.protocol),
+				Endpoint:              aws.String(test.endpoint),
+				Attributes:            test.attributes,
+				ReturnSubscriptionArn: true,
+			})
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				attributes, err := f.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{SubscriptionArn: result.SubscriptionArn})
+
+				if err != nil {
+					t.Fatalf("Expected no error getting subscription attributes but got %s", err.Error())
+				}
+
+				if attributes.Attributes["TopicArn"] != test.topicArn || attributes.Attributes["Endpoint"] != test.endpoint {
+					t.Fatalf("Subscription attributes %v did not match the topic and endpoint", attributes.Attributes)
+				}
+			}
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	f := New()
+	ctx := context.TODO()
+	topicArn := createTopic(t, f, "valid-topic", map[string]string{})
+	allQueueUrl := createQueue(t, f, "all-messages", map[string]string{})
+	blueQueueUrl := createQueue(t, f, "blue-messages", map[string]string{})
+	rawQueueUrl := createQueue(t, f, "raw-messages", map[string]string{})
+
+	subscribe(t, f, topicArn, "arn:aws:sqs:us-east-1:123456789012:all-messages", map[string]string{})
+	subscribe(t, f, topicArn, "arn:aws:sqs:us-east-1:123456789012:blue-messages", map[string]string{"FilterPolicy": `{"colour": ["blue"]}`})
+	subscribe(t, f, topicArn, "arn:aws:sqs:us-east-1:123456789012:raw-messages", map[string]string{"RawMessageDelivery": "true"})
+
+	for _, colour := range []string{"red", "blue"} {
+		_, err := f.Publish(ctx, &sns.PublishInput{
+			TopicArn: aws.String(topicArn),
+			Message:  aws.String(colour + " message"),
+			Subject:  aws.String("colours"),
+			MessageAttributes: map[string]snsTypes.MessageAttributeValue{
+				"colour": {DataType: aws.String("String"), StringValue: aws.String(colour)},
+			},
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error publishing but got %s", err.Error())
+		}
+	}
+
+	all := receiveMessages(t, f, allQueueUrl, 10)
+
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 messages without a filter policy but got %d", len(all))
+	}
+
+	envelope := decode(t, *all[0].Body)
+
+	if envelope.Type != "Notification" || envelope.TopicArn != topicArn || envelope.Message != "red message" || envelope.Subject != "colours" {
+		t.Fatalf("Envelope %v did not match the published message", envelope)
+	}
+
+	if envelope.MessageAttributes["colour"].Value != "red" {
+		t.Fatalf("Expected the colour attribute in the envelope but got %v", envelope.MessageAttributes)
+	}
+
+	blue := receiveMessages(t, f, blueQueueUrl, 10)
+
+	if len(blue) != 1 || decode(t, *blue[0].Body).Message != "blue message" {
+		t.Fatalf("Expected only the blue message with a filter policy but got %v", bodies(blue))
+	}
+
+	raw := receiveMessages(t, f, rawQueueUrl, 10)
+
+	if len(raw) != 2 || *raw[0].Body != "red message" || aws.ToString(raw[0].MessageAttributes["colour"].StringValue) != "red" {
+		t.Fatalf("Expected raw messages with message attributes but got %v", bodies(raw))
+	}
+}
+
+func TestPublishMessageStructure(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr       bool
+		message         string
+		expectedMessage string
+	}{
+		"sqs message":     {false, `{"default": "default message", "sqs": "sqs message"}`, "sqs message"},
+		"default message": {false, `{"default": "default message", "email": "email message"}`, "default message"},
+		"no default":      {true, `{"sqs": "sqs message"}`, ""},
+		"not json":        {true, `sqs message`, ""},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := New()
+			topicArn := createTopic(t, f, "valid-topic", map[string]string{})
+			queueUrl := createQueue(t, f, "valid-queue", map[string]string{})
+			subscribe(t, f, topicArn, "arn:aws:sqs:us-east-1:123456789012:valid-queue", map[string]string{"RawMessageDelivery": "true"})
+
+			_, err := f.Publish(ctx, &sns.PublishInput{
+				TopicArn:         aws.String(topicArn),
+				Message:          aws.String(test.message),
+				MessageStructure: aws.String("json"),
+			})
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				messages := receiveMessages(t, f, queueUrl, 10)
+
+				if len(messages) != 1 || *messages[0].Body != test.expectedMessage {
+					t.Fatalf("Messages %v did not match expected value %s", bodies(messages), test.expectedMessage)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishFIFO(t *testing.T) {
+	f := New()
+	ctx := context.TODO()
+	topicArn := createTopic(t, f, "valid-topic.fifo", map[string]string{"FifoTopic": "true", "ContentBasedDeduplication": "true"})
+	queueUrl := createQueue(t, f, "valid-queue.fifo", map[string]string{"FifoQueue": "true"})
+	subscribe(t, f, topicArn, "arn:aws:sqs:us-east-1:123456789012:valid-queue.fifo", map[string]string{})
+
+	_, err := f.Publish(ctx, &sns.PublishInput{TopicArn: aws.String(topicArn), Message: aws.String("no group")})
+
+	if err == nil {
+		t.Fatal("Expected an error publishing without a message group ID but got no error")
+	}
+
+	ids := []string{}
+
+	for _, message := range []string{"first", "first", "second"} {
+		result, err := f.Publish(ctx, &sns.PublishInput{
+			TopicArn:       aws.String(topicArn),
+			Message:        aws.String(message),
+			MessageGroupId: aws.String("group"),
+		})
+
+		if err != nil {
+			t.Fatalf("Expected no error publishing but got %s", err.Error())
+		}
+
+		ids = append(ids, *result.MessageId)
+	}
+
+	if ids[0] != ids[1] || ids[0] == ids[2] {
+		t.Fatalf("Expected the duplicate to have the same message ID but got %v", ids)
+	}
+
+	messages := receiveMessages(t, f, queueUrl, 10)
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages after deduplication but got %d", len(messages))
+	}
+
+	if messages[0].Attributes["MessageGroupId"] != "group" || decode(t, *messages[0].Body).SequenceNumber == "" {
+		t.Fatalf("Expected the message group ID and a sequence number but got %v", messages[0].Attributes)
+	}
+}
+
+func TestCrossAccountSubscription(t *testing.T) {
+	f := New()
+	ctx := context.TODO()
+	topicArn := "arn:aws:sns:us-west-2:210987654321:other-account-topic"
+
+	err := f.AddTopic(topicArn, map[string]string{})
+
+	if err != nil {
+		t.Fatalf("Expected no error adding topic but got %s", err.Error())
+	}
+
+	queueUrl := createQueue(t, f, "valid-queue", map[string]string{})
+	subscriptionArn := subscribe(t, f, topicArn, "arn:aws:sqs:us-east-1:123456789012:valid-queue", map[string]string{})
+
+	attributes, err := f.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{SubscriptionArn: aws.String(subscriptionArn)})
+
+	if err != nil || attributes.Attributes["PendingConfirmation"] != "true" {
+		t.Fatalf("Expected the subscription to be pending confirmation but got %v", attributes)
+	}
+
+	_, _ = f.Publish(ctx, &sns.PublishInput{TopicArn: aws.String(topicArn), Message: aws.String("before confirmation")})
+
+	messages := receiveMessages(t, f, queueUrl, 10)
+
+	if len(messages) != 1 {
+		t.Fatalf("Expected only the confirmation message but got %v", bodies(messages))
+	}
+
+	confirmation := decode(t, *messages[0].Body)
+
+	if confirmation.Type != "SubscriptionConfirmation" || confirmation.Token == "" {
+		t.Fatalf("Expected a subscription confirmation but got %v", confirmation)
+	}
+
+	_, err = f.ConfirmSubscription(ctx, &sns.ConfirmSubscriptionInput{TopicArn: aws.String(topicArn), Token: aws.String("invalid-token")})
+
+	if err == nil {
+		t.Fatal("Expected an error confirming with an invalid token but got no error")
+	}
+
+	result, err := f.ConfirmSubscription(ctx, &sns.ConfirmSubscriptionInput{TopicArn: aws.String(topicArn), Token: aws.String(confirmation.Token)})
+
+	if err != nil || *result.SubscriptionArn != subscriptionArn {
+		t.Fatalf("Expected subscription %s to be confirmed but got %v", subscriptionArn, err)
+	}
+
+	_, _ = f.Publish(ctx, &sns.PublishInput{TopicArn: aws.String(topicArn), Message: aws.String("after confirmation")})
+	_, _ = f.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(queueUrl), ReceiptHandle: messages[0].ReceiptHandle})
+
+	messages = receiveMessages(t, f, queueUrl, 10)
+
+	if len(messages) != 1 || decode(t, *messages[0].Body).Message != "after confirmation" {
+		t.Fatalf("Expected the message published after confirmation but got %v", bodies(messages))
+	}
+}
@@ -0,0 +1,756 @@
+package listenertest
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+// deduplicationInterval is how long a FIFO queue or topic remembers a message deduplication ID for.
+const deduplicationInterval = 5 * time.Minute
+
+var queueNamePattern = regexp.MustCompile(`^[A-Za-z0-9_\-]{1,80}$`)
+
+type queue struct {
+	name       string
+	url        string
+	arn        string
+	attributes map[string]string
+	createdAt  time.Time
+	messages   []*message
+	// deduplicated is when each message deduplication ID was last accepted and the ID of the message it was for
+	deduplicated map[string]deduplicatedMessage
+}
+
+type deduplicatedMessage struct {
+	messageId      string
+	sequenceNumber string
+	sentAt         time.Time
+}
+
+type message struct {
+	id              string
+	body            string
+	attributes      map[string]types.MessageAttributeValue
+	senderId        string
+	groupId         string
+	deduplicationId string
+	sequenceNumber  string
+	sentAt          time.Time
+	visibleAt       time.Time
+	receiveCount    int
+	firstReceivedAt time.Time
+	receiptHandle   string
+}
+
+func (q *queue) isFIFO() bool {
+	return q.attributes[string(types.QueueAttributeNameFifoQueue)] == "true"
+}
+
+func (q *queue) contentBasedDeduplication() bool {
+	return q.attributes[string(types.QueueAttributeNameContentBasedDeduplication)] == "true"
+}
+
+func (q *queue) seconds(attribute types.QueueAttributeName, defaultValue int) int {
+	value, err := strconv.Atoi(q.attributes[string(attribute)])
+
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// redrivePolicy returns the dead-letter queue ARN and max receive count from the queue's redrive policy, if it has one.
+func (q *queue) redrivePolicy() (string, int) {
+	var policy struct {
+		DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+		MaxReceiveCount     json.Number `json:"maxReceiveCount"`
+	}
+
+	err := json.Unmarshal([]byte(q.attributes[string(types.QueueAttributeNameRedrivePolicy)]), &policy)
+
+	if err != nil {
+		return "", 0
+	}
+
+	maxReceiveCount, err := policy.MaxReceiveCount.Int64()
+
+	if err != nil {
+		return "", 0
+	}
+
+	return policy.DeadLetterTargetArn, int(maxReceiveCount)
+}
+
+// CreateQueue creates a queue, or returns the URL of an existing queue with the same name and attributes.
+func (f *Fake) CreateQueue(ctx context.Context,
+	params *sqs.CreateQueueInput,
+	optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateQueue"); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.QueueName)
+	isFIFO := params.Attributes[string(types.QueueAttributeNameFifoQueue)] == "true"
+
+	if isFIFO != strings.HasSuffix(name, ".fifo") || !queueNamePattern.MatchString(strings.TrimSuffix(name, ".fifo")) {
+		return nil, invalidParameterValue("Can only include alphanumeric characters, hyphens, or underscores. 1 to 80 in length. FIFO queue names must end in .fifo")
+	}
+
+	if params.Attributes[string(types.QueueAttributeNameContentBasedDeduplication)] == "true" && !isFIFO {
+		return nil, &types.InvalidAttributeName{Message: aws.String("ContentBasedDeduplication is only valid for FIFO queues")}
+	}
+
+	queueBaseUrl := f.QueueBaseURL
+
+	if queueBaseUrl == "" {
+		queueBaseUrl = fmt.Sprintf("https://sqs.%s.amazonaws.com", f.Region)
+	}
+
+	queueUrl := fmt.Sprintf("%s/%s/%s", queueBaseUrl, f.AccountID, name)
+
+	if existing, ok := f.queues[queueUrl]; ok {
+		for k, v := range params.Attributes {
+			if existing.attributes[k] != v {
+				return nil, &types.QueueNameExists{Message: aws.String("A queue already exists with the same name and a different value for attribute " + k)}
+			}
+		}
+
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String(queueUrl)}, nil
+	}
+
+	attributes := map[string]string{}
+
+	for k, v := range params.Attributes {
+		attributes[k] = v
+	}
+
+	f.queues[queueUrl] = &queue{
+		name:         name,
+		url:          queueUrl,
+		arn:          fmt.Sprintf("arn:aws:sqs:%s:%s:%s", f.Region, f.AccountID, name),
+		attributes:   attributes,
+		createdAt:    f.Clock(),
+		deduplicated: map[string]deduplicatedMessage{},
+	}
+
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(queueUrl)}, nil
+}
+
+// DeleteQueue deletes a queue and every message in it.
+func (f *Fake) DeleteQueue(ctx context.Context,
+	params *sqs.DeleteQueueInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteQueue"); err != nil {
+		return nil, err
+	}
+
+	q, err := f.queue(params.QueueUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delete(f.queues, q.url)
+
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+// ListQueues returns the URLs of every queue whose name starts with QueueNamePrefix, in a single page.
+func (f *Fake) ListQueues(ctx context.Context,
+	params *sqs.ListQueuesInput,
+	optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ListQueues"); err != nil {
+		return nil, err
+	}
+
+	queueUrls := []string{}
+
+	for _, queueUrl := range sortedKeys(f.queues) {
+		if strings.HasPrefix(f.queues[queueUrl].name, aws.ToString(params.QueueNamePrefix)) {
+			queueUrls = append(queueUrls, queueUrl)
+		}
+	}
+
+	return &sqs.ListQueuesOutput{QueueUrls: queueUrls}, nil
+}
+
+// PurgeQueue deletes every message in a queue, including messages that are in flight.
+func (f *Fake) PurgeQueue(ctx context.Context,
+	params *sqs.PurgeQueueInput,
+	optFns ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("PurgeQueue"); err != nil {
+		return nil, err
+	}
+
+	q, err := f.queue(params.QueueUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q.messages = nil
+
+	return &sqs.PurgeQueueOutput{}, nil
+}
+
+// GetQueueUrl returns the URL of the queue with the provided name.
+func (f *Fake) GetQueueUrl(ctx context.Context,
+	params *sqs.GetQueueUrlInput,
+	optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("GetQueueUrl"); err != nil {
+		return nil, err
+	}
+
+	for _, q := range f.queues {
+		if q.name == aws.ToString(params.QueueName) {
+			return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(q.url)}, nil
+		}
+	}
+
+	return nil, queueDoesNotExist()
+}
+
+// GetQueueAttributes returns the requested attributes of a queue, including the approximate number of messages
+// that are visible, in flight and delayed.
+func (f *Fake) GetQueueAttributes(ctx context.Context,
+	params *sqs.GetQueueAttributesInput,
+	optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("GetQueueAttributes"); err != nil {
+		return nil, err
+	}
+
+	q, err := f.queue(params.QueueUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := f.Clock()
+	visible, inFlight, delayed := 0, 0, 0
+
+	for _, m := range q.messages {
+		switch {
+		case !m.visibleAt.After(now):
+			visible++
+		case m.receiveCount > 0:
+			inFlight++
+		default:
+			delayed++
+		}
+	}
+
+	all := map[string]string{
+		string(types.QueueAttributeNameQueueArn):                              q.arn,
+		string(types.QueueAttributeNameCreatedTimestamp):                      strconv.FormatInt(q.createdAt.Unix(), 10),
+		string(types.QueueAttributeNameLastModifiedTimestamp):                 strconv.FormatInt(q.createdAt.Unix(), 10),
+		string(types.QueueAttributeNameVisibilityTimeout):                     strconv.Itoa(q.seconds(types.QueueAttributeNameVisibilityTimeout, 30)),
+		string(types.QueueAttributeNameDelaySeconds):                          strconv.Itoa(q.seconds(types.QueueAttributeNameDelaySeconds, 0)),
+		string(types.QueueAttributeNameReceiveMessageWaitTimeSeconds):         strconv.Itoa(q.seconds(types.QueueAttributeNameReceiveMessageWaitTimeSeconds, 0)),
+		string(types.QueueAttributeNameApproximateNumberOfMessages):           strconv.Itoa(visible),
+		string(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible): strconv.Itoa(inFlight),
+		string(types.QueueAttributeNameApproximateNumberOfMessagesDelayed):    strconv.Itoa(delayed),
+	}
+
+	for k, v := range q.attributes {
+		all[k] = v
+	}
+
+	attributes := map[string]string{}
+
+	for _, name := range params.AttributeNames {
+		if name == types.QueueAttributeNameAll {
+			attributes = all
+			break
+		}
+
+		if v, ok := all[string(name)]; ok {
+			attributes[string(name)] = v
+		}
+	}
+
+	return &sqs.GetQueueAttributesOutput{Attributes: attributes}, nil
+}
+
+// SendMessage adds a message to a queue. Messages sent to a FIFO queue need a message group ID and either a message
+// deduplication ID or a queue with content-based deduplication. Duplicates are accepted but aren't added to the queue.
+func (f *Fake) SendMessage(ctx context.Context,
+	params *sqs.SendMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("SendMessage"); err != nil {
+		return nil, err
+	}
+
+	q, err := f.queue(params.QueueUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(params.MessageBody) == "" {
+		return nil, &smithy.GenericAPIError{Code: "MissingParameter", Message: "The request must contain the parameter MessageBody.", Fault: smithy.FaultClient}
+	}
+
+	m, err := f.enqueue(
+		q,
+		aws.ToString(params.MessageBody),
+		params.MessageAttributes,
+		aws.ToString(params.MessageGroupId),
+		aws.ToString(params.MessageDeduplicationId),
+		time.Duration(params.DelaySeconds)*time.Second,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	output := &sqs.SendMessageOutput{
+		MessageId:              aws.String(m.messageId),
+		MD5OfMessageBody:       aws.String(md5Hex([]byte(aws.ToString(params.MessageBody)))),
+		MD5OfMessageAttributes: md5OfMessageAttributes(params.MessageAttributes),
+	}
+
+	if m.sequenceNumber != "" {
+		output.SequenceNumber = aws.String(m.sequenceNumber)
+	}
+
+	return output, nil
+}
+
+// enqueue adds a message to the queue unless it's a duplicate of a message added to a FIFO queue in the last 5
+// minutes. The returned message ID is the ID of the original message for duplicates. It must be called with the lock held.
+func (f *Fake) enqueue(q *queue,
+	body string,
+	attributes map[string]types.MessageAttributeValue,
+	groupId string,
+	deduplicationId string,
+	delay time.Duration) (deduplicatedMessage, error) {
+	now := f.Clock()
+
+	if !q.isFIFO() {
+		if groupId != "" || deduplicationId != "" {
+			return deduplicatedMessage{}, invalidParameterValue("MessageGroupId and MessageDeduplicationId are only valid for FIFO queues")
+		}
+
+		if delay == 0 {
+			delay = time.Duration(q.seconds(types.QueueAttributeNameDelaySeconds, 0)) * time.Second
+		}
+	} else {
+		if groupId == "" {
+			return deduplicatedMessage{}, &smithy.GenericAPIError{Code: "MissingParameter", Message: "The request must contain the parameter MessageGroupId.", Fault: smithy.FaultClient}
+		}
+
+		if delay != 0 {
+			return deduplicatedMessage{}, invalidParameterValue("DelaySeconds can't be set on messages sent to FIFO queues")
+		}
+
+		delay = time.Duration(q.seconds(types.QueueAttributeNameDelaySeconds, 0)) * time.Second
+
+		if deduplicationId == "" && !q.contentBasedDeduplication() {
+			return deduplicatedMessage{}, invalidParameterValue("The queue should either have ContentBasedDeduplication enabled or MessageDeduplicationId provided explicitly")
+		}
+
+		if deduplicationId == "" {
+			deduplicationId = sha256Hex([]byte(body))
+		}
+
+		if previous, ok := q.deduplicated[deduplicationId]; ok && now.Sub(previous.sentAt) < deduplicationInterval {
+			return previous, nil
+		}
+	}
+
+	m := &message{
+		id:              newId(),
+		body:            body,
+		attributes:      attributes,
+		senderId:        f.AccountID,
+		groupId:         groupId,
+		deduplicationId: deduplicationId,
+		sentAt:          now,
+		visibleAt:       now.Add(delay),
+	}
+
+	if q.isFIFO() {
+		m.sequenceNumber = f.nextSequenceNumber()
+	}
+
+	q.messages = append(q.messages, m)
+
+	sent := deduplicatedMessage{m.id, m.sequenceNumber, now}
+
+	if q.isFIFO() {
+		q.deduplicated[deduplicationId] = sent
+	}
+
+	f.notify()
+
+	return sent, nil
+}
+
+// ReceiveMessage receives up to MaxNumberOfMessages visible messages from a queue and hides them for the visibility
+// timeout. Messages that have already been received as many times as the redrive policy allows are moved to the
+// dead-letter queue instead. A FIFO message group isn't received from while any of its messages are in flight.
+// When WaitTimeSeconds is set it waits for messages to arrive if the queue is empty.
+func (f *Fake) ReceiveMessage(ctx context.Context,
+	params *sqs.ReceiveMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	maxMessages := int(params.MaxNumberOfMessages)
+
+	if maxMessages == 0 {
+		maxMessages = 1
+	}
+
+	if maxMessages < 1 || maxMessages > 10 {
+		return nil, invalidParameterValue("Value %d for parameter MaxNumberOfMessages is invalid. Reason: Must be between 1 and 10.", maxMessages)
+	}
+
+	if params.WaitTimeSeconds < 0 || params.WaitTimeSeconds > 20 {
+		return nil, invalidParameterValue("Value %d for parameter WaitTimeSeconds is invalid. Reason: Must be >= 0 and <= 20.", params.WaitTimeSeconds)
+	}
+
+	waiting := params.WaitTimeSeconds > 0
+	deadline := time.NewTimer(time.Duration(params.WaitTimeSeconds) * time.Second)
+	defer deadline.Stop()
+
+	for {
+		f.mu.Lock()
+
+		if err := f.fault("ReceiveMessage"); err != nil {
+			f.mu.Unlock()
+			return nil, err
+		}
+
+		q, err := f.queue(params.QueueUrl)
+
+		if err != nil {
+			f.mu.Unlock()
+			return nil, err
+		}
+
+		messages := f.receive(q, maxMessages, params)
+		changed := f.changed
+
+		f.mu.Unlock()
+
+		if len(messages) > 0 || !waiting {
+			return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &smithy.CanceledError{Err: ctx.Err()}
+		case <-deadline.C:
+			waiting = false
+		case <-changed:
+		}
+	}
+}
+
+// receive takes messages from the queue. It must be called with the lock held.
+func (f *Fake) receive(q *queue, maxMessages int, params *sqs.ReceiveMessageInput) []types.Message {
+	now := f.Clock()
+	visibilityTimeout := time.Duration(q.seconds(types.QueueAttributeNameVisibilityTimeout, 30)) * time.Second
+
+	if params.VisibilityTimeout > 0 {
+		visibilityTimeout = time.Duration(params.VisibilityTimeout) * time.Second
+	}
+
+	// A FIFO message group is blocked while any of its messages are in flight, and messages behind a delayed
+	// message have to wait for it
+	blocked := map[string]bool{}
+
+	if q.isFIFO() {
+		for _, m := range q.messages {
+			if m.visibleAt.After(now) {
+				blocked[m.groupId] = true
+			}
+		}
+	}
+
+	deadLetterQueueArn, maxReceiveCount := q.redrivePolicy()
+	received := []types.Message{}
+	remaining := make([]*message, 0, len(q.messages))
+
+	for _, m := range q.messages {
+		if len(received) == maxMessages || m.visibleAt.After(now) || blocked[m.groupId] {
+			remaining = append(remaining, m)
+			continue
+		}
+
+		if maxReceiveCount > 0 && m.receiveCount >= maxReceiveCount {
+			f.redrive(m, deadLetterQueueArn)
+			continue
+		}
+
+		m.receiveCount++
+		m.receiptHandle = newId()
+		m.visibleAt = now.Add(visibilityTimeout)
+
+		if m.receiveCount == 1 {
+			m.firstReceivedAt = now
+		}
+
+		received = append(received, m.toMessage(params))
+		remaining = append(remaining, m)
+	}
+
+	q.messages = remaining
+
+	return received
+}
+
+// redrive moves a message to the dead-letter queue. The message is dropped if the dead-letter queue doesn't exist.
+// It must be called with the lock held.
+func (f *Fake) redrive(m *message, deadLetterQueueArn string) {
+	for _, q := range f.queues {
+		if q.arn != deadLetterQueueArn {
+			continue
+		}
+
+		moved := *m
+		moved.receiveCount = 0
+		moved.receiptHandle = ""
+		moved.visibleAt = m.sentAt
+
+		q.messages = append(q.messages, &moved)
+
+		return
+	}
+}
+
+func (m *message) toMessage(params *sqs.ReceiveMessageInput) types.Message {
+	systemAttributes := map[string]string{
+		string(types.MessageSystemAttributeNameSenderId):                         m.senderId,
+		string(types.MessageSystemAttributeNameSentTimestamp):                    strconv.FormatInt(m.sentAt.UnixMilli(), 10),
+		string(types.MessageSystemAttributeNameApproximateReceiveCount):          strconv.Itoa(m.receiveCount),
+		string(types.MessageSystemAttributeNameApproximateFirstReceiveTimestamp): strconv.FormatInt(m.firstReceivedAt.UnixMilli(), 10),
+	}
+
+	if m.groupId != "" {
+		systemAttributes[string(types.MessageSystemAttributeNameMessageGroupId)] = m.groupId
+		systemAttributes[string(types.MessageSystemAttributeNameMessageDeduplicationId)] = m.deduplicationId
+		systemAttributes[string(types.MessageSystemAttributeNameSequenceNumber)] = m.sequenceNumber
+	}
+
+	attributes := map[string]string{}
+
+	for _, name := range params.AttributeNames {
+		if name == types.QueueAttributeNameAll {
+			attributes = systemAttributes
+			break
+		}
+
+		if v, ok := systemAttributes[string(name)]; ok {
+			attributes[string(name)] = v
+		}
+	}
+
+	messageAttributes := map[string]types.MessageAttributeValue{}
+
+	for k, v := range m.attributes {
+		for _, name := range params.MessageAttributeNames {
+			if name == "All" || name == ".*" || name == k || (strings.HasSuffix(name, ".*") && strings.HasPrefix(k, strings.TrimSuffix(name, "*"))) {
+				messageAttributes[k] = v
+				break
+			}
+		}
+	}
+
+	message := types.Message{
+		MessageId:     aws.String(m.id),
+		ReceiptHandle: aws.String(m.receiptHandle),
+		Body:          aws.String(m.body),
+		MD5OfBody:     aws.String(md5Hex([]byte(m.body))),
+		Attributes:    attributes,
+	}
+
+	if len(messageAttributes) > 0 {
+		message.MessageAttributes = messageAttributes
+		message.MD5OfMessageAttributes = md5OfMessageAttributes(messageAttributes)
+	}
+
+	return message
+}
+
+// DeleteMessage deletes the message that was most recently received with the receipt handle.
+func (f *Fake) DeleteMessage(ctx context.Context,
+	params *sqs.DeleteMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteMessage"); err != nil {
+		return nil, err
+	}
+
+	q, err := f.queue(params.QueueUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range q.messages {
+		if m.receiptHandle != "" && m.receiptHandle == aws.ToString(params.ReceiptHandle) {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			f.notify()
+
+			return &sqs.DeleteMessageOutput{}, nil
+		}
+	}
+
+	return nil, &types.ReceiptHandleIsInvalid{Message: aws.String("The input receipt handle is invalid.")}
+}
+
+// ChangeMessageVisibility changes when a message that's in flight becomes visible again.
+// A visibility timeout of 0 makes it visible straight away.
+func (f *Fake) ChangeMessageVisibility(ctx context.Context,
+	params *sqs.ChangeMessageVisibilityInput,
+	optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ChangeMessageVisibility"); err != nil {
+		return nil, err
+	}
+
+	q, err := f.queue(params.QueueUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if params.VisibilityTimeout < 0 || params.VisibilityTimeout > 43200 {
+		return nil, invalidParameterValue("Value %d for parameter VisibilityTimeout is invalid. Reason: Must be >= 0 and <= 43200.", params.VisibilityTimeout)
+	}
+
+	now := f.Clock()
+
+	for _, m := range q.messages {
+		if m.receiptHandle == "" || m.receiptHandle != aws.ToString(params.ReceiptHandle) {
+			continue
+		}
+
+		if !m.visibleAt.After(now) {
+			return nil, &types.MessageNotInflight{Message: aws.String("The message referred to isn't in flight.")}
+		}
+
+		m.visibleAt = now.Add(time.Duration(params.VisibilityTimeout) * time.Second)
+		f.notify()
+
+		return &sqs.ChangeMessageVisibilityOutput{}, nil
+	}
+
+	return nil, &types.ReceiptHandleIsInvalid{Message: aws.String("The input receipt handle is invalid.")}
+}
+
+// queue returns the queue with the provided URL. It must be called with the lock held.
+func (f *Fake) queue(queueUrl *string) (*queue, error) {
+	q, ok := f.queues[aws.ToString(queueUrl)]
+
+	if !ok {
+		return nil, queueDoesNotExist()
+	}
+
+	return q, nil
+}
+
+// queueByArn returns the queue with the provided ARN. It must be called with the lock held.
+func (f *Fake) queueByArn(queueArn string) (*queue, bool) {
+	for _, q := range f.queues {
+		if q.arn == queueArn {
+			return q, true
+		}
+	}
+
+	return nil, false
+}
+
+func queueDoesNotExist() error {
+	return &types.QueueDoesNotExist{Message: aws.String("The specified queue does not exist.")}
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// md5OfMessageAttributes returns the digest of the message attributes that SQS returns, which the SDK checks.
+func md5OfMessageAttributes(attributes map[string]types.MessageAttributeValue) *string {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(attributes))
+
+	for name := range attributes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	buf := []byte{}
+	appendValue := func(b []byte) {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(b)))
+		buf = append(buf, b...)
+	}
+
+	for _, name := range names {
+		attribute := attributes[name]
+
+		appendValue([]byte(name))
+		appendValue([]byte(aws.ToString(attribute.DataType)))
+
+		if attribute.BinaryValue != nil {
+			buf = append(buf, 2)
+			appendValue(attribute.BinaryValue)
+		} else {
+			buf = append(buf, 1)
+			appendValue([]byte(aws.ToString(attribute.StringValue)))
+		}
+	}
+
+	return aws.String(md5Hex(buf))
+}
@@ -0,0 +1,483 @@
+package listenertest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2023, 3, 30, 21, 49, 37, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func createQueue(t *testing.T, f *Fake, name string, attributes map[string]string) string {
+	result, err := f.CreateQueue(context.TODO(), &sqs.CreateQueueInput{QueueName: aws.String(name), Attributes: attributes})
+
+	if err != nil {
+		t.Fatalf("Expected no error creating queue but got %s", err.Error())
+	}
+
+	return *result.QueueUrl
+}
+
+func sendMessage(t *testing.T, f *Fake, queueUrl string, body string, groupId string, deduplicationId string) string {
+	params := &sqs.SendMessageInput{QueueUrl: aws.String(queueUrl), MessageBody: aws.String(body)}
+
+	if groupId != "" {
+		params.MessageGroupId = aws.String(groupId)
+	}
+
+	if deduplicationId != "" {
+		params.MessageDeduplicationId = aws.String(deduplicationId)
+	}
+
+	result, err := f.SendMessage(context.TODO(), params)
+
+	if err != nil {
+		t.Fatalf("Expected no error sending message but got %s", err.Error())
+	}
+
+	return *result.MessageId
+}
+
+func receiveMessages(t *testing.T, f *Fake, queueUrl string, maxMessages int32) []types.Message {
+	result, err := f.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(queueUrl),
+		MaxNumberOfMessages:   maxMessages,
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{"All"},
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error receiving messages but got %s", err.Error())
+	}
+
+	return result.Messages
+}
+
+func bodies(messages []types.Message) []string {
+	result := []string{}
+
+	for _, m := range messages {
+		result = append(result, aws.ToString(m.Body))
+	}
+
+	return result
+}
+
+func TestCreateQueue(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr   bool
+		queueName   string
+		attributes  map[string]string
+		expectedUrl string
+	}{
+		"standard queue": {
+			false,
+			"valid-queue",
+			map[string]string{},
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+		},
+		"fifo queue": {
+			false,
+			"valid-queue.fifo",
+			map[string]string{"FifoQueue": "true", "ContentBasedDeduplication": "true"},
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue.fifo",
+		},
+		"existing queue with the same attributes": {
+			false,
+			"existing-queue",
+			map[string]string{"VisibilityTimeout": "60"},
+			"https://sqs.us-east-1.amazonaws.com/123456789012/existing-queue",
+		},
+		"existing queue with different attributes": {true, "existing-queue", map[string]string{"VisibilityTimeout": "30"}, ""},
+		"fifo queue without suffix":                {true, "valid-queue", map[string]string{"FifoQueue": "true"}, ""},
+		"suffix without fifo queue":                {true, "valid-queue.fifo", map[string]string{}, ""},
+		"content-based deduplication on standard":  {true, "valid-queue", map[string]string{"ContentBasedDeduplication": "true"}, ""},
+		"invalid name":                             {true, "invalid queue", map[string]string{}, ""},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := New()
+			createQueue(t, f, "existing-queue", map[string]string{"VisibilityTimeout": "60"})
+
+			result, err := f.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String(test.queueName), Attributes: test.attributes})
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if *result.QueueUrl != test.expectedUrl {
+					t.Fatalf("Queue URL %s did not match expected value %s", *result.QueueUrl, test.expectedUrl)
+				}
+			}
+		})
+	}
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	clock := newTestClock()
+	f := New(WithClock(clock.Now))
+	ctx := context.TODO()
+	queueUrl := createQueue(t, f, "valid-queue", map[string]string{"VisibilityTimeout": "30"})
+
+	sendMessage(t, f, queueUrl, "hello", "", "")
+
+	messages := receiveMessages(t, f, queueUrl, 10)
+
+	if len(messages) != 1 || messages[0].Attributes["ApproximateReceiveCount"] != "1" {
+		t.Fatalf("Expected 1 message received once but got %v", messages)
+	}
+
+	if messages := receiveMessages(t, f, queueUrl, 10); len(messages) != 0 {
+		t.Fatalf("Expected no messages while in flight but got %d", len(messages))
+	}
+
+	clock.Advance(30 * time.Second)
+
+	messages = receiveMessages(t, f, queueUrl, 10)
+
+	if len(messages) != 1 || messages[0].Attributes["ApproximateReceiveCount"] != "2" {
+		t.Fatalf("Expected 1 message received twice but got %v", messages)
+	}
+
+	_, err := f.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueUrl),
+		ReceiptHandle:     messages[0].ReceiptHandle,
+		VisibilityTimeout: 0,
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error changing visibility but got %s", err.Error())
+	}
+
+	messages = receiveMessages(t, f, queueUrl, 10)
+
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message after changing its visibility but got %d", len(messages))
+	}
+
+	_, err = f.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(queueUrl), ReceiptHandle: messages[0].ReceiptHandle})
+
+	if err != nil {
+		t.Fatalf("Expected no error deleting message but got %s", err.Error())
+	}
+
+	_, err = f.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(queueUrl), ReceiptHandle: messages[0].ReceiptHandle})
+
+	var invalid *types.ReceiptHandleIsInvalid
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected an invalid receipt handle deleting the message again but got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+
+	if messages := receiveMessages(t, f, queueUrl, 10); len(messages) != 0 {
+		t.Fatalf("Expected no messages after deleting but got %d", len(messages))
+	}
+}
+
+func TestFIFOOrdering(t *testing.T) {
+	f := New()
+	ctx := context.TODO()
+	queueUrl := createQueue(t, f, "valid-queue.fifo", map[string]string{"FifoQueue": "true", "ContentBasedDeduplication": "true"})
+
+	sendMessage(t, f, queueUrl, "first", "group-1", "")
+	sendMessage(t, f, queueUrl, "second", "group-1", "")
+	sendMessage(t, f, queueUrl, "third", "group-2", "")
+
+	first := receiveMessages(t, f, queueUrl, 1)
+
+	if len(first) != 1 || *first[0].Body != "first" || first[0].Attributes["MessageGroupId"] != "group-1" {
+		t.Fatalf("Expected the first message from group-1 but got %v", bodies(first))
+	}
+
+	// group-1 is blocked until the first message is deleted
+	if messages := receiveMessages(t, f, queueUrl, 10); len(messages) != 1 || *messages[0].Body != "third" {
+		t.Fatalf("Expected only the message from group-2 but got %v", bodies(messages))
+	}
+
+	_, err := f.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(queueUrl), ReceiptHandle: first[0].ReceiptHandle})
+
+	if err != nil {
+		t.Fatalf("Expected no error deleting message but got %s", err.Error())
+	}
+
+	if messages := receiveMessages(t, f, queueUrl, 10); len(messages) != 1 || *messages[0].Body != "second" {
+		t.Fatalf("Expected the second message from group-1 but got %v", bodies(messages))
+	}
+}
+
+func TestFIFODeduplication(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr       bool
+		attributes      map[string]string
+		bodies          []string
+		deduplicationId string
+		advance         time.Duration
+		expectedBodies  []string
+	}{
+		"explicit deduplication ID": {
+			false,
+			map[string]string{"FifoQueue": "true"},
+			[]string{"first", "second"},
+			"same-id",
+			0,
+			[]string{"first"},
+		},
+		"content-based deduplication": {
+			false,
+			map[string]string{"FifoQueue": "true", "ContentBasedDeduplication": "true"},
+			[]string{"same", "same", "different"},
+			"",
+			0,
+			[]string{"same", "different"},
+		},
+		"after the deduplication interval": {
+			false,
+			map[string]string{"FifoQueue": "true"},
+			[]string{"first", "second"},
+			"same-id",
+			5 * time.Minute,
+			[]string{"first", "second"},
+		},
+		"no deduplication ID": {true, map[string]string{"FifoQueue": "true"}, []string{"first"}, "", 0, []string{}},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clock := newTestClock()
+			f := New(WithClock(clock.Now))
+			queueUrl := createQueue(t, f, "valid-queue.fifo", test.attributes)
+			var err error
+
+			for _, body := range test.bodies {
+				params := &sqs.SendMessageInput{
+					QueueUrl:       aws.String(queueUrl),
+					MessageBody:    aws.String(body),
+					MessageGroupId: aws.String("group"),
+				}
+
+				if test.deduplicationId != "" {
+					params.MessageDeduplicationId = aws.String(test.deduplicationId)
+				}
+
+				_, err = f.SendMessage(ctx, params)
+
+				if err != nil {
+					break
+				}
+
+				clock.Advance(test.advance)
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				received := []string{}
+
+				for {
+					messages := receiveMessages(t, f, queueUrl, 10)
+
+					if len(messages) == 0 {
+						break
+					}
+
+					for _, m := range messages {
+						received = append(received, *m.Body)
+						_, _ = f.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(queueUrl), ReceiptHandle: m.ReceiptHandle})
+					}
+				}
+
+				if len(received) != len(test.expectedBodies) {
+					t.Fatalf("Received messages %v did not match expected values %v", received, test.expectedBodies)
+				}
+
+				for i := range received {
+					if received[i] != test.expectedBodies[i] {
+						t.Fatalf("Received messages %v did not match expected values %v", received, test.expectedBodies)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRedrive(t *testing.T) {
+	clock := newTestClock()
+	f := New(WithClock(clock.Now))
+	deadLetterQueueUrl := createQueue(t, f, "valid-queue-dlq", map[string]string{})
+	queueUrl := createQueue(t, f, "valid-queue", map[string]string{
+		"RedrivePolicy": `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:valid-queue-dlq","maxReceiveCount":"2"}`,
+	})
+
+	messageId := sendMessage(t, f, queueUrl, "hello", "", "")
+
+	for i := 0; i < 2; i++ {
+		if messages := receiveMessages(t, f, queueUrl, 10); len(messages) != 1 {
+			t.Fatalf("Expected receive %d to return the message but got %d messages", i+1, len(messages))
+		}
+
+		clock.Advance(time.Minute)
+	}
+
+	if messages := receiveMessages(t, f, queueUrl, 10); len(messages) != 0 {
+		t.Fatalf("Expected the message to have been moved but got %d messages", len(messages))
+	}
+
+	messages := receiveMessages(t, f, deadLetterQueueUrl, 10)
+
+	if len(messages) != 1 || *messages[0].MessageId != messageId {
+		t.Fatalf("Expected message %s in the dead-letter queue but got %v", messageId, messages)
+	}
+}
+
+func TestLongPolling(t *testing.T) {
+	f := New()
+	queueUrl := createQueue(t, f, "valid-queue", map[string]string{})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		sendMessage(t, f, queueUrl, "hello", "", "")
+	}()
+
+	result, err := f.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{QueueUrl: aws.String(queueUrl), WaitTimeSeconds: 5})
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err.Error())
+	}
+
+	if len(result.Messages) != 1 {
+		t.Fatalf("Expected the message sent while waiting but got %d messages", len(result.Messages))
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = f.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: aws.String(queueUrl), WaitTimeSeconds: 20})
+
+	var cancelErr *smithy.CanceledError
+
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("Expected a cancelled error but got %v", err)
+	}
+}
+
+func TestGetQueueAttributes(t *testing.T) {
+	clock := newTestClock()
+	f := New(WithClock(clock.Now))
+	queueUrl := createQueue(t, f, "valid-queue", map[string]string{"DelaySeconds": "10"})
+
+	sendMessage(t, f, queueUrl, "first", "", "")
+	sendMessage(t, f, queueUrl, "second", "", "")
+	clock.Advance(10 * time.Second)
+	receiveMessages(t, f, queueUrl, 1)
+	sendMessage(t, f, queueUrl, "third", "", "")
+
+	result, err := f.GetQueueAttributes(context.TODO(), &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueUrl),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err.Error())
+	}
+
+	expected := map[string]string{
+		"QueueArn":                              "arn:aws:sqs:us-east-1:123456789012:valid-queue",
+		"DelaySeconds":                          "10",
+		"ApproximateNumberOfMessages":           "1",
+		"ApproximateNumberOfMessagesNotVisible": "1",
+		"ApproximateNumberOfMessagesDelayed":    "1",
+	}
+
+	for k, v := range expected {
+		if result.Attributes[k] != v {
+			t.Fatalf("Expected attribute %s to be %s but got %s", k, v, result.Attributes[k])
+		}
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	f := New()
+	queueUrl := createQueue(t, f, "valid-queue", map[string]string{})
+	fault := errors.New("Throttled")
+
+	f.InjectFault("ReceiveMessage", 2, fault)
+
+	for i := 0; i < 2; i++ {
+		_, err := f.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{QueueUrl: aws.String(queueUrl)})
+
+		if !errors.Is(err, fault) {
+			t.Fatalf("Expected the injected fault on call %d but got %v", i+1, err)
+		}
+	}
+
+	_, err := f.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{QueueUrl: aws.String(queueUrl)})
+
+	if err != nil {
+		t.Fatalf("Expected no error once the fault was used up but got %s", err.Error())
+	}
+
+	f.InjectFault("DeleteQueue", -1, fault)
+	f.ClearFaults()
+
+	_, err = f.DeleteQueue(context.TODO(), &sqs.DeleteQueueInput{QueueUrl: aws.String(queueUrl)})
+
+	if err != nil {
+		t.Fatalf("Expected no error once the faults were cleared but got %s", err.Error())
+	}
+}
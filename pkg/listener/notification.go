@@ -0,0 +1,50 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// A Notification is the JSON envelope that SNS wraps around a published message when delivering
+// it to an SQS queue. Fields which aren't relevant to the notification type are left empty, e.g.
+// SubscribeURL and Token are only present on subscription confirmations.
+type Notification struct {
+	Type              string
+	MessageId         string
+	TopicArn          string
+	Subject           string
+	Message           string
+	Timestamp         time.Time
+	SequenceNumber    string
+	MessageAttributes map[string]MessageAttribute
+	SignatureVersion  string
+	Signature         string
+	SigningCertURL    string
+	SubscribeURL      string
+	Token             string
+	UnsubscribeURL    string
+}
+
+// A MessageAttribute is a single message attribute as it appears in the SNS envelope.
+// Binary values are base64 encoded by SNS and are left that way.
+type MessageAttribute struct {
+	Type  string
+	Value string
+}
+
+func parseNotification(body string) (*Notification, error) {
+	n := new(Notification)
+
+	err := json.Unmarshal([]byte(body), n)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Type == "" || n.MessageId == "" || n.TopicArn == "" {
+		return nil, errors.New("message body is not an SNS notification")
+	}
+
+	return n, nil
+}
@@ -0,0 +1,98 @@
+package listener
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNotification(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		body      string
+		expected  Notification
+	}{
+		"notification": {
+			false,
+			`{
+				"Type": "Notification",
+				"MessageId": "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+				"TopicArn": "arn:aws:sns:us-east-1:123456789012:my-topic",
+				"Subject": "My First Message",
+				"Message": "Hello world!",
+				"Timestamp": "2012-05-02T00:54:06.655Z",
+				"SignatureVersion": "1",
+				"Signature": "EXAMPLEw6JRN...",
+				"SigningCertURL": "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-f3ecfb7224c7233fe7bb5f59f96de52f.pem",
+				"UnsubscribeURL": "https://sns.us-east-1.amazonaws.com/?Action=Unsubscribe",
+				"MessageAttributes": {
+					"colour": {"Type": "String", "Value": "blue"}
+				}
+			}`,
+			Notification{
+				Type:             "Notification",
+				MessageId:        "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+				TopicArn:         "arn:aws:sns:us-east-1:123456789012:my-topic",
+				Subject:          "My First Message",
+				Message:          "Hello world!",
+				Timestamp:        time.Date(2012, 5, 2, 0, 54, 6, 655000000, time.UTC),
+				SignatureVersion: "1",
+				Signature:        "EXAMPLEw6JRN...",
+				SigningCertURL:   "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-f3ecfb7224c7233fe7bb5f59f96de52f.pem",
+				UnsubscribeURL:   "https://sns.us-east-1.amazonaws.com/?Action=Unsubscribe",
+				MessageAttributes: map[string]MessageAttribute{
+					"colour": {Type: "String", Value: "blue"},
+				},
+			},
+		},
+		"not JSON":               {true, "Hello world!", Notification{}},
+		"JSON but not SNS":       {true, `{"foo": "bar"}`, Notification{}},
+		"invalid timestamp type": {true, `{"Type": "Notification", "MessageId": "foo", "TopicArn": "bar", "Timestamp": 5}`, Notification{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := parseNotification(test.body)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result.Type != test.expected.Type ||
+					result.MessageId != test.expected.MessageId ||
+					result.TopicArn != test.expected.TopicArn ||
+					result.Subject != test.expected.Subject ||
+					result.Message != test.expected.Message ||
+					!result.Timestamp.Equal(test.expected.Timestamp) ||
+					result.SignatureVersion != test.expected.SignatureVersion ||
+					result.Signature != test.expected.Signature ||
+					result.SigningCertURL != test.expected.SigningCertURL ||
+					result.UnsubscribeURL != test.expected.UnsubscribeURL {
+					t.Fatalf(
+						"Notification %+v did not match expected notification %+v",
+						*result,
+						test.expected,
+					)
+				}
+
+				for k, v := range test.expected.MessageAttributes {
+					if result.MessageAttributes[k] != v {
+						t.Fatalf(
+							"Message attribute %s was %+v but expected %+v",
+							k,
+							result.MessageAttributes[k],
+							v,
+						)
+					}
+				}
+			}
+		})
+	}
+}
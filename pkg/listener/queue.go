@@ -2,8 +2,15 @@ package listener
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,6 +21,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SQSAPI is a shim over v2 of the AWS SDK's sqs client. The sqs client provided by
@@ -38,21 +46,25 @@ type SQSAPI interface {
 	DeleteMessage(ctx context.Context,
 		params *sqs.DeleteMessageInput,
 		optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+
+	ChangeMessageVisibility(ctx context.Context,
+		params *sqs.ChangeMessageVisibilityInput,
+		optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+
+	GetQueueUrl(ctx context.Context,
+		params *sqs.GetQueueUrlInput,
+		optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
 }
 
-func createQueue(ctx context.Context, client SQSAPI, queueName string, topicArn string) (string, error) {
+// createQueue creates a queue with a policy that allows every topic to send messages to it.
+// The queue is a FIFO queue with the same content-based deduplication as the topics if they're FIFO topics.
+func createQueue(ctx context.Context, client SQSAPI, queueName string, topicArns []string, settings topicSettings, redrivePolicy string) (string, error) {
 	ctx, span := otel.Tracer(name).Start(ctx, "createQueue")
 	defer span.End()
 
-	isFIFO, err := isTopicFIFO(ctx, topicArn)
-
-	if err != nil {
-		return "", err
-	}
+	queueName = defaultQueueName(queueName)
 
-	if queueName == "" {
-		queueName = "sns-listener-" + uuid.NewString()
-	}
+	sourceArns, _ := json.Marshal(topicArns)
 
 	queuePolicy := fmt.Sprintf(`{
 		"Version": "2012-10-17",
@@ -65,29 +77,85 @@ func createQueue(ctx context.Context, client SQSAPI, queueName string, topicArn
 			"Resource": "*",
 			"Condition": {
 				"ArnEquals": {
-					"aws:SourceArn": "%s"
+					"aws:SourceArn": %s
 				}
 			}
 		}]
-	}`, topicArn)
+	}`, sourceArns)
 
 	queueAttributes := map[string]string{
 		"Policy": queuePolicy,
 	}
 
+	if settings.fifo {
+		queueName += ".fifo"
+		queueAttributes["FifoQueue"] = "true"
+		queueAttributes["ContentBasedDeduplication"] = strconv.FormatBool(settings.contentBasedDeduplication)
+	}
+
+	if redrivePolicy != "" {
+		queueAttributes["RedrivePolicy"] = redrivePolicy
+	}
+
+	span.SetAttributes(
+		attribute.String(traceNamespace+".queueName", queueName),
+		attribute.StringSlice(traceNamespace+".topicArns", topicArns),
+		attribute.Bool(traceNamespace+".isFIFO", settings.fifo),
+		attribute.Bool(traceNamespace+".contentBasedDeduplication", settings.contentBasedDeduplication),
+		attribute.String(traceNamespace+".redrivePolicy", redrivePolicy),
+	)
+
+	logger.Printf(
+		"Creating new queue...\n\tName: %s\n\tAllowing messages from topics: %s\n\tFIFO: %t\n\tContent-based deduplication: %t",
+		queueName,
+		strings.Join(topicArns, ", "),
+		settings.fifo,
+		settings.fifo && settings.contentBasedDeduplication,
+	)
+
+	result, err := client.CreateQueue(
+		ctx,
+		&sqs.CreateQueueInput{
+			QueueName:  aws.String(queueName),
+			Attributes: queueAttributes,
+		},
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	span.SetAttributes(attribute.String(traceNamespace+".queueUrl", *result.QueueUrl))
+
+	logger.Printf("Queue created with URL %s", *result.QueueUrl)
+
+	span.SetStatus(codes.Ok, "")
+	return *result.QueueUrl, nil
+}
+
+// createDeadLetterQueue creates a queue for messages that couldn't be processed. Its name is the name of the
+// queue it's for with "-dlq" appended. It has to be a FIFO queue if the queue it's for is a FIFO queue.
+func createDeadLetterQueue(ctx context.Context, client SQSAPI, queueName string, settings topicSettings) (string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "createDeadLetterQueue")
+	defer span.End()
+
+	isFIFO := settings.fifo
+	queueName = queueName + "-dlq"
+	queueAttributes := map[string]string{}
+
 	if isFIFO {
 		queueName += ".fifo"
 		queueAttributes["FifoQueue"] = "true"
-		queueAttributes["ContentBasedDeduplication"] = "true"
 	}
 
 	span.SetAttributes(
 		attribute.String(traceNamespace+".queueName", queueName),
-		attribute.String(traceNamespace+".topicArn", topicArn),
 		attribute.Bool(traceNamespace+".isFIFO", isFIFO),
 	)
 
-	logger.Printf("Creating new queue...\n\tName: %s\n\tAllowing messages from topic: %s\n\tFIFO: %t", queueName, topicArn, isFIFO)
+	logger.Printf("Creating new dead-letter queue...\n\tName: %s\n\tFIFO: %t", queueName, isFIFO)
 
 	result, err := client.CreateQueue(
 		ctx,
@@ -105,7 +173,72 @@ func createQueue(ctx context.Context, client SQSAPI, queueName string, topicArn
 
 	span.SetAttributes(attribute.String(traceNamespace+".queueUrl", *result.QueueUrl))
 
-	logger.Printf("Queue created with URL %s", *result.QueueUrl)
+	logger.Printf("Dead-letter queue created with URL %s", *result.QueueUrl)
+
+	span.SetStatus(codes.Ok, "")
+	return *result.QueueUrl, nil
+}
+
+func newRedrivePolicy(deadLetterQueueArn string, maxReceiveCount int) string {
+	redrivePolicy, _ := json.Marshal(map[string]string{
+		"deadLetterTargetArn": deadLetterQueueArn,
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+
+	return string(redrivePolicy)
+}
+
+// defaultQueueName returns the provided queue name or, if it's empty, a v4 UUID prefixed with "sns-listener-".
+func defaultQueueName(queueName string) string {
+	if queueName == "" {
+		return "sns-listener-" + uuid.NewString()
+	}
+
+	return queueName
+}
+
+// resolveQueueUrl returns the URL of a queue identified by either its URL or its ARN.
+func resolveQueueUrl(ctx context.Context, client SQSAPI, queue string) (string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "resolveQueueUrl")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".queue", queue))
+
+	if !strings.HasPrefix(queue, "arn:") {
+		span.SetAttributes(attribute.String(traceNamespace+".queueUrl", queue))
+		span.SetStatus(codes.Ok, "")
+		return queue, nil
+	}
+
+	// arn:partition:sqs:region:account-id:queue-name
+	parts := strings.Split(queue, ":")
+
+	if len(parts) != 6 || parts[2] != "sqs" {
+		err := fmt.Errorf("%s is not a valid SQS queue ARN", queue)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	logger.Printf("Looking up URL for queue %s...", queue)
+
+	result, err := client.GetQueueUrl(
+		ctx,
+		&sqs.GetQueueUrlInput{
+			QueueName:              aws.String(parts[5]),
+			QueueOwnerAWSAccountId: aws.String(parts[4]),
+		},
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	span.SetAttributes(attribute.String(traceNamespace+".queueUrl", *result.QueueUrl))
+
+	logger.Printf("Found queue with URL %s", *result.QueueUrl)
 
 	span.SetStatus(codes.Ok, "")
 	return *result.QueueUrl, nil
@@ -139,105 +272,678 @@ func getQueueArn(ctx context.Context, client SQSAPI, queueUrl string) (string, e
 	return result.Attributes[string(types.QueueAttributeNameQueueArn)], nil
 }
 
-func listenToQueue(ctx context.Context, client SQSAPI, queueUrl string, consumer Consumer, pollingInterval time.Duration) error {
-	logger.Printf("Starting to listen to queue. Fetching messages every %s...", pollingInterval.String())
+// getMaxReceiveCount returns the number of times a message can be received from the queue before it's moved to a
+// dead-letter queue, or 0 if the queue doesn't have a redrive policy.
+func getMaxReceiveCount(ctx context.Context, client SQSAPI, queueUrl string) (int, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "getMaxReceiveCount")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".queueUrl", queueUrl))
+
+	result, err := client.GetQueueAttributes(
+		ctx,
+		&sqs.GetQueueAttributesInput{
+			QueueUrl: &queueUrl,
+			AttributeNames: []types.QueueAttributeName{
+				types.QueueAttributeNameRedrivePolicy,
+			},
+		},
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	redrivePolicy, ok := result.Attributes[string(types.QueueAttributeNameRedrivePolicy)]
+
+	if !ok {
+		span.SetStatus(codes.Ok, "")
+		return 0, nil
+	}
+
+	span.SetAttributes(attribute.String(traceNamespace+".redrivePolicy", redrivePolicy))
+
+	// maxReceiveCount can be either a number or a string
+	var policy struct {
+		MaxReceiveCount json.Number `json:"maxReceiveCount"`
+	}
+
+	err = json.Unmarshal([]byte(redrivePolicy), &policy)
+
+	if err == nil {
+		var maxReceiveCount int64
+		maxReceiveCount, err = policy.MaxReceiveCount.Int64()
+
+		if err == nil {
+			span.SetStatus(codes.Ok, "")
+			return int(maxReceiveCount), nil
+		}
+	}
+
+	err = fmt.Errorf("unable to read redrive policy of queue %s: %w", queueUrl, err)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return 0, err
+}
+
+// receiveOptions controls how messages are received from the queue and passed to the handler.
+type receiveOptions struct {
+	pollingInterval    time.Duration
+	batchSize          int32
+	waitTime           time.Duration
+	concurrency        int
+	visibilityTimeout  time.Duration
+	rawMessageDelivery bool
+	// topicArn is the topic that messages come from when there's only one, since raw messages don't say
+	topicArn string
+	// deleteBeforeHandling is set for Consumers which can't report failures
+	deleteBeforeHandling bool
+	// peek never deletes messages and makes them visible again straight after they've been handled
+	peek bool
+	// seen tracks the messages that have already been handled when peeking
+	seen *seenMessages
+	// groups tracks the message groups with a message that failed to be handled
+	groups *messageGroups
+	// confirmer confirms pending subscriptions from their confirmation messages, it's nil when peeking
+	confirmer *subscriptionConfirmer
+}
+
+// receivedMessage is a message that a poller has received and is waiting to be handled.
+type receivedMessage struct {
+	types.Message
+	// batch identifies the receive that the message came from
+	batch uint64
+	// heartbeat stops extending the visibility timeout of the message
+	heartbeat func()
+}
+
+// stopHeartbeat stops extending the visibility timeout of the message if it's being extended.
+func (m receivedMessage) stopHeartbeat() {
+	if m.heartbeat != nil {
+		m.heartbeat()
+	}
+}
+
+// listenToQueue starts a poller and a handler for each level of concurrency. Pollers receive batches of messages
+// and hand them off to handlers. Messages with a group ID are always handed to the same handler so that they're
+// processed in the order they were received, other messages go to whichever handler is free first.
+func listenToQueue(ctx context.Context, client SQSAPI, queueUrl string, handler Handler, opts receiveOptions) error {
+	if opts.concurrency < 1 {
+		opts.concurrency = 1
+	}
+
+	logger.Printf(
+		"Starting to listen to queue. Receiving up to %d messages at a time with %d pollers and handlers, waiting up to %s for messages and backing off for %s when the queue is empty...",
+		opts.batchSize,
+		opts.concurrency,
+		opts.waitTime.String(),
+		opts.pollingInterval.String(),
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts.groups = newMessageGroups()
+
+	shared := make(chan receivedMessage)
+	grouped := make([]chan receivedMessage, opts.concurrency)
+	errCh := make(chan error, 2*opts.concurrency)
+	wg := sync.WaitGroup{}
+
+	dispatch := func(message receivedMessage) bool {
+		ch := shared
+
+		if groupId, ok := message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]; ok {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(groupId))
+			ch = grouped[h.Sum32()%uint32(len(grouped))]
+		}
+
+		select {
+		case ch <- message:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for i := range grouped {
+		grouped[i] = make(chan receivedMessage)
+	}
+
+	for i := range grouped {
+		wg.Add(2)
+
+		go func(own chan receivedMessage) {
+			defer wg.Done()
+			errCh <- handleMessages(ctx, client, queueUrl, handler, shared, own, opts)
+		}(grouped[i])
+
+		go func() {
+			defer wg.Done()
+			errCh <- pollQueue(ctx, client, queueUrl, dispatch, opts)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(errCh)
+	}()
+
+	var err error
+
+	for e := range errCh {
+		if e != nil && err == nil {
+			err = e
+			cancel()
+		}
+	}
+
+	if err == nil {
+		logger.Printf("Context cancelled, no longer listening to queue")
+	}
+
+	return err
+}
+
+// maxPeekBackoff is the longest a poller waits after only receiving messages that have already been seen.
+const maxPeekBackoff = 5 * time.Minute
+
+func pollQueue(ctx context.Context, client SQSAPI, queueUrl string, dispatch func(receivedMessage) bool, opts receiveOptions) error {
+	delay := time.Duration(0)
+	peekBackoff := opts.pollingInterval
+
 	for {
 		select {
-		case <-time.After(pollingInterval):
-			ctx, span := otel.Tracer(name).Start(ctx, "listenToQueue")
-			defer span.End()
-
-			span.SetAttributes(
-				attribute.String(traceNamespace+".queueUrl", queueUrl),
-				attribute.String(traceNamespace+".pollingInterval", pollingInterval.String()),
-			)
-			span.AddEvent("Receiving messages from queue")
-
-			receiveResult, err := client.ReceiveMessage(
-				ctx,
-				&sqs.ReceiveMessageInput{
-					MessageAttributeNames: []string{
-						string(types.QueueAttributeNameAll),
-					},
-					QueueUrl:            &queueUrl,
-					MaxNumberOfMessages: 1,
-					VisibilityTimeout:   int32(60),
-				},
-			)
+		case <-time.After(delay):
+			messages, err := receiveMessages(ctx, client, queueUrl, opts)
+
+			if isCancelled(err) {
+				logger.Print("Leaving receive loop early due to cancelled context")
+				return nil
+			}
 
 			if err != nil {
-				var cancelErr *smithy.CanceledError
+				return err
+			}
 
-				if errors.As(err, &cancelErr) {
-					logger.Print("Leaving receive loop early due to cancelled context")
+			batch := opts.groups.newBatch()
+			pending := make([]receivedMessage, 0, len(messages))
 
-					span.AddEvent("Leaving receive loop early due to cancelled context")
-					span.SetStatus(codes.Ok, "")
+			for _, message := range messages {
+				// When peeking, messages go back on the queue after being handled so they'll be received again
+				if opts.peek && !opts.seen.add(*message.MessageId) {
+					err = changeMessageVisibility(ctx, client, queueUrl, message, 0)
 
+					if isCancelled(err) {
+						return nil
+					}
+
+					if err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				received := receivedMessage{Message: message, batch: batch}
+
+				// Messages can wait behind the rest of the batch for a while so they need to be kept invisible straight away
+				if opts.visibilityTimeout > 0 {
+					received.heartbeat = startHeartbeat(ctx, client, queueUrl, message, opts.visibilityTimeout)
+				}
+
+				pending = append(pending, received)
+			}
+
+			for _, message := range pending {
+				if !dispatch(message) {
 					return nil
 				}
+			}
 
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				return err
+			// Only back off when the queue is empty, otherwise there are probably more messages waiting.
+			// Each receive of a seen message counts towards its max receive count, so back off for longer each time.
+			if len(pending) == 0 && len(messages) > 0 {
+				peekBackoff *= 2
+
+				if peekBackoff > maxPeekBackoff {
+					peekBackoff = maxPeekBackoff
+				}
+
+				delay = peekBackoff
+				logger.Printf("Only received messages that have already been seen, waiting %s before receiving again", delay.String())
+			} else if len(pending) == 0 {
+				delay = opts.pollingInterval
+			} else {
+				peekBackoff = opts.pollingInterval
+				delay = 0
 			}
 
-			span.SetAttributes(attribute.Int(traceNamespace+".messagesReceived", len(receiveResult.Messages)))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
 
-			for _, message := range receiveResult.Messages {
-				msgCtx, msgSpan := otel.Tracer(name).Start(ctx, "processMessage")
+func handleMessages(ctx context.Context, client SQSAPI, queueUrl string, handler Handler, shared <-chan receivedMessage, own <-chan receivedMessage, opts receiveOptions) error {
+	for {
+		var message receivedMessage
 
-				msgSpan.SetAttributes(
-					attribute.String(traceNamespace+".queueUrl", queueUrl),
-					attribute.String(traceNamespace+".messageId", *message.MessageId),
-					attribute.String(traceNamespace+".receiptHandle", *message.ReceiptHandle),
-				)
+		select {
+		case message = <-own:
+		case message = <-shared:
+		case <-ctx.Done():
+			return nil
+		}
 
-				_, err := client.DeleteMessage(
-					msgCtx,
-					&sqs.DeleteMessageInput{
-						QueueUrl:      &queueUrl,
-						ReceiptHandle: message.ReceiptHandle,
-					},
-				)
+		groupId, isGrouped := message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
 
-				if err != nil {
-					var cancelErr *smithy.CanceledError
+		var failed bool
+		var err error
+
+		// Handling the rest of a group after a failure would put it out of order, so it's released to be received again
+		if isGrouped && opts.groups.hasFailed(groupId, message.batch) {
+			logger.Printf("Releasing message %s because an earlier message in group %s was not handled successfully", *message.MessageId, groupId)
+			message.stopHeartbeat()
+			err = changeMessageVisibility(ctx, client, queueUrl, message.Message, 0)
+		} else {
+			failed, err = processMessage(ctx, client, queueUrl, handler, message, opts)
+		}
 
-					if errors.As(err, &cancelErr) {
-						logger.Print("Leaving receive loop early due to cancelled context")
+		if isCancelled(err) {
+			logger.Print("Leaving receive loop early due to cancelled context")
+			return nil
+		}
 
-						span.AddEvent("Leaving receive loop early due to cancelled context")
-						span.SetStatus(codes.Ok, "")
+		if err != nil {
+			return err
+		}
 
-						return nil
+		if isGrouped && failed {
+			opts.groups.fail(groupId, message.batch)
+		}
+	}
+}
+
+func receiveMessages(ctx context.Context, client SQSAPI, queueUrl string, opts receiveOptions) ([]types.Message, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "receiveMessages")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String(traceNamespace+".queueUrl", queueUrl),
+		attribute.String(traceNamespace+".pollingInterval", opts.pollingInterval.String()),
+		attribute.Int(traceNamespace+".batchSize", int(opts.batchSize)),
+		attribute.String(traceNamespace+".waitTime", opts.waitTime.String()),
+		attribute.String(traceNamespace+".visibilityTimeout", opts.visibilityTimeout.String()),
+	)
+	span.AddEvent("Receiving messages from queue")
+
+	receiveResult, err := client.ReceiveMessage(
+		ctx,
+		&sqs.ReceiveMessageInput{
+			AttributeNames: []types.QueueAttributeName{
+				types.QueueAttributeNameAll,
+			},
+			MessageAttributeNames: []string{
+				string(types.QueueAttributeNameAll),
+			},
+			QueueUrl:            &queueUrl,
+			MaxNumberOfMessages: opts.batchSize,
+			VisibilityTimeout:   int32(opts.visibilityTimeout / time.Second),
+			WaitTimeSeconds:     int32(opts.waitTime / time.Second),
+		},
+	)
+
+	if isCancelled(err) {
+		span.AddEvent("Leaving receive loop early due to cancelled context")
+		span.SetStatus(codes.Ok, "")
+		return nil, err
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int(traceNamespace+".messagesReceived", len(receiveResult.Messages)))
+	span.SetStatus(codes.Ok, "")
+
+	return receiveResult.Messages, nil
+}
+
+// processMessage passes the message to the handler and then deletes it or makes it visible again.
+// It reports whether the message failed to be handled and was left in the queue.
+func processMessage(ctx context.Context, client SQSAPI, queueUrl string, handler Handler, message receivedMessage, opts receiveOptions) (bool, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "processMessage")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String(traceNamespace+".queueUrl", queueUrl),
+		attribute.String(traceNamespace+".messageId", *message.MessageId),
+		attribute.String(traceNamespace+".receiptHandle", *message.ReceiptHandle),
+		attribute.Bool(traceNamespace+".deleteBeforeHandling", opts.deleteBeforeHandling),
+		attribute.Bool(traceNamespace+".peek", opts.peek),
+	)
+
+	if opts.peek {
+		handlerErr := handler.HandleMessage(ctx, newMessageContent(message.Message, opts.rawMessageDelivery, opts.topicArn))
+
+		if handlerErr != nil {
+			logger.Printf("Message %s was not handled successfully: %s", *message.MessageId, handlerErr.Error())
+			span.RecordError(handlerErr)
+		}
+
+		message.stopHeartbeat()
+
+		err := changeMessageVisibility(ctx, client, queueUrl, message.Message, 0)
+
+		if err != nil {
+			return false, err
+		}
+
+		span.SetStatus(codes.Ok, "")
+		return false, nil
+	}
+
+	if confirmation, ok := opts.confirmer.confirmationFor(message.Body); ok {
+		message.stopHeartbeat()
+
+		err := opts.confirmer.confirm(ctx, confirmation)
+
+		if err != nil {
+			// The confirmation is left in the queue to be tried again once it's visible
+			logger.Printf("Unable to confirm subscription to topic %s: %s", confirmation.TopicArn, err.Error())
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return false, nil
+		}
+
+		span.SetStatus(codes.Ok, "")
+		return false, deleteMessage(ctx, client, queueUrl, message.Message)
+	}
+
+	if opts.deleteBeforeHandling {
+		message.stopHeartbeat()
+
+		err := deleteMessage(ctx, client, queueUrl, message.Message)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Messages that are still in the queue are kept invisible by the heartbeat for as long as the handler is running
+	handlerErr := handler.HandleMessage(ctx, newMessageContent(message.Message, opts.rawMessageDelivery, opts.topicArn))
+
+	message.stopHeartbeat()
+
+	if handlerErr != nil {
+		logger.Printf("Message %s was not handled successfully: %s", *message.MessageId, handlerErr.Error())
+
+		span.RecordError(handlerErr)
+		span.SetStatus(codes.Error, handlerErr.Error())
+
+		if opts.deleteBeforeHandling {
+			return false, nil
+		}
+
+		var retryErr *RetryError
+
+		if errors.As(handlerErr, &retryErr) {
+			logger.Printf("Making message %s visible again in %s", *message.MessageId, retryErr.Delay.String())
+			return true, changeMessageVisibility(ctx, client, queueUrl, message.Message, int32(retryErr.Delay/time.Second))
+		}
+
+		return true, nil
+	}
+
+	if !opts.deleteBeforeHandling {
+		err := deleteMessage(ctx, client, queueUrl, message.Message)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return false, nil
+}
+
+func deleteMessage(ctx context.Context, client SQSAPI, queueUrl string, message types.Message) error {
+	span := trace.SpanFromContext(ctx)
+
+	_, err := client.DeleteMessage(
+		ctx,
+		&sqs.DeleteMessageInput{
+			QueueUrl:      &queueUrl,
+			ReceiptHandle: message.ReceiptHandle,
+		},
+	)
+
+	if isCancelled(err) {
+		span.AddEvent("Leaving receive loop early due to cancelled context")
+		span.SetStatus(codes.Ok, "")
+		return err
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.AddEvent("Deleted message from queue")
+
+	return nil
+}
+
+// startHeartbeat extends the visibility timeout of the message every half a visibility timeout until the returned
+// function is called or the context is cancelled. The returned function blocks until the heartbeat has stopped.
+func startHeartbeat(ctx context.Context, client SQSAPI, queueUrl string, message types.Message, visibilityTimeout time.Duration) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	// SQS only supports whole seconds so round up to make sure the message stays invisible until the next heartbeat
+	seconds := int32(math.Ceil(visibilityTimeout.Seconds()))
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(visibilityTimeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				logger.Printf("Message %s is still being handled, extending its visibility timeout by %ds", *message.MessageId, seconds)
+
+				err := changeMessageVisibility(ctx, client, queueUrl, message, seconds)
+
+				if err != nil {
+					if !isCancelled(err) {
+						logger.Printf("Unable to extend visibility timeout of message %s: %s", *message.MessageId, err.Error())
 					}
 
-					msgSpan.RecordError(err)
-					msgSpan.SetStatus(codes.Error, err.Error())
-					msgSpan.End()
-					return err
+					return
 				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-				consumer.OnMessage(
-					msgCtx,
-					MessageContent{
-						Body: message.Body,
-						Id:   message.MessageId,
-					})
+	return func() {
+		cancel()
+		<-done
+	}
+}
 
-				msgSpan.SetStatus(codes.Ok, "")
-				msgSpan.End()
-			}
+func changeMessageVisibility(ctx context.Context, client SQSAPI, queueUrl string, message types.Message, visibilityTimeout int32) error {
+	span := trace.SpanFromContext(ctx)
 
-			span.SetStatus(codes.Ok, "")
-			span.End()
+	_, err := client.ChangeMessageVisibility(
+		ctx,
+		&sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          &queueUrl,
+			ReceiptHandle:     message.ReceiptHandle,
+			VisibilityTimeout: visibilityTimeout,
+		},
+	)
 
-		case <-ctx.Done():
-			logger.Printf("Context cancelled, no longer listening to queue")
-			return nil
+	if isCancelled(err) {
+		span.AddEvent("Leaving receive loop early due to cancelled context")
+		return err
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.AddEvent("Changed message visibility", trace.WithAttributes(
+		attribute.Int(traceNamespace+".visibilityTimeout", int(visibilityTimeout)),
+	))
+
+	return nil
+}
+
+// seenMessages is a set of message IDs which forgets the oldest IDs once it reaches its limit.
+type seenMessages struct {
+	mu    sync.Mutex
+	ids   map[string]struct{}
+	order []string
+	limit int
+}
+
+func newSeenMessages(limit int) *seenMessages {
+	return &seenMessages{ids: map[string]struct{}{}, limit: limit}
+}
+
+// add returns true if the message ID hadn't been seen before.
+func (s *seenMessages) add(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+
+	if len(s.order) == s.limit {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	s.ids[id] = struct{}{}
+	s.order = append(s.order, id)
+
+	return true
+}
+
+// messageGroups numbers the batches received by pollers and tracks the message groups which had a message fail
+// to be handled in each batch. A group stops being tracked once a newer batch contains it.
+type messageGroups struct {
+	mu      sync.Mutex
+	batches uint64
+	failed  map[string]uint64
+}
+
+func newMessageGroups() *messageGroups {
+	return &messageGroups{failed: map[string]uint64{}}
+}
+
+// newBatch returns the number for the next batch received from the queue.
+func (g *messageGroups) newBatch() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.batches++
+
+	return g.batches
+}
+
+// fail marks the group as having a message that failed to be handled in the batch.
+func (g *messageGroups) fail(groupId string, batch uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.failed[groupId] = batch
+}
+
+// hasFailed returns true if a message from the group failed to be handled in the batch.
+func (g *messageGroups) hasFailed(groupId string, batch uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	failedBatch, ok := g.failed[groupId]
+
+	// A newer batch for the group means the failed batch has been released
+	if ok && failedBatch < batch {
+		delete(g.failed, groupId)
+	}
+
+	return ok && failedBatch == batch
+}
+
+func isCancelled(err error) bool {
+	var cancelErr *smithy.CanceledError
+
+	return errors.As(err, &cancelErr)
+}
+
+// newMessageContent decodes the message. The topic ARN is used for messages that don't say which topic they came from.
+func newMessageContent(message types.Message, rawMessageDelivery bool, topicArn string) MessageContent {
+	content := MessageContent{
+		Body:       message.Body,
+		Id:         message.MessageId,
+		Attributes: message.Attributes,
+	}
+
+	if topicArn != "" {
+		content.TopicArn = aws.String(topicArn)
+	}
+
+	if rawMessageDelivery {
+		content.Message = message.Body
+		content.MessageAttributes = make(map[string]MessageAttribute, len(message.MessageAttributes))
+
+		for k, v := range message.MessageAttributes {
+			attr := MessageAttribute{Type: aws.ToString(v.DataType)}
+
+			if v.BinaryValue != nil {
+				attr.Value = base64.StdEncoding.EncodeToString(v.BinaryValue)
+			} else {
+				attr.Value = aws.ToString(v.StringValue)
+			}
+
+			content.MessageAttributes[k] = attr
 		}
+
+		return content
 	}
+
+	if message.Body == nil {
+		return content
+	}
+
+	notification, err := parseNotification(*message.Body)
+
+	if err != nil {
+		logger.Printf("Unable to decode SNS notification from message %s: %s", aws.ToString(message.MessageId), err.Error())
+		return content
+	}
+
+	content.Message = &notification.Message
+	content.MessageAttributes = notification.MessageAttributes
+	content.TopicArn = &notification.TopicArn
+	content.Notification = notification
+
+	return content
 }
 
 func deleteQueue(ctx context.Context, client SQSAPI, queueUrl string) error {
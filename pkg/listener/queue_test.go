@@ -2,8 +2,12 @@ package listener
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
 	"regexp"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,6 +31,12 @@ func (c SQSAPIImpl) CreateQueue(ctx context.Context,
 		return nil, errors.New("Invalid queue name")
 	}
 
+	if redrivePolicy, ok := params.Attributes["RedrivePolicy"]; ok {
+		if !json.Valid([]byte(redrivePolicy)) {
+			return nil, errors.New("Invalid redrive policy")
+		}
+	}
+
 	return &sqs.CreateQueueOutput{
 		QueueUrl: aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/" + queueName),
 	}, nil
@@ -37,7 +47,8 @@ func (c SQSAPIImpl) DeleteQueue(ctx context.Context,
 	optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
 	queueUrl := *params.QueueUrl
 
-	if queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue" {
+	if queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue" ||
+		queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue-dlq" {
 		return &sqs.DeleteQueueOutput{}, nil
 	}
 
@@ -52,7 +63,16 @@ func (c SQSAPIImpl) GetQueueAttributes(ctx context.Context,
 	if queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue" {
 		return &sqs.GetQueueAttributesOutput{
 			Attributes: map[string]string{
-				"QueueArn": "arn:aws:sqs:us-east-1:123456789012:valid-queue",
+				"QueueArn":      "arn:aws:sqs:us-east-1:123456789012:valid-queue",
+				"RedrivePolicy": `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:valid-queue-dlq","maxReceiveCount":5}`,
+			},
+		}, nil
+	}
+
+	if queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue-dlq" {
+		return &sqs.GetQueueAttributesOutput{
+			Attributes: map[string]string{
+				"QueueArn": "arn:aws:sqs:us-east-1:123456789012:valid-queue-dlq",
 			},
 		}, nil
 	}
@@ -73,11 +93,25 @@ func (c SQSAPIImpl) ReceiveMessage(ctx context.Context,
 	optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
 	queueUrl := *params.QueueUrl
 
+	if params.MaxNumberOfMessages < 1 || params.MaxNumberOfMessages > 10 {
+		return nil, errors.New("Invalid number of messages to receive")
+	}
+
+	if params.WaitTimeSeconds < 0 || params.WaitTimeSeconds > 20 {
+		return nil, errors.New("Invalid wait time")
+	}
+
 	if queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue" ||
 		queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/breaks-on-teardown" {
+		if len(c.messages) > int(params.MaxNumberOfMessages) {
+			return &sqs.ReceiveMessageOutput{
+				Messages: c.messages[:params.MaxNumberOfMessages],
+			}, nil
+		}
+
 		if len(c.messages) > 0 {
 			return &sqs.ReceiveMessageOutput{
-				Messages: []types.Message{c.messages[0]},
+				Messages: c.messages,
 			}, nil
 		}
 
@@ -105,6 +139,194 @@ func (c SQSAPIImpl) DeleteMessage(ctx context.Context,
 	return nil, errors.New("Couldn't delete messages from that queue!")
 }
 
+func (c SQSAPIImpl) ChangeMessageVisibility(ctx context.Context,
+	params *sqs.ChangeMessageVisibilityInput,
+	optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	if params.VisibilityTimeout < 0 || params.VisibilityTimeout > 43200 {
+		return nil, errors.New("Invalid visibility timeout")
+	}
+
+	queueUrl := *params.QueueUrl
+	if queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue" ||
+		queueUrl == "https://sqs.us-east-1.amazonaws.com/123456789012/breaks-on-teardown" {
+		for _, v := range c.messages {
+			if *v.ReceiptHandle == *params.ReceiptHandle && *params.ReceiptHandle == *v.Body+"-handle" {
+				return &sqs.ChangeMessageVisibilityOutput{}, nil
+			}
+		}
+	}
+
+	return nil, errors.New("Couldn't change visibility of messages in that queue!")
+}
+
+func (c SQSAPIImpl) GetQueueUrl(ctx context.Context,
+	params *sqs.GetQueueUrlInput,
+	optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	if *params.QueueOwnerAWSAccountId == "123456789012" &&
+		(*params.QueueName == "valid-queue" || *params.QueueName == "breaks-on-teardown") {
+		return &sqs.GetQueueUrlOutput{
+			QueueUrl: aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/" + *params.QueueName),
+		}, nil
+	}
+
+	return nil, errors.New("Couldn't find that queue!")
+}
+
+// FIFOSQSAPIImpl behaves like a FIFO queue, it won't deliver messages from a message group while
+// another message from that group is still in flight. Messages received with a visibility timeout
+// are delivered again once it expires.
+type FIFOSQSAPIImpl struct {
+	SQSAPIImpl
+
+	mu                    sync.Mutex
+	queued                []types.Message
+	inFlight              map[string]string
+	deadlines             map[string]time.Time
+	receiveCount          int
+	visibilityChanges     map[string]int32
+	visibilityChangeCount int
+}
+
+func (c *FIFOSQSAPIImpl) ReceiveMessage(ctx context.Context,
+	params *sqs.ReceiveMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	received := []types.Message{}
+	blocked := map[string]bool{}
+
+	c.receiveCount++
+
+	for receiptHandle, deadline := range c.deadlines {
+		if time.Now().After(deadline) {
+			delete(c.inFlight, receiptHandle)
+			delete(c.deadlines, receiptHandle)
+		}
+	}
+
+	for _, groupId := range c.inFlight {
+		blocked[groupId] = true
+	}
+
+	for _, message := range c.queued {
+		if len(received) == int(params.MaxNumberOfMessages) {
+			break
+		}
+
+		groupId := message.Attributes["MessageGroupId"]
+
+		if _, ok := c.inFlight[*message.ReceiptHandle]; ok || blocked[groupId] {
+			blocked[groupId] = true
+			continue
+		}
+
+		received = append(received, message)
+	}
+
+	for _, message := range received {
+		c.inFlight[*message.ReceiptHandle] = message.Attributes["MessageGroupId"]
+		c.setDeadline(*message.ReceiptHandle, params.VisibilityTimeout)
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: received}, nil
+}
+
+func (c *FIFOSQSAPIImpl) DeleteMessage(ctx context.Context,
+	params *sqs.DeleteMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, message := range c.queued {
+		if *message.ReceiptHandle == *params.ReceiptHandle {
+			c.queued = append(c.queued[:i], c.queued[i+1:]...)
+			delete(c.inFlight, *params.ReceiptHandle)
+			return &sqs.DeleteMessageOutput{}, nil
+		}
+	}
+
+	return nil, errors.New("Couldn't delete messages from that queue!")
+}
+
+func (c *FIFOSQSAPIImpl) ChangeMessageVisibility(ctx context.Context,
+	params *sqs.ChangeMessageVisibilityInput,
+	optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.inFlight[*params.ReceiptHandle]; !ok {
+		return nil, errors.New("Message is not in flight")
+	}
+
+	if c.visibilityChanges == nil {
+		c.visibilityChanges = map[string]int32{}
+	}
+
+	c.visibilityChanges[*params.ReceiptHandle] = params.VisibilityTimeout
+	c.visibilityChangeCount++
+
+	if params.VisibilityTimeout == 0 {
+		delete(c.inFlight, *params.ReceiptHandle)
+	}
+
+	c.setDeadline(*params.ReceiptHandle, params.VisibilityTimeout)
+
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+// setDeadline tracks when an in flight message becomes visible again, a visibility timeout of 0 is never tracked.
+func (c *FIFOSQSAPIImpl) setDeadline(receiptHandle string, visibilityTimeout int32) {
+	if c.deadlines == nil {
+		c.deadlines = map[string]time.Time{}
+	}
+
+	if visibilityTimeout == 0 {
+		delete(c.deadlines, receiptHandle)
+		return
+	}
+
+	c.deadlines[receiptHandle] = time.Now().Add(time.Duration(visibilityTimeout) * time.Second)
+}
+
+type OrderedListenerImpl struct {
+	mu       sync.Mutex
+	received map[string][]int
+	count    int
+	failOnce map[string]bool
+}
+
+func (c *OrderedListenerImpl) OnMessage(ctx context.Context, m MessageContent) {
+	time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+	var groupId string
+	var sequence int
+
+	_, _ = fmt.Sscanf(*m.Body, "%s %d", &groupId, &sequence)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.received[groupId] = append(c.received[groupId], sequence)
+	c.count++
+}
+
+// HandleMessage fails the first time it's given a message in failOnce, otherwise it behaves like OnMessage.
+func (c *OrderedListenerImpl) HandleMessage(ctx context.Context, m MessageContent) error {
+	c.mu.Lock()
+
+	if c.failOnce[*m.Body] {
+		delete(c.failOnce, *m.Body)
+		c.mu.Unlock()
+		return Retry(errors.New("failed"), 0)
+	}
+
+	c.mu.Unlock()
+
+	c.OnMessage(ctx, m)
+	return nil
+}
+
 type ListenerImpl struct {
 	messages chan MessageContent
 }
@@ -117,51 +339,128 @@ func (c ListenerImpl) GetPollingInterval(ctx context.Context) time.Duration {
 	return 10 * time.Millisecond
 }
 
+// CreatingSQSAPIImpl records the attributes of the queues it creates.
+type CreatingSQSAPIImpl struct {
+	SQSAPIImpl
+	attributes map[string]string
+}
+
+func (c *CreatingSQSAPIImpl) CreateQueue(ctx context.Context,
+	params *sqs.CreateQueueInput,
+	optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	c.attributes = params.Attributes
+
+	return c.SQSAPIImpl.CreateQueue(ctx, params, optFns...)
+}
+
 func TestCreateQueue(t *testing.T) {
 	tests := map[string]struct {
-		shouldErr      bool
-		queueName      string
-		topicArn       string
-		queueUrlRegexp string
+		shouldErr                         bool
+		queueName                         string
+		topicArns                         []string
+		settings                          topicSettings
+		redrivePolicy                     string
+		queueUrlRegexp                    string
+		expectedContentBasedDeduplication string
 	}{
 		"generated queue name": {
 			false,
 			"",
-			"arn:aws:sns:us-east-1:123456789012:example-topic",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic"},
+			topicSettings{},
+			"",
 			"https://sqs.us-east-1.amazonaws.com/123456789012/sns-listener-[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}",
+			"",
 		},
 		"generated FIFO queue name": {
 			false,
 			"",
-			"arn:aws:sns:us-east-1:123456789012:example-topic.fifo",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic.fifo"},
+			topicSettings{true, true},
+			"",
 			"https://sqs.us-east-1.amazonaws.com/123456789012/sns-listener-[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}\\.fifo",
+			"true",
 		},
 		"overridden queue name": {
 			false,
 			"test-queue-name",
-			"arn:aws:sns:us-east-1:123456789012:example-topic",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic"},
+			topicSettings{},
+			"",
 			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name",
+			"",
 		},
 		"overridden FIFO queue name": {
 			false,
 			"test-queue-name",
-			"arn:aws:sns:us-east-1:123456789012:example-topic.fifo",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic.fifo"},
+			topicSettings{true, true},
+			"",
+			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name.fifo",
+			"true",
+		},
+		"FIFO queue without content-based deduplication": {
+			false,
+			"test-queue-name",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic.fifo"},
+			topicSettings{true, false},
+			"",
+			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name.fifo",
+			"false",
+		},
+		"queue with redrive policy": {
+			false,
+			"test-queue-name",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic"},
+			topicSettings{},
+			`{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:test-queue-name-dlq","maxReceiveCount":"5"}`,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name",
+			"",
+		},
+		"invalid redrive policy": {
+			true,
+			"test-queue-name",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic"},
+			topicSettings{},
+			"not a redrive policy",
+			"",
+			"",
+		},
+		"multiple topics": {
+			false,
+			"test-queue-name",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic", "arn:aws:sns:us-east-1:123456789012:other-topic"},
+			topicSettings{},
+			"",
+			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name",
+			"",
+		},
+		"multiple FIFO topics": {
+			false,
+			"test-queue-name",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic.fifo", "arn:aws:sns:us-east-1:123456789012:other-topic.fifo"},
+			topicSettings{true, true},
+			"",
 			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name.fifo",
+			"true",
 		},
 		"invalid queue name": {
 			true,
 			"?<>",
-			"arn:aws:sns:us-east-1:123456789012:example-topic",
+			[]string{"arn:aws:sns:us-east-1:123456789012:example-topic"},
+			topicSettings{},
+			"",
+			"",
 			"",
 		},
 	}
 
-	client := &SQSAPIImpl{}
 	ctx := context.TODO()
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			queueUrl, err := createQueue(ctx, client, test.queueName, test.topicArn)
+			client := &CreatingSQSAPIImpl{}
+			queueUrl, err := createQueue(ctx, client, test.queueName, test.topicArns, test.settings, test.redrivePolicy)
 
 			if err != nil && !test.shouldErr {
 				t.Fatalf(
@@ -184,25 +483,42 @@ func TestCreateQueue(t *testing.T) {
 						test.queueUrlRegexp,
 					)
 				}
+
+				if client.attributes["ContentBasedDeduplication"] != test.expectedContentBasedDeduplication {
+					t.Fatalf(
+						"Expected ContentBasedDeduplication %q but got %q",
+						test.expectedContentBasedDeduplication,
+						client.attributes["ContentBasedDeduplication"],
+					)
+				}
 			}
 		})
 	}
 }
 
-func TestGetQueueArn(t *testing.T) {
+func TestCreateDeadLetterQueue(t *testing.T) {
 	tests := map[string]struct {
-		shouldErr   bool
-		queueUrl    string
-		expectedArn string
+		shouldErr        bool
+		queueName        string
+		settings         topicSettings
+		expectedQueueUrl string
 	}{
-		"valid queue": {
+		"dead-letter queue": {
 			false,
-			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
-			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+			"test-queue-name",
+			topicSettings{},
+			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name-dlq",
 		},
-		"invalid queue": {
+		"FIFO dead-letter queue": {
+			false,
+			"test-queue-name",
+			topicSettings{true, true},
+			"https://sqs.us-east-1.amazonaws.com/123456789012/test-queue-name-dlq.fifo",
+		},
+		"invalid queue name": {
 			true,
-			"https://sqs.us-east-1.amazonaws.com/123456789012/invalid-queue",
+			"?<>",
+			topicSettings{},
 			"",
 		},
 	}
@@ -212,7 +528,7 @@ func TestGetQueueArn(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			result, err := getQueueArn(ctx, client, test.queueUrl)
+			queueUrl, err := createDeadLetterQueue(ctx, client, test.queueName, test.settings)
 
 			if err != nil && !test.shouldErr {
 				t.Fatalf(
@@ -226,11 +542,11 @@ func TestGetQueueArn(t *testing.T) {
 			}
 
 			if err == nil && !test.shouldErr {
-				if result != test.expectedArn {
+				if queueUrl != test.expectedQueueUrl {
 					t.Fatalf(
-						"Queue ARN %s did not match expected ARN %s",
-						result,
-						test.expectedArn,
+						"Queue URL %s did not match expected URL %s",
+						queueUrl,
+						test.expectedQueueUrl,
 					)
 				}
 			}
@@ -238,87 +554,993 @@ func TestGetQueueArn(t *testing.T) {
 	}
 }
 
-func TestListenToQueue(t *testing.T) {
+func TestResolveQueueUrl(t *testing.T) {
 	tests := map[string]struct {
-		shouldErr bool
-		queueUrl  string
-		messages  []types.Message
+		shouldErr   bool
+		queue       string
+		expectedUrl string
 	}{
-		"valid queue with valid receipts": {
+		"queue URL": {
 			false,
 			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
-			[]types.Message{
-				{
-					Body:          aws.String("foo"),
-					MessageId:     aws.String("foo"),
-					ReceiptHandle: aws.String("foo-handle"),
-				},
-			},
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
 		},
-		"empty queue": {
+		"queue ARN": {
 			false,
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
 			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
-			[]types.Message{},
 		},
-		"invalid queue": {
+		"queue ARN in another account": {
 			true,
-			"https://sqs.us-east-1.amazonaws.com/123456789012/invalid-queue",
-			[]types.Message{
-				{
-					Body:          aws.String("foo"),
-					MessageId:     aws.String("foo"),
-					ReceiptHandle: aws.String("foo-handle"),
-				},
-			},
+			"arn:aws:sqs:us-east-1:210987654321:valid-queue",
+			"",
 		},
-		"valid queue with invalid receipts": {
+		"invalid queue ARN": {
 			true,
-			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
-			[]types.Message{
-				{
-					Body:          aws.String("foo"),
-					MessageId:     aws.String("foo"),
-					ReceiptHandle: aws.String("bar-handle"),
-				},
-			},
+			"arn:aws:sns:us-east-1:123456789012:valid-queue",
+			"",
 		},
 	}
 
-	client := SQSAPIImpl{}
-	ctx := context.Background()
+	client := &SQSAPIImpl{}
+	ctx := context.TODO()
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			ctx, cancel := context.WithCancel(ctx)
-			client.messages = test.messages
-			consumer := ListenerImpl{}
-			consumer.messages = make(chan MessageContent, 1)
-			errCh := make(chan error, 1)
+			result, err := resolveQueueUrl(ctx, client, test.queue)
 
-			go func() {
-				errCh <- listenToQueue(
-					ctx,
-					client,
-					test.queueUrl,
-					consumer,
-					10*time.Millisecond,
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
 				)
-			}()
-
-			for len(errCh) == 0 && len(consumer.messages) < len(test.messages) {
 			}
 
-			cancel()
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error but got no error")
+			}
 
-			if len(errCh) > 0 && !test.shouldErr {
-				t.Fatalf(
-					"Expected no error but got %s",
-					(<-errCh).Error(),
-				)
+			if err == nil && !test.shouldErr {
+				if result != test.expectedUrl {
+					t.Fatalf(
+						"Queue URL %s did not match expected URL %s",
+						result,
+						test.expectedUrl,
+					)
+				}
 			}
+		})
+	}
+}
 
-			if len(errCh) == 0 && test.shouldErr {
-				t.Fatal("Expected error but got no error")
+func TestGetQueueArn(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr   bool
+		queueUrl    string
+		expectedArn string
+	}{
+		"valid queue": {
+			false,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+			"arn:aws:sqs:us-east-1:123456789012:valid-queue",
+		},
+		"invalid queue": {
+			true,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/invalid-queue",
+			"",
+		},
+	}
+
+	client := &SQSAPIImpl{}
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := getQueueArn(ctx, client, test.queueUrl)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatalf("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result != test.expectedArn {
+					t.Fatalf(
+						"Queue ARN %s did not match expected ARN %s",
+						result,
+						test.expectedArn,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMaxReceiveCount(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr       bool
+		queueUrl        string
+		maxReceiveCount int
+	}{
+		"queue with redrive policy":    {false, "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue", 5},
+		"queue without redrive policy": {false, "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue-dlq", 0},
+		"invalid queue":                {true, "https://sqs.us-east-1.amazonaws.com/123456789012/invalid-queue", 0},
+	}
+
+	ctx := context.TODO()
+	client := SQSAPIImpl{}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := getMaxReceiveCount(ctx, client, test.queueUrl)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if result != test.maxReceiveCount {
+				t.Fatalf(
+					"Expected max receive count %d but got %d",
+					test.maxReceiveCount,
+					result,
+				)
+			}
+		})
+	}
+}
+
+func TestPollQueuePeek(t *testing.T) {
+	client := &FIFOSQSAPIImpl{
+		queued: []types.Message{
+			{
+				Body:          aws.String("foo"),
+				MessageId:     aws.String("foo"),
+				ReceiptHandle: aws.String("foo-handle"),
+			},
+		},
+		inFlight: map[string]string{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	dispatched := 0
+
+	// Handling a peeked message makes it visible again straight away
+	dispatch := func(message receivedMessage) bool {
+		dispatched++
+		_ = changeMessageVisibility(ctx, client, "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue", message.Message, 0)
+		return true
+	}
+
+	err := pollQueue(
+		ctx,
+		client,
+		"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+		dispatch,
+		receiveOptions{
+			pollingInterval: 10 * time.Millisecond,
+			batchSize:       10,
+			peek:            true,
+			seen:            newSeenMessages(10),
+			groups:          newMessageGroups(),
+		},
+	)
+
+	if err != nil {
+		t.Fatalf(
+			"Expected no error but got %s",
+			err.Error(),
+		)
+	}
+
+	if dispatched != 1 {
+		t.Fatalf(
+			"Expected message to be dispatched once but it was dispatched %d times",
+			dispatched,
+		)
+	}
+
+	if client.visibilityChanges["foo-handle"] != 0 {
+		t.Fatalf(
+			"Expected message to be made visible again but its visibility timeout was %d",
+			client.visibilityChanges["foo-handle"],
+		)
+	}
+
+	// Backing off from 20ms and doubling each time only leaves room for a handful of receives
+	if client.receiveCount > 6 {
+		t.Fatalf(
+			"Expected poller to back off after receiving seen messages but it received %d times",
+			client.receiveCount,
+		)
+	}
+
+	if len(client.queued) != 1 {
+		t.Fatal("Expected message to be left in the queue but it was deleted")
+	}
+}
+
+func TestListenToQueue(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		queueUrl  string
+		batchSize int32
+		waitTime  time.Duration
+		messages  []types.Message
+	}{
+		"valid queue with valid receipts": {
+			false,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+			1,
+			0,
+			[]types.Message{
+				{
+					Body:          aws.String("foo"),
+					MessageId:     aws.String("foo"),
+					ReceiptHandle: aws.String("foo-handle"),
+				},
+			},
+		},
+		"batch of messages with long polling": {
+			false,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+			10,
+			20 * time.Second,
+			[]types.Message{
+				{
+					Body:          aws.String("foo"),
+					MessageId:     aws.String("foo"),
+					ReceiptHandle: aws.String("foo-handle"),
+				},
+				{
+					Body:          aws.String("bar"),
+					MessageId:     aws.String("bar"),
+					ReceiptHandle: aws.String("bar-handle"),
+				},
+			},
+		},
+		"empty queue": {
+			false,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+			1,
+			0,
+			[]types.Message{},
+		},
+		"invalid queue": {
+			true,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/invalid-queue",
+			1,
+			0,
+			[]types.Message{
+				{
+					Body:          aws.String("foo"),
+					MessageId:     aws.String("foo"),
+					ReceiptHandle: aws.String("foo-handle"),
+				},
+			},
+		},
+		"valid queue with invalid receipts": {
+			true,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+			1,
+			0,
+			[]types.Message{
+				{
+					Body:          aws.String("foo"),
+					MessageId:     aws.String("foo"),
+					ReceiptHandle: aws.String("bar-handle"),
+				},
+			},
+		},
+		"invalid batch size": {
+			true,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+			11,
+			0,
+			[]types.Message{
+				{
+					Body:          aws.String("foo"),
+					MessageId:     aws.String("foo"),
+					ReceiptHandle: aws.String("foo-handle"),
+				},
+			},
+		},
+	}
+
+	client := SQSAPIImpl{}
+	ctx := context.Background()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			client.messages = test.messages
+			consumer := ListenerImpl{}
+			consumer.messages = make(chan MessageContent, len(test.messages))
+			errCh := make(chan error, 1)
+
+			go func() {
+				errCh <- listenToQueue(
+					ctx,
+					client,
+					test.queueUrl,
+					consumerHandler{consumer: consumer},
+					receiveOptions{
+						pollingInterval:      10 * time.Millisecond,
+						batchSize:            test.batchSize,
+						waitTime:             test.waitTime,
+						deleteBeforeHandling: true,
+					},
+				)
+			}()
+
+			for len(errCh) == 0 && len(consumer.messages) < len(test.messages) {
+			}
+
+			cancel()
+
+			if len(errCh) > 0 && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					(<-errCh).Error(),
+				)
+			}
+
+			if len(errCh) == 0 && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+		})
+	}
+}
+
+func TestListenToQueueMessageGroupOrdering(t *testing.T) {
+	tests := map[string]struct {
+		deleteBeforeHandling bool
+		failOnce             []string
+	}{
+		"consumer":                 {true, nil},
+		"handler":                  {false, nil},
+		"handler with failures":    {false, []string{"red 3", "green 0", "blue 9"}},
+		"handler with retry storm": {false, []string{"red 1", "red 2", "red 3", "green 5", "green 6"}},
+	}
+
+	groups := []string{"red", "green", "blue"}
+	messagesPerGroup := 10
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FIFOSQSAPIImpl{inFlight: map[string]string{}}
+
+			for i := 0; i < messagesPerGroup; i++ {
+				for _, groupId := range groups {
+					body := fmt.Sprintf("%s %d", groupId, i)
+
+					client.queued = append(client.queued, types.Message{
+						Attributes:    map[string]string{"MessageGroupId": groupId},
+						Body:          aws.String(body),
+						MessageId:     aws.String(body),
+						ReceiptHandle: aws.String(body + "-handle"),
+					})
+				}
+			}
+
+			consumer := &OrderedListenerImpl{received: map[string][]int{}, failOnce: map[string]bool{}}
+
+			for _, body := range test.failOnce {
+				consumer.failOnce[body] = true
+			}
+
+			var handler Handler = consumer
+
+			if test.deleteBeforeHandling {
+				handler = consumerHandler{consumer: consumer}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			errCh := make(chan error, 1)
+
+			go func() {
+				errCh <- listenToQueue(
+					ctx,
+					client,
+					"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue.fifo",
+					handler,
+					receiveOptions{
+						pollingInterval:      10 * time.Millisecond,
+						batchSize:            10,
+						concurrency:          4,
+						deleteBeforeHandling: test.deleteBeforeHandling,
+					},
+				)
+			}()
+
+			for {
+				consumer.mu.Lock()
+				count := consumer.count
+				consumer.mu.Unlock()
+
+				if count == len(groups)*messagesPerGroup || ctx.Err() != nil {
+					break
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+
+			cancel()
+
+			err := <-errCh
+
+			if err != nil {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if len(consumer.failOnce) != 0 {
+				t.Fatalf(
+					"Expected handler to fail on every message in %v but it didn't",
+					test.failOnce,
+				)
+			}
+
+			for _, groupId := range groups {
+				received := consumer.received[groupId]
+
+				if len(received) != messagesPerGroup {
+					t.Fatalf(
+						"Expected %d messages for group %s but got %d",
+						messagesPerGroup,
+						groupId,
+						len(received),
+					)
+				}
+
+				for i, sequence := range received {
+					if sequence != i {
+						t.Fatalf(
+							"Messages for group %s were processed out of order: %v",
+							groupId,
+							received,
+						)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestListenToQueueVisibilityTimeout(t *testing.T) {
+	messages := 4
+	client := &FIFOSQSAPIImpl{inFlight: map[string]string{}}
+
+	for i := 0; i < messages; i++ {
+		body := fmt.Sprintf("red %d", i)
+
+		client.queued = append(client.queued, types.Message{
+			Attributes:    map[string]string{"MessageGroupId": "red"},
+			Body:          aws.String(body),
+			MessageId:     aws.String(body),
+			ReceiptHandle: aws.String(body + "-handle"),
+		})
+	}
+
+	mu := sync.Mutex{}
+	handled := []string{}
+
+	// The last messages in the batch wait longer than the visibility timeout before they're handled
+	handler := HandlerFunc(func(ctx context.Context, msg MessageContent) error {
+		time.Sleep(400 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled = append(handled, *msg.Body)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- listenToQueue(
+			ctx,
+			client,
+			"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue.fifo",
+			handler,
+			receiveOptions{
+				pollingInterval:   10 * time.Millisecond,
+				batchSize:         10,
+				concurrency:       2,
+				visibilityTimeout: time.Second,
+			},
+		)
+	}()
+
+	for {
+		client.mu.Lock()
+		remaining := len(client.queued)
+		client.mu.Unlock()
+
+		if remaining == 0 || ctx.Err() != nil || len(errCh) > 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	err := <-errCh
+
+	if err != nil {
+		t.Fatalf(
+			"Expected no error but got %s",
+			err.Error(),
+		)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(handled) != messages {
+		t.Fatalf(
+			"Expected %d messages to be handled once each but got %v",
+			messages,
+			handled,
+		)
+	}
+
+	for i, body := range handled {
+		if body != fmt.Sprintf("red %d", i) {
+			t.Fatalf(
+				"Messages were handled out of order: %v",
+				handled,
+			)
+		}
+	}
+}
+
+func TestProcessSubscriptionConfirmation(t *testing.T) {
+	confirmation := `{"Type": "SubscriptionConfirmation", "MessageId": "foo", "TopicArn": "%s", "Token": "%s", "SubscribeURL": "https://example.com"}`
+
+	tests := map[string]struct {
+		body          string
+		confirm       bool
+		expectHandled bool
+		expectDeleted bool
+	}{
+		"confirmed": {
+			fmt.Sprintf(confirmation, "arn:aws:sns:us-east-1:210987654321:other-account-topic", "valid-token"),
+			true,
+			false,
+			true,
+		},
+		"confirmation failed": {
+			fmt.Sprintf(confirmation, "arn:aws:sns:us-east-1:210987654321:other-account-topic", "invalid-token"),
+			true,
+			false,
+			false,
+		},
+		"confirmation for another topic": {
+			fmt.Sprintf(confirmation, "arn:aws:sns:us-east-1:210987654321:unknown-topic", "valid-token"),
+			true,
+			true,
+			true,
+		},
+		"confirmation while peeking": {
+			fmt.Sprintf(confirmation, "arn:aws:sns:us-east-1:210987654321:other-account-topic", "valid-token"),
+			false,
+			true,
+			true,
+		},
+		"notification": {
+			`{"Type": "Notification", "MessageId": "foo", "TopicArn": "arn:aws:sns:us-east-1:210987654321:other-account-topic", "Message": "bar"}`,
+			true,
+			true,
+			true,
+		},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FIFOSQSAPIImpl{
+				queued: []types.Message{
+					{
+						Body:          aws.String(test.body),
+						MessageId:     aws.String("foo"),
+						ReceiptHandle: aws.String("foo-handle"),
+					},
+				},
+				inFlight: map[string]string{"foo-handle": ""},
+			}
+
+			opts := receiveOptions{}
+
+			if test.confirm {
+				opts.confirmer = newSubscriptionConfirmer(&SNSAPIImpl{}, []string{"arn:aws:sns:us-east-1:210987654321:other-account-topic"})
+			}
+
+			handled := false
+
+			_, err := processMessage(
+				ctx,
+				client,
+				"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+				HandlerFunc(func(ctx context.Context, msg MessageContent) error {
+					handled = true
+					return nil
+				}),
+				receivedMessage{Message: client.queued[0]},
+				opts,
+			)
+
+			if err != nil {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if handled != test.expectHandled {
+				t.Fatalf(
+					"Expected message to be handled: %t but was handled: %t",
+					test.expectHandled,
+					handled,
+				)
+			}
+
+			if deleted := len(client.queued) == 0; deleted != test.expectDeleted {
+				t.Fatalf(
+					"Expected message to be deleted: %t but was deleted: %t",
+					test.expectDeleted,
+					deleted,
+				)
+			}
+		})
+	}
+}
+
+func TestProcessMessage(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr            bool
+		deleteBeforeHandling bool
+		peek                 bool
+		handlerErr           error
+		receiptHandle        string
+		expectDeleted        bool
+		expectFailed         bool
+		expectVisibility     int32
+	}{
+		"handled successfully":              {false, false, false, nil, "foo-handle", true, false, -1},
+		"handler failed":                    {false, false, false, errors.New("failed"), "foo-handle", false, true, -1},
+		"handler failed with retry":         {false, false, false, Retry(errors.New("failed"), 0), "foo-handle", false, true, 0},
+		"handler failed with delayed retry": {false, false, false, fmt.Errorf("wrapped: %w", Retry(errors.New("failed"), 30*time.Second)), "foo-handle", false, true, 30},
+		"deleted before handling":           {false, true, false, nil, "foo-handle", true, false, -1},
+		"deleted before failed handling":    {false, true, false, Retry(errors.New("failed"), 0), "foo-handle", true, false, -1},
+		"peeked":                            {false, false, true, nil, "foo-handle", false, false, 0},
+		"peeked with failed handling":       {false, false, true, errors.New("failed"), "foo-handle", false, false, 0},
+		"invalid receipt":                   {true, false, false, nil, "bar-handle", false, false, -1},
+		"invalid receipt on retry":          {true, false, false, Retry(errors.New("failed"), 0), "bar-handle", false, false, -1},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FIFOSQSAPIImpl{
+				queued: []types.Message{
+					{
+						Body:          aws.String("foo"),
+						MessageId:     aws.String("foo"),
+						ReceiptHandle: aws.String("foo-handle"),
+					},
+				},
+				inFlight: map[string]string{"foo-handle": ""},
+			}
+
+			handled := false
+
+			failed, err := processMessage(
+				ctx,
+				client,
+				"https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue",
+				HandlerFunc(func(ctx context.Context, msg MessageContent) error {
+					handled = true
+					return test.handlerErr
+				}),
+				receivedMessage{
+					Message: types.Message{
+						Body:          aws.String("foo"),
+						MessageId:     aws.String("foo"),
+						ReceiptHandle: aws.String(test.receiptHandle),
+					},
+				},
+				receiveOptions{deleteBeforeHandling: test.deleteBeforeHandling, peek: test.peek},
+			)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if !handled {
+					t.Fatal("Expected message to be handled but it wasn't")
+				}
+
+				if failed != test.expectFailed {
+					t.Fatalf(
+						"Expected message to have failed: %t but failed: %t",
+						test.expectFailed,
+						failed,
+					)
+				}
+
+				if deleted := len(client.queued) == 0; deleted != test.expectDeleted {
+					t.Fatalf(
+						"Expected message to be deleted: %t but was deleted: %t",
+						test.expectDeleted,
+						deleted,
+					)
+				}
+
+				visibility, changed := client.visibilityChanges["foo-handle"]
+
+				if (test.expectVisibility < 0 && changed) || (test.expectVisibility >= 0 && visibility != test.expectVisibility) {
+					t.Fatalf(
+						"Expected visibility timeout %d but got %d (changed: %t)",
+						test.expectVisibility,
+						visibility,
+						changed,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	tests := map[string]struct {
+		handlerDuration    time.Duration
+		visibilityTimeout  time.Duration
+		expectedHeartbeats int
+	}{
+		"handler finishes before heartbeat": {10 * time.Millisecond, 200 * time.Millisecond, 0},
+		"handler outlives visibility":       {250 * time.Millisecond, 200 * time.Millisecond, 2},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &FIFOSQSAPIImpl{inFlight: map[string]string{"foo-handle": ""}}
+			message := types.Message{
+				Body:          aws.String("foo"),
+				MessageId:     aws.String("foo"),
+				ReceiptHandle: aws.String("foo-handle"),
+			}
+
+			stop := startHeartbeat(ctx, client, "https://sqs.us-east-1.amazonaws.com/123456789012/valid-queue", message, test.visibilityTimeout)
+			time.Sleep(test.handlerDuration)
+			stop()
+
+			client.mu.Lock()
+			heartbeats := client.visibilityChangeCount
+			client.mu.Unlock()
+
+			if heartbeats != test.expectedHeartbeats {
+				t.Fatalf(
+					"Expected %d heartbeats but got %d",
+					test.expectedHeartbeats,
+					heartbeats,
+				)
+			}
+
+			if heartbeats > 0 && client.visibilityChanges["foo-handle"] != 1 {
+				t.Fatalf(
+					"Expected visibility timeout to be rounded up to 1 second but got %d",
+					client.visibilityChanges["foo-handle"],
+				)
+			}
+
+			time.Sleep(test.visibilityTimeout)
+
+			client.mu.Lock()
+			defer client.mu.Unlock()
+
+			if client.visibilityChangeCount != heartbeats {
+				t.Fatal("Expected heartbeat to stop but it didn't")
+			}
+		})
+	}
+}
+
+func TestSeenMessages(t *testing.T) {
+	seen := newSeenMessages(2)
+
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"foo", true},
+		{"foo", false},
+		{"bar", true},
+		{"foo", false},
+		{"baz", true},
+		{"foo", true},
+		{"baz", false},
+	}
+
+	for _, test := range tests {
+		result := seen.add(test.id)
+
+		if result != test.expected {
+			t.Fatalf(
+				"Expected %t when adding %s but got %t",
+				test.expected,
+				test.id,
+				result,
+			)
+		}
+	}
+}
+
+func TestNewMessageContent(t *testing.T) {
+	tests := map[string]struct {
+		rawMessageDelivery   bool
+		topicArn             string
+		message              types.Message
+		expectedMessage      string
+		expectedAttributes   map[string]MessageAttribute
+		expectedTopicArn     string
+		expectedNotification bool
+	}{
+		"SNS envelope": {
+			false,
+			"",
+			types.Message{
+				Body:       aws.String(`{"Type": "Notification", "MessageId": "foo", "TopicArn": "arn:aws:sns:us-east-1:123456789012:my-topic", "Message": "bar", "MessageAttributes": {"colour": {"Type": "String", "Value": "blue"}}}`),
+				MessageId:  aws.String("foo"),
+				Attributes: map[string]string{"SentTimestamp": "1680173422443"},
+			},
+			"bar",
+			map[string]MessageAttribute{"colour": {"String", "blue"}},
+			"arn:aws:sns:us-east-1:123456789012:my-topic",
+			true,
+		},
+		"SNS envelope from one of many topics": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:other-topic",
+			types.Message{
+				Body:      aws.String(`{"Type": "Notification", "MessageId": "foo", "TopicArn": "arn:aws:sns:us-east-1:123456789012:my-topic", "Message": "bar"}`),
+				MessageId: aws.String("foo"),
+			},
+			"bar",
+			map[string]MessageAttribute{},
+			"arn:aws:sns:us-east-1:123456789012:my-topic",
+			true,
+		},
+		"raw message": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:my-topic",
+			types.Message{
+				Body:      aws.String("bar"),
+				MessageId: aws.String("foo"),
+				MessageAttributes: map[string]types.MessageAttributeValue{
+					"colour": {DataType: aws.String("String"), StringValue: aws.String("blue")},
+					"bytes":  {DataType: aws.String("Binary"), BinaryValue: []byte("baz")},
+				},
+			},
+			"bar",
+			map[string]MessageAttribute{"colour": {"String", "blue"}, "bytes": {"Binary", "YmF6"}},
+			"arn:aws:sns:us-east-1:123456789012:my-topic",
+			false,
+		},
+		"raw message from many topics": {
+			true,
+			"",
+			types.Message{
+				Body:      aws.String("bar"),
+				MessageId: aws.String("foo"),
+			},
+			"bar",
+			map[string]MessageAttribute{},
+			"",
+			false,
+		},
+		"not an SNS envelope": {
+			false,
+			"",
+			types.Message{
+				Body:      aws.String("bar"),
+				MessageId: aws.String("foo"),
+			},
+			"",
+			map[string]MessageAttribute{},
+			"",
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := newMessageContent(test.message, test.rawMessageDelivery, test.topicArn)
+
+			if aws.ToString(result.Message) != test.expectedMessage {
+				t.Fatalf(
+					"Message %s did not match expected message %s",
+					aws.ToString(result.Message),
+					test.expectedMessage,
+				)
+			}
+
+			if len(result.MessageAttributes) != len(test.expectedAttributes) {
+				t.Fatalf(
+					"Expected %d message attributes but got %d",
+					len(test.expectedAttributes),
+					len(result.MessageAttributes),
+				)
+			}
+
+			for k, v := range test.expectedAttributes {
+				if result.MessageAttributes[k] != v {
+					t.Fatalf(
+						"Message attribute %s was %+v but expected %+v",
+						k,
+						result.MessageAttributes[k],
+						v,
+					)
+				}
+			}
+
+			if aws.ToString(result.TopicArn) != test.expectedTopicArn {
+				t.Fatalf(
+					"Topic ARN %s did not match expected topic ARN %s",
+					aws.ToString(result.TopicArn),
+					test.expectedTopicArn,
+				)
+			}
+
+			if len(result.Attributes) != len(test.message.Attributes) {
+				t.Fatalf(
+					"Attributes %v did not match expected attributes %v",
+					result.Attributes,
+					test.message.Attributes,
+				)
+			}
+
+			if (result.Notification != nil) != test.expectedNotification {
+				t.Fatalf(
+					"Expected notification to be decoded: %t but got %+v",
+					test.expectedNotification,
+					result.Notification,
+				)
 			}
 		})
 	}
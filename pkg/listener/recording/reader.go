@@ -0,0 +1,116 @@
+package recording
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// A Reader reads the records of a recording in the order they were written, following on to each numbered file
+// that the Recorder moved on to.
+// It should not be instantiated directly, instead the Open() function should be used.
+type Reader struct {
+	path    string
+	index   int
+	file    *os.File
+	decoder *json.Decoder
+	read    int
+}
+
+// Open opens the first file of a recording, which is the path that was provided to NewRecorder.
+func Open(path string) (*Reader, error) {
+	r := &Reader{path: path}
+
+	err := r.open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Read returns the next record. It returns io.EOF once every file in the recording has been read.
+func (r *Reader) Read() (Record, error) {
+	if r.file == nil {
+		return Record{}, io.EOF
+	}
+
+	var record Record
+
+	err := r.decoder.Decode(&record)
+
+	if errors.Is(err, io.EOF) {
+		r.file.Close()
+		r.file = nil
+		r.index++
+
+		err = r.open()
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return Record{}, io.EOF
+		}
+
+		if err != nil {
+			return Record{}, err
+		}
+
+		return r.Read()
+	}
+
+	r.read++
+
+	if err != nil {
+		return Record{}, fmt.Errorf("unable to read record %d of %s: %w", r.read, filePath(r.path, r.index), err)
+	}
+
+	return record, nil
+}
+
+// Close closes the file currently being read.
+func (r *Reader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
+
+func (r *Reader) open() error {
+	path := filePath(r.path, r.index)
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = file
+
+	if strings.HasSuffix(path, ".gz") {
+		reader, err = gzip.NewReader(file)
+
+		// A recording that was interrupted before anything was written to it is empty rather than compressed
+		if errors.Is(err, io.EOF) {
+			reader, err = file, nil
+		}
+
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("unable to decompress %s: %w", path, err)
+		}
+	}
+
+	r.file = file
+	r.decoder = json.NewDecoder(reader)
+	r.read = 0
+
+	return nil
+}
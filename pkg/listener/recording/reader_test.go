@@ -0,0 +1,109 @@
+package recording
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+func TestReader(t *testing.T) {
+	tests := map[string]struct {
+		fileName string
+		maxSize  int64
+		messages []string
+	}{
+		"single file":            {"messages.ndjson", 0, []string{"first", "second", "third"}},
+		"rotated":                {"messages.ndjson", 1, []string{"first", "second", "third"}},
+		"rotated and compressed": {"messages.ndjson.gz", 1, []string{"first", "second", "third"}},
+		"empty":                  {"messages.ndjson.gz", 0, []string{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.fileName)
+			recorder, err := NewRecorder(path, test.maxSize)
+
+			if err != nil {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			for _, message := range test.messages {
+				_ = recorder.Record(listener.MessageContent{Id: aws.String(message), Body: aws.String(message)}, time.Now())
+			}
+
+			_ = recorder.Close()
+
+			reader, err := Open(path)
+
+			if err != nil {
+				t.Fatalf("Expected no error opening but got %s", err.Error())
+			}
+
+			defer reader.Close()
+
+			read := []string{}
+
+			for {
+				record, err := reader.Read()
+
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				if err != nil {
+					t.Fatalf("Expected no error reading but got %s", err.Error())
+				}
+
+				read = append(read, record.MessageId)
+			}
+
+			if len(read) != len(test.messages) {
+				t.Fatalf("Read messages %v did not match expected values %v", read, test.messages)
+			}
+
+			for i := range read {
+				if read[i] != test.messages[i] {
+					t.Fatalf("Read messages %v did not match expected values %v", read, test.messages)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	malformedPath := filepath.Join(dir, "malformed.ndjson")
+	_ = os.WriteFile(malformedPath, []byte("{\"MessageId\": \"first\"}\nnot json\n"), 0600)
+
+	_, err := Open(filepath.Join(dir, "missing.ndjson"))
+
+	if err == nil {
+		t.Fatal("Expected error opening a missing file but got no error")
+	}
+
+	reader, err := Open(malformedPath)
+
+	if err != nil {
+		t.Fatalf("Expected no error opening but got %s", err.Error())
+	}
+
+	defer reader.Close()
+
+	_, err = reader.Read()
+
+	if err != nil {
+		t.Fatalf("Expected no error reading the first record but got %s", err.Error())
+	}
+
+	_, err = reader.Read()
+
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("Expected error reading the second record but got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package recording
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+// A Recorder writes messages to newline-delimited JSON files. Files with a path ending in ".gz" are gzip compressed.
+// When a file reaches the maximum size the Recorder moves on to a new file, numbered from 1 and placed before the
+// file's extension, e.g. "messages.ndjson.gz" is followed by "messages.1.ndjson.gz". Existing files are never
+// overwritten. Every record is flushed as it's written so a recording can be read even if it was interrupted.
+// It should not be instantiated directly, instead the NewRecorder() function should be used.
+type Recorder struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	index   int
+	file    *os.File
+	gz      *gzip.Writer
+	written int64
+}
+
+// NewRecorder creates the first file of a recording. A maximum size in bytes of 0 keeps recording to the one file.
+func NewRecorder(path string, maxSize int64) (*Recorder, error) {
+	r := &Recorder{path: path, maxSize: maxSize}
+
+	err := r.open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Record writes a message to the recording, moving on to a new file first if the current one is full.
+// It's safe to call from several goroutines.
+func (r *Recorder) Record(msg listener.MessageContent, receivedAt time.Time) error {
+	line, err := json.Marshal(NewRecord(msg, receivedAt))
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+
+	if r.maxSize > 0 && r.written >= r.maxSize {
+		err = r.close()
+
+		if err != nil {
+			return err
+		}
+
+		r.index++
+		err = r.open()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.writer().Write(append(line, '\n'))
+
+	if err != nil {
+		return err
+	}
+
+	if r.gz != nil {
+		return r.gz.Flush()
+	}
+
+	return nil
+}
+
+// Close flushes and closes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	return r.close()
+}
+
+func (r *Recorder) open() error {
+	path := filePath(r.path, r.index)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	r.file = file
+	r.written = 0
+	r.gz = nil
+
+	if strings.HasSuffix(path, ".gz") {
+		r.gz = gzip.NewWriter(countingWriter{file, &r.written})
+	}
+
+	return nil
+}
+
+func (r *Recorder) close() error {
+	var err error
+
+	if r.gz != nil {
+		err = r.gz.Close()
+	}
+
+	closeErr := r.file.Close()
+	r.file = nil
+
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+func (r *Recorder) writer() io.Writer {
+	if r.gz != nil {
+		return r.gz
+	}
+
+	return countingWriter{r.file, &r.written}
+}
+
+// countingWriter counts the bytes written to w so that the size of a file is known without asking for it.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+
+	return n, err
+}
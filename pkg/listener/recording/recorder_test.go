@@ -0,0 +1,132 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+// readRecording reads every record from a file written by a recorder.
+func readRecording(t *testing.T, path string) []Record {
+	file, err := os.Open(path)
+
+	if err != nil {
+		t.Fatalf("Expected no error opening %s but got %s", path, err.Error())
+	}
+
+	defer file.Close()
+
+	var r io.Reader = file
+
+	if filepath.Ext(path) == ".gz" {
+		r, err = gzip.NewReader(file)
+
+		if err != nil {
+			t.Fatalf("Expected no error decompressing %s but got %s", path, err.Error())
+		}
+	}
+
+	records := []Record{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		var rec Record
+
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+
+		if err != nil {
+			t.Fatalf("Expected no error decoding %s but got %s", scanner.Text(), err.Error())
+		}
+
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func TestRecorder(t *testing.T) {
+	tests := map[string]struct {
+		fileName      string
+		maxSize       int64
+		expectedFiles map[string]int
+	}{
+		"single file": {
+			"messages.ndjson",
+			0,
+			map[string]int{"messages.ndjson": 3},
+		},
+		"compressed": {
+			"messages.ndjson.gz",
+			0,
+			map[string]int{"messages.ndjson.gz": 3},
+		},
+		"rotated": {
+			"messages.ndjson",
+			1,
+			map[string]int{"messages.ndjson": 1, "messages.1.ndjson": 1, "messages.2.ndjson": 1},
+		},
+		"rotated and compressed": {
+			"messages.ndjson.gz",
+			1,
+			map[string]int{"messages.ndjson.gz": 1, "messages.1.ndjson.gz": 1, "messages.2.ndjson.gz": 1},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			r, err := NewRecorder(filepath.Join(dir, test.fileName), test.maxSize)
+
+			if err != nil {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			for _, message := range []string{"first", "second", "third"} {
+				err = r.Record(listener.MessageContent{Id: aws.String(message), Body: aws.String(message)}, time.Now())
+
+				if err != nil {
+					t.Fatalf("Expected no error recording but got %s", err.Error())
+				}
+			}
+
+			err = r.Close()
+
+			if err != nil {
+				t.Fatalf("Expected no error closing but got %s", err.Error())
+			}
+
+			entries, _ := os.ReadDir(dir)
+
+			if len(entries) != len(test.expectedFiles) {
+				t.Fatalf("Expected %d files but found %d", len(test.expectedFiles), len(entries))
+			}
+
+			for fileName, expectedCount := range test.expectedFiles {
+				records := readRecording(t, filepath.Join(dir, fileName))
+
+				if len(records) != expectedCount {
+					t.Fatalf("Expected %d records in %s but got %d", expectedCount, fileName, len(records))
+				}
+			}
+		})
+	}
+}
+
+func TestRecorderExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.ndjson")
+	_ = os.WriteFile(path, []byte("{}\n"), 0600)
+
+	_, err := NewRecorder(path, 0)
+
+	if err == nil {
+		t.Fatal("Expected error but got no error")
+	}
+}
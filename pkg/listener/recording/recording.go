@@ -0,0 +1,101 @@
+// Package recording writes messages received by a Listener to newline-delimited JSON files and replays them,
+// either by publishing them to a topic again or by passing them to a listener.Consumer without AWS.
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+const name string = "github.com/whatsfordinner/aws-sns-listener/pkg/listener/recording"
+const traceNamespace string = "aws-sns-listener"
+
+// A Record is a received message as it's written to a recording, one per line. It has the same shape as the SNS
+// envelope where the two overlap so that a recording can be read wherever SNS notifications can.
+type Record struct {
+	ReceivedAt time.Time
+	// MessageId is the ID of the SQS message, the ID of the SNS message is in the Body unless it was delivered raw
+	MessageId         string
+	TopicArn          string                               `json:",omitempty"`
+	Subject           string                               `json:",omitempty"`
+	SequenceNumber    string                               `json:",omitempty"`
+	Message           string                               `json:",omitempty"`
+	MessageAttributes map[string]listener.MessageAttribute `json:",omitempty"`
+	// Attributes are the SQS system attributes, such as MessageGroupId and MessageDeduplicationId
+	Attributes map[string]string `json:",omitempty"`
+	Body       string
+}
+
+// NewRecord returns the Record of a message received at receivedAt.
+func NewRecord(msg listener.MessageContent, receivedAt time.Time) Record {
+	r := Record{
+		ReceivedAt:        receivedAt.UTC(),
+		MessageId:         aws.ToString(msg.Id),
+		TopicArn:          aws.ToString(msg.TopicArn),
+		Message:           aws.ToString(msg.Message),
+		MessageAttributes: msg.MessageAttributes,
+		Attributes:        msg.Attributes,
+		Body:              aws.ToString(msg.Body),
+		// The SQS sequence number is used when the SNS one isn't available with raw message delivery
+		SequenceNumber: msg.Attributes["SequenceNumber"],
+	}
+
+	if msg.Notification != nil {
+		r.Subject = msg.Notification.Subject
+
+		if msg.Notification.SequenceNumber != "" {
+			r.SequenceNumber = msg.Notification.SequenceNumber
+		}
+	}
+
+	return r
+}
+
+// MessageContent returns the message as it was when it was received. The Notification is decoded from the Body
+// again if it's an SNS notification.
+func (r Record) MessageContent() listener.MessageContent {
+	content := listener.MessageContent{
+		Body:              aws.String(r.Body),
+		Id:                aws.String(r.MessageId),
+		MessageAttributes: r.MessageAttributes,
+		Attributes:        r.Attributes,
+	}
+
+	if r.Message != "" {
+		content.Message = aws.String(r.Message)
+	}
+
+	if r.TopicArn != "" {
+		content.TopicArn = aws.String(r.TopicArn)
+	}
+
+	notification := new(listener.Notification)
+
+	if json.Unmarshal([]byte(r.Body), notification) == nil && notification.Type != "" && notification.TopicArn != "" {
+		content.Notification = notification
+	}
+
+	return content
+}
+
+// filePath returns the path of a file in a recording, numbering every file after the first before its extension.
+func filePath(path string, index int) string {
+	if index == 0 {
+		return path
+	}
+
+	dir, base := filepath.Split(path)
+	name, extension, found := strings.Cut(base, ".")
+
+	if found {
+		extension = "." + extension
+	}
+
+	return fmt.Sprintf("%s%s.%d%s", dir, name, index, extension)
+}
@@ -0,0 +1,140 @@
+package recording
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+func TestFilePath(t *testing.T) {
+	tests := map[string]struct {
+		path     string
+		index    int
+		expected string
+	}{
+		"first file":        {"messages.ndjson", 0, "messages.ndjson"},
+		"numbered file":     {"messages.ndjson", 2, "messages.2.ndjson"},
+		"compressed file":   {"dir/messages.ndjson.gz", 1, "dir/messages.1.ndjson.gz"},
+		"without extension": {"messages", 1, "messages.1"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := filePath(test.path, test.index)
+
+			if result != test.expected {
+				t.Fatalf("Path %s did not match expected value %s", result, test.expected)
+			}
+		})
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	receivedAt := time.Date(2023, 3, 30, 10, 50, 22, 0, time.UTC)
+
+	tests := map[string]struct {
+		msg      listener.MessageContent
+		expected Record
+	}{
+		"SNS envelope": {
+			listener.MessageContent{
+				Id:                aws.String("sqs-id"),
+				Body:              aws.String("envelope"),
+				Message:           aws.String("hello"),
+				TopicArn:          aws.String("arn:aws:sns:us-east-1:123456789012:orders.fifo"),
+				MessageAttributes: map[string]listener.MessageAttribute{"colour": {Type: "String", Value: "blue"}},
+				Attributes:        map[string]string{"MessageGroupId": "group", "SequenceNumber": "2"},
+				Notification:      &listener.Notification{Subject: "greeting", SequenceNumber: "1"},
+			},
+			Record{
+				ReceivedAt:        receivedAt,
+				MessageId:         "sqs-id",
+				TopicArn:          "arn:aws:sns:us-east-1:123456789012:orders.fifo",
+				Subject:           "greeting",
+				SequenceNumber:    "1",
+				Message:           "hello",
+				MessageAttributes: map[string]listener.MessageAttribute{"colour": {Type: "String", Value: "blue"}},
+				Attributes:        map[string]string{"MessageGroupId": "group", "SequenceNumber": "2"},
+				Body:              "envelope",
+			},
+		},
+		"raw message": {
+			listener.MessageContent{
+				Id:         aws.String("sqs-id"),
+				Body:       aws.String("hello"),
+				Message:    aws.String("hello"),
+				Attributes: map[string]string{"SequenceNumber": "2"},
+			},
+			Record{
+				ReceivedAt:     receivedAt,
+				MessageId:      "sqs-id",
+				SequenceNumber: "2",
+				Message:        "hello",
+				Attributes:     map[string]string{"SequenceNumber": "2"},
+				Body:           "hello",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := NewRecord(test.msg, receivedAt)
+
+			resultJSON, _ := json.Marshal(result)
+			expectedJSON, _ := json.Marshal(test.expected)
+
+			if string(resultJSON) != string(expectedJSON) {
+				t.Fatalf("Record %s did not match expected value %s", resultJSON, expectedJSON)
+			}
+		})
+	}
+}
+
+func TestMessageContent(t *testing.T) {
+	tests := map[string]struct {
+		record               Record
+		expectedTopicArn     string
+		expectedNotification bool
+	}{
+		"SNS envelope": {
+			Record{
+				MessageId: "sqs-id",
+				TopicArn:  "arn:aws:sns:us-east-1:123456789012:orders",
+				Message:   "hello",
+				Body:      `{"Type": "Notification", "MessageId": "sns-id", "TopicArn": "arn:aws:sns:us-east-1:123456789012:orders", "Message": "hello"}`,
+			},
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			true,
+		},
+		"raw message": {
+			Record{
+				MessageId: "sqs-id",
+				Message:   "hello",
+				Body:      "hello",
+			},
+			"",
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := test.record.MessageContent()
+
+			if *result.Id != test.record.MessageId || *result.Body != test.record.Body || aws.ToString(result.Message) != test.record.Message {
+				t.Fatalf("Message content %+v did not match record %+v", result, test.record)
+			}
+
+			if aws.ToString(result.TopicArn) != test.expectedTopicArn {
+				t.Fatalf("Topic ARN %s did not match expected topic ARN %s", aws.ToString(result.TopicArn), test.expectedTopicArn)
+			}
+
+			if (result.Notification != nil) != test.expectedNotification {
+				t.Fatalf("Expected notification to be decoded: %t but got %+v", test.expectedNotification, result.Notification)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package recording
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// A Target is where Replay sends each record.
+type Target func(ctx context.Context, record Record) error
+
+// SNSAPI is the subset of the SNS API used to publish records to a topic.
+type SNSAPI interface {
+	Publish(ctx context.Context,
+		params *sns.PublishInput,
+		optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+type replayOptions struct {
+	speed float64
+}
+
+// An Option is a function that changes how records are replayed.
+type Option func(o *replayOptions)
+
+// WithSpeed scales the time waited between records, e.g. 2 replays records twice as fast as they were received.
+// A speed of 0 replays records without waiting.
+// Defaults to 1, which waits as long between records as passed between receiving them.
+func WithSpeed(speed float64) Option {
+	return func(o *replayOptions) {
+		if speed < 0 {
+			speed = 0
+		}
+
+		o.speed = speed
+	}
+}
+
+// Replay sends every record read from r to target in order, waiting between them so that they arrive with the
+// same timing they were received with. It returns once every record has been replayed, the first error from target
+// or when ctx is cancelled.
+func Replay(ctx context.Context, r *Reader, target Target, opts ...Option) error {
+	o := &replayOptions{speed: 1}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var previous time.Time
+
+	for {
+		record, err := r.Read()
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = wait(ctx, record.ReceivedAt.Sub(previous), o.speed, !previous.IsZero())
+
+		if err != nil {
+			return err
+		}
+
+		previous = record.ReceivedAt
+
+		err = target(ctx, record)
+
+		if err != nil {
+			return fmt.Errorf("unable to replay message %s: %w", record.MessageId, err)
+		}
+	}
+}
+
+// wait waits for the gap between two records at the provided speed. Records received concurrently can be recorded
+// slightly out of order so negative gaps aren't waited for.
+func wait(ctx context.Context, gap time.Duration, speed float64, hasPrevious bool) error {
+	if !hasPrevious || speed == 0 || gap <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(float64(gap) / speed))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ConsumerTarget passes each record to the consumer as the message that was originally received.
+func ConsumerTarget(consumer listener.Consumer) Target {
+	return func(ctx context.Context, record Record) error {
+		consumer.OnMessage(ctx, record.MessageContent())
+		return nil
+	}
+}
+
+// TopicTarget publishes each record to the topic with its original subject and message attributes. When the topic is
+// a FIFO topic the original message group ID and deduplication ID are used as well.
+// Messages that weren't SNS notifications, and so have no Message, have their whole Body published instead.
+func TopicTarget(client SNSAPI, topicArn string) Target {
+	return func(ctx context.Context, record Record) error {
+		ctx, span := otel.Tracer(name).Start(ctx, "publishRecord")
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String(traceNamespace+".topicArn", topicArn),
+			attribute.String(traceNamespace+".messageId", record.MessageId),
+		)
+
+		params, err := publishInput(record, topicArn)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+
+		_, err = client.Publish(ctx, params)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+
+		span.SetStatus(codes.Ok, "")
+
+		return nil
+	}
+}
+
+func publishInput(record Record, topicArn string) (*sns.PublishInput, error) {
+	message := record.Message
+
+	if message == "" {
+		message = record.Body
+	}
+
+	params := &sns.PublishInput{
+		TopicArn: aws.String(topicArn),
+		Message:  aws.String(message),
+	}
+
+	if record.Subject != "" {
+		params.Subject = aws.String(record.Subject)
+	}
+
+	if len(record.MessageAttributes) > 0 {
+		params.MessageAttributes = make(map[string]types.MessageAttributeValue, len(record.MessageAttributes))
+	}
+
+	for k, v := range record.MessageAttributes {
+		attr := types.MessageAttributeValue{DataType: aws.String(v.Type)}
+
+		if strings.HasPrefix(v.Type, "Binary") {
+			value, err := base64.StdEncoding.DecodeString(v.Value)
+
+			if err != nil {
+				return nil, fmt.Errorf("binary message attribute %s is not base64 encoded: %w", k, err)
+			}
+
+			attr.BinaryValue = value
+		} else {
+			attr.StringValue = aws.String(v.Value)
+		}
+
+		params.MessageAttributes[k] = attr
+	}
+
+	if strings.HasSuffix(topicArn, ".fifo") {
+		if groupId := record.Attributes["MessageGroupId"]; groupId != "" {
+			params.MessageGroupId = aws.String(groupId)
+		}
+
+		if deduplicationId := record.Attributes["MessageDeduplicationId"]; deduplicationId != "" {
+			params.MessageDeduplicationId = aws.String(deduplicationId)
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,207 @@
+package recording_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/listenertest"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/recording"
+)
+
+// writeRecording writes records to a new recording and opens it for reading.
+func writeRecording(t *testing.T, records []recording.Record) *recording.Reader {
+	path := filepath.Join(t.TempDir(), "messages.ndjson")
+	lines := []string{}
+
+	for _, record := range records {
+		line, _ := json.Marshal(record)
+		lines = append(lines, string(line))
+	}
+
+	_ = os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600)
+
+	reader, err := recording.Open(path)
+
+	if err != nil {
+		t.Fatalf("Expected no error opening recording but got %s", err.Error())
+	}
+
+	t.Cleanup(func() { reader.Close() })
+
+	return reader
+}
+
+type consumer struct {
+	messages []string
+}
+
+func (c *consumer) OnMessage(ctx context.Context, msg listener.MessageContent) {
+	c.messages = append(c.messages, aws.ToString(msg.Message))
+}
+
+func TestReplayToConsumer(t *testing.T) {
+	start := time.Date(2023, 3, 30, 10, 50, 22, 0, time.UTC)
+
+	tests := map[string]struct {
+		speed           float64
+		expectedElapsed time.Duration
+	}{
+		"without waiting": {0, 0},
+		"scaled timing":   {10, 100 * time.Millisecond},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := writeRecording(t, []recording.Record{
+				{ReceivedAt: start, MessageId: "1", Message: "first"},
+				{ReceivedAt: start.Add(time.Second), MessageId: "2", Message: "second"},
+				{ReceivedAt: start.Add(500 * time.Millisecond), MessageId: "3", Message: "third"},
+			})
+
+			c := &consumer{}
+			begin := time.Now()
+
+			err := recording.Replay(context.TODO(), reader, recording.ConsumerTarget(c), recording.WithSpeed(test.speed))
+
+			if err != nil {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if elapsed := time.Since(begin); elapsed < test.expectedElapsed {
+				t.Fatalf("Expected replay to take at least %s but it took %s", test.expectedElapsed, elapsed)
+			}
+
+			if strings.Join(c.messages, ",") != "first,second,third" {
+				t.Fatalf("Replayed messages %v were not in the recorded order", c.messages)
+			}
+		})
+	}
+}
+
+func TestReplayCancelled(t *testing.T) {
+	start := time.Now()
+	reader := writeRecording(t, []recording.Record{
+		{ReceivedAt: start, MessageId: "1", Message: "first"},
+		{ReceivedAt: start.Add(time.Hour), MessageId: "2", Message: "second"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+
+	c := &consumer{}
+
+	err := recording.Replay(ctx, reader, recording.ConsumerTarget(c))
+
+	if err == nil {
+		t.Fatal("Expected error but got no error")
+	}
+
+	if len(c.messages) != 1 {
+		t.Fatalf("Expected only the first message to be replayed but got %v", c.messages)
+	}
+}
+
+func TestReplayToTopic(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr        bool
+		topicName        string
+		topicAttributes  map[string]string
+		records          []recording.Record
+		expectedMessages int
+	}{
+		"standard topic": {
+			false,
+			"valid-topic",
+			map[string]string{},
+			[]recording.Record{
+				{
+					MessageId:         "1",
+					Subject:           "greeting",
+					Message:           "hello",
+					MessageAttributes: map[string]listener.MessageAttribute{"colour": {Type: "String", Value: "blue"}, "bytes": {Type: "Binary", Value: base64.StdEncoding.EncodeToString([]byte("baz"))}},
+					Attributes:        map[string]string{"MessageGroupId": "group"},
+				},
+				{MessageId: "2", Body: "not a notification"},
+			},
+			2,
+		},
+		"fifo topic keeps deduplication IDs": {
+			false,
+			"valid-topic.fifo",
+			map[string]string{"FifoTopic": "true"},
+			[]recording.Record{
+				{MessageId: "1", Message: "hello", Attributes: map[string]string{"MessageGroupId": "group", "MessageDeduplicationId": "a"}},
+				{MessageId: "2", Message: "hello again", Attributes: map[string]string{"MessageGroupId": "group", "MessageDeduplicationId": "a"}},
+				{MessageId: "3", Message: "goodbye", Attributes: map[string]string{"MessageGroupId": "group", "MessageDeduplicationId": "b"}},
+			},
+			2,
+		},
+		"invalid binary attribute": {
+			true,
+			"valid-topic",
+			map[string]string{},
+			[]recording.Record{
+				{MessageId: "1", Message: "hello", MessageAttributes: map[string]listener.MessageAttribute{"bytes": {Type: "Binary", Value: "not base64!"}}},
+			},
+			0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := listenertest.New()
+			ctx := context.TODO()
+
+			topic, _ := f.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String(test.topicName), Attributes: test.topicAttributes})
+			queueName := "valid-queue"
+			queueAttributes := map[string]string{}
+
+			if strings.HasSuffix(test.topicName, ".fifo") {
+				queueName += ".fifo"
+				queueAttributes["FifoQueue"] = "true"
+			}
+
+			queue, _ := f.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String(queueName), Attributes: queueAttributes})
+			queueArn, _ := f.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: queue.QueueUrl, AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn}})
+
+			_, err := f.Subscribe(ctx, &sns.SubscribeInput{
+				TopicArn:   topic.TopicArn,
+				Protocol:   aws.String("sqs"),
+				Endpoint:   aws.String(queueArn.Attributes["QueueArn"]),
+				Attributes: map[string]string{"RawMessageDelivery": "true"},
+			})
+
+			if err != nil {
+				t.Fatalf("Expected no error subscribing but got %s", err.Error())
+			}
+
+			err = recording.Replay(ctx, writeRecording(t, test.records), recording.TopicTarget(f, *topic.TopicArn), recording.WithSpeed(0))
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			result, _ := f.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: queue.QueueUrl, AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages}})
+
+			if result.Attributes["ApproximateNumberOfMessages"] != strconv.Itoa(test.expectedMessages) {
+				t.Fatalf("Expected %d messages to be published but found %s", test.expectedMessages, result.Attributes["ApproximateNumberOfMessages"])
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package listener
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// An SNSClientProvider returns an SNS client for the provided region.
+// It must be safe for concurrent use because it's called from every handler while listening.
+type SNSClientProvider func(region string) SNSAPI
+
+// regionalSNSClient sends each request to a client for the region of the topic or subscription it's about.
+// Requests about something without a region in its ARN go to the default client.
+type regionalSNSClient struct {
+	defaultClient SNSAPI
+	provider      SNSClientProvider
+}
+
+func (c regionalSNSClient) client(resourceArn *string) SNSAPI {
+	if resourceArn == nil {
+		return c.defaultClient
+	}
+
+	parsed, err := arn.Parse(*resourceArn)
+
+	if err != nil || parsed.Region == "" {
+		return c.defaultClient
+	}
+
+	return c.provider(parsed.Region)
+}
+
+func (c regionalSNSClient) Subscribe(ctx context.Context,
+	params *sns.SubscribeInput,
+	optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
+	return c.client(params.TopicArn).Subscribe(ctx, params, optFns...)
+}
+
+func (c regionalSNSClient) Unsubscribe(ctx context.Context,
+	params *sns.UnsubscribeInput,
+	optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
+	return c.client(params.SubscriptionArn).Unsubscribe(ctx, params, optFns...)
+}
+
+func (c regionalSNSClient) GetSubscriptionAttributes(ctx context.Context,
+	params *sns.GetSubscriptionAttributesInput,
+	optFns ...func(*sns.Options)) (*sns.GetSubscriptionAttributesOutput, error) {
+	return c.client(params.SubscriptionArn).GetSubscriptionAttributes(ctx, params, optFns...)
+}
+
+func (c regionalSNSClient) GetTopicAttributes(ctx context.Context,
+	params *sns.GetTopicAttributesInput,
+	optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	return c.client(params.TopicArn).GetTopicAttributes(ctx, params, optFns...)
+}
+
+func (c regionalSNSClient) ConfirmSubscription(ctx context.Context,
+	params *sns.ConfirmSubscriptionInput,
+	optFns ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error) {
+	return c.client(params.TopicArn).ConfirmSubscription(ctx, params, optFns...)
+}
@@ -0,0 +1,115 @@
+package listener
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// RegionRecorder provides SNS clients and records the regions they were provided for.
+type RegionRecorder struct {
+	regions []string
+}
+
+func (r *RegionRecorder) provide(region string) SNSAPI {
+	r.regions = append(r.regions, region)
+
+	return SNSAPIImpl{}
+}
+
+func TestRegionalSNSClient(t *testing.T) {
+	tests := map[string]struct {
+		call            func(ctx context.Context, client SNSAPI)
+		expectedRegions []string
+	}{
+		"subscribe": {
+			func(ctx context.Context, client SNSAPI) {
+				client.Subscribe(ctx, &sns.SubscribeInput{TopicArn: aws.String("arn:aws:sns:ap-southeast-2:123456789012:valid-topic")})
+			},
+			[]string{"ap-southeast-2"},
+		},
+		"unsubscribe": {
+			func(ctx context.Context, client SNSAPI) {
+				client.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: aws.String("arn:aws:sns:eu-west-1:123456789012:valid-topic:some-id")})
+			},
+			[]string{"eu-west-1"},
+		},
+		"get subscription attributes": {
+			func(ctx context.Context, client SNSAPI) {
+				client.GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{SubscriptionArn: aws.String("arn:aws:sns:us-west-2:123456789012:valid-topic:some-id")})
+			},
+			[]string{"us-west-2"},
+		},
+		"get topic attributes": {
+			func(ctx context.Context, client SNSAPI) {
+				client.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String("arn:aws:sns:us-east-1:123456789012:valid-topic")})
+			},
+			[]string{"us-east-1"},
+		},
+		"not an ARN": {
+			func(ctx context.Context, client SNSAPI) {
+				client.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: aws.String("valid:arn")})
+			},
+			[]string{},
+		},
+	}
+
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &RegionRecorder{regions: []string{}}
+			client := regionalSNSClient{SNSAPIImpl{}, recorder.provide}
+
+			test.call(ctx, client)
+
+			if !reflect.DeepEqual(recorder.regions, test.expectedRegions) {
+				t.Fatalf(
+					"Expected clients for regions %v but got %v",
+					test.expectedRegions,
+					recorder.regions,
+				)
+			}
+		})
+	}
+}
+
+func TestSetupWithSNSClientProvider(t *testing.T) {
+	recorder := &RegionRecorder{regions: []string{}}
+	ctx := context.TODO()
+
+	l := New(
+		"arn:aws:sns:us-east-1:123456789012:valid-topic",
+		&SNSAPIImpl{},
+		&SQSAPIImpl{},
+		WithQueueName("valid-queue"),
+		WithSNSClientProvider(recorder.provide),
+	)
+
+	err := l.Setup(ctx)
+
+	if err != nil {
+		t.Fatalf("Expected no error on setup but got %s", err.Error())
+	}
+
+	err = l.Teardown(ctx)
+
+	if err != nil {
+		t.Fatalf("Expected no error on teardown but got %s", err.Error())
+	}
+
+	// the topic's attributes are read to decide the queue's settings and then it's subscribed to,
+	// the fake subscription ARN has no region so unsubscribing uses the default client
+	expectedRegions := []string{"us-east-1", "us-east-1"}
+
+	if !reflect.DeepEqual(recorder.regions, expectedRegions) {
+		t.Fatalf(
+			"Expected clients for regions %v but got %v",
+			expectedRegions,
+			recorder.regions,
+		)
+	}
+}
@@ -2,10 +2,13 @@ package listener
 
 import (
 	"context"
-	"regexp"
+	"errors"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/smithy-go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -21,9 +24,21 @@ type SNSAPI interface {
 	Unsubscribe(ctx context.Context,
 		params *sns.UnsubscribeInput,
 		optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error)
+
+	GetSubscriptionAttributes(ctx context.Context,
+		params *sns.GetSubscriptionAttributesInput,
+		optFns ...func(*sns.Options)) (*sns.GetSubscriptionAttributesOutput, error)
+
+	GetTopicAttributes(ctx context.Context,
+		params *sns.GetTopicAttributesInput,
+		optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error)
+
+	ConfirmSubscription(ctx context.Context,
+		params *sns.ConfirmSubscriptionInput,
+		optFns ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error)
 }
 
-func subscribeToTopic(ctx context.Context, client SNSAPI, topicArn string, queueArn string) (string, error) {
+func subscribeToTopic(ctx context.Context, client SNSAPI, topicArn string, queueArn string, attributes map[string]string) (string, error) {
 	ctx, span := otel.Tracer(name).Start(ctx, "subscribeToTopic")
 	defer span.End()
 
@@ -32,11 +47,16 @@ func subscribeToTopic(ctx context.Context, client SNSAPI, topicArn string, queue
 		attribute.String(traceNamespace+".queueArn", queueArn),
 	)
 
-	logger.Printf("Creating a new SNS subscription...\n\tSNS topic ARN: %s\n\tSQS queue ARN: %s", topicArn, queueArn)
+	for k, v := range attributes {
+		span.SetAttributes(attribute.String(traceNamespace+".subscriptionAttributes."+k, v))
+	}
+
+	logger.Printf("Creating a new SNS subscription...\n\tSNS topic ARN: %s\n\tSQS queue ARN: %s\n\tAttributes: %v", topicArn, queueArn, attributes)
 
 	result, err := client.Subscribe(
 		ctx,
 		&sns.SubscribeInput{
+			Attributes:            attributes,
 			Endpoint:              &queueArn,
 			Protocol:              aws.String("sqs"),
 			ReturnSubscriptionArn: true,
@@ -87,9 +107,266 @@ func unsubscribeFromTopic(ctx context.Context, client SNSAPI, subscriptionArn st
 	return nil
 }
 
+func getSubscriptionAttributes(ctx context.Context, client SNSAPI, subscriptionArn string) (map[string]string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "getSubscriptionAttributes")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".subscriptionArn", subscriptionArn))
+
+	logger.Printf("Looking up subscription with ARN %s...", subscriptionArn)
+
+	result, err := client.GetSubscriptionAttributes(
+		ctx,
+		&sns.GetSubscriptionAttributesInput{
+			SubscriptionArn: &subscriptionArn,
+		},
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.String(traceNamespace+".topicArn", result.Attributes["TopicArn"]),
+		attribute.String(traceNamespace+".protocol", result.Attributes["Protocol"]),
+		attribute.String(traceNamespace+".endpoint", result.Attributes["Endpoint"]),
+	)
+
+	logger.Printf(
+		"Found subscription...\n\tSNS topic ARN: %s\n\tProtocol: %s\n\tEndpoint: %s",
+		result.Attributes["TopicArn"],
+		result.Attributes["Protocol"],
+		result.Attributes["Endpoint"],
+	)
+
+	span.SetStatus(codes.Ok, "")
+	return result.Attributes, nil
+}
+
+func confirmSubscription(ctx context.Context, client SNSAPI, topicArn string, token string) (string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "confirmSubscription")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".topicArn", topicArn))
+
+	logger.Printf("Confirming subscription to topic %s...", topicArn)
+
+	result, err := client.ConfirmSubscription(
+		ctx,
+		&sns.ConfirmSubscriptionInput{
+			TopicArn: &topicArn,
+			Token:    &token,
+		},
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	span.SetAttributes(attribute.String(traceNamespace+".subscriptionArn", *result.SubscriptionArn))
+	span.SetStatus(codes.Ok, "")
+
+	logger.Printf("Subscription confirmed with ARN %s", *result.SubscriptionArn)
+
+	return *result.SubscriptionArn, nil
+}
+
+// isCrossAccount returns true if the topic and queue belong to different accounts, in which case a subscription
+// made with the topic owner's credentials has to be confirmed by the queue.
+func isCrossAccount(topicArn string, queueArn string) bool {
+	topic, err := arn.Parse(topicArn)
+
+	if err != nil {
+		return false
+	}
+
+	queue, err := arn.Parse(queueArn)
+
+	if err != nil {
+		return false
+	}
+
+	return topic.AccountID != queue.AccountID
+}
+
+// A subscriptionConfirmer confirms subscriptions to the Listener's topics from the confirmation messages SNS sends to
+// the queue. Confirmations for any other topic are treated like any other message.
+type subscriptionConfirmer struct {
+	client    SNSAPI
+	topicArns map[string]bool
+}
+
+func newSubscriptionConfirmer(client SNSAPI, topicArns []string) *subscriptionConfirmer {
+	c := &subscriptionConfirmer{
+		client:    client,
+		topicArns: map[string]bool{},
+	}
+
+	for _, topicArn := range topicArns {
+		c.topicArns[topicArn] = true
+	}
+
+	return c
+}
+
+// confirmationFor returns the subscription confirmation in the message if it's for one of the Listener's topics.
+func (c *subscriptionConfirmer) confirmationFor(body *string) (*Notification, bool) {
+	if c == nil || body == nil {
+		return nil, false
+	}
+
+	notification, err := parseNotification(*body)
+
+	if err != nil || notification.Type != "SubscriptionConfirmation" || notification.Token == "" {
+		return nil, false
+	}
+
+	return notification, c.topicArns[notification.TopicArn]
+}
+
+func (c *subscriptionConfirmer) confirm(ctx context.Context, notification *Notification) error {
+	_, err := confirmSubscription(ctx, c.client, notification.TopicArn, notification.Token)
+
+	return err
+}
+
+func getTopicAttributes(ctx context.Context, client SNSAPI, topicArn string) (map[string]string, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "getTopicAttributes")
+	defer span.End()
+
+	span.SetAttributes(attribute.String(traceNamespace+".topicArn", topicArn))
+
+	logger.Printf("Looking up topic with ARN %s...", topicArn)
+
+	result, err := client.GetTopicAttributes(
+		ctx,
+		&sns.GetTopicAttributesInput{
+			TopicArn: &topicArn,
+		},
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String(traceNamespace+".fifoTopic", result.Attributes["FifoTopic"]))
+
+	logger.Printf(
+		"Found topic...\n\tSNS topic ARN: %s\n\tFIFO: %t",
+		topicArn,
+		result.Attributes["FifoTopic"] == "true",
+	)
+
+	span.SetStatus(codes.Ok, "")
+	return result.Attributes, nil
+}
+
 func isTopicFIFO(ctx context.Context, topicArn string) (bool, error) {
 	_, span := otel.Tracer(name).Start(ctx, "isTopicFIFO")
 	defer span.End()
 
-	return regexp.MatchString(`\.fifo$`, topicArn)
+	parsed, err := ParseTopicArn(topicArn)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return false, err
+	}
+
+	span.SetStatus(codes.Ok, "")
+	return parsed.IsFIFO(), nil
+}
+
+// topicSettings are the settings of the topics that a queue subscribed to them has to mirror.
+type topicSettings struct {
+	fifo                      bool
+	contentBasedDeduplication bool
+}
+
+// getTopicSettings looks up whether a topic is a FIFO topic and whether it uses content-based deduplication.
+// If the caller isn't allowed to read the topic's attributes then FIFO topics are detected from the ".fifo" suffix
+// of the topic's name and are assumed to use content-based deduplication.
+func getTopicSettings(ctx context.Context, client SNSAPI, topicArn string) (topicSettings, error) {
+	ctx, span := otel.Tracer(name).Start(ctx, "getTopicSettings")
+	defer span.End()
+
+	attributes, err := getTopicAttributes(ctx, client, topicArn)
+
+	if err != nil && !isAccessDenied(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return topicSettings{}, err
+	}
+
+	if err != nil {
+		logger.Printf("Unable to read attributes of topic %s, checking its name for the .fifo suffix instead", topicArn)
+
+		isFIFO, err := isTopicFIFO(ctx, topicArn)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return topicSettings{}, err
+		}
+
+		span.SetStatus(codes.Ok, "")
+		return topicSettings{fifo: isFIFO, contentBasedDeduplication: isFIFO}, nil
+	}
+
+	settings := topicSettings{
+		fifo:                      attributes["FifoTopic"] == "true",
+		contentBasedDeduplication: attributes["ContentBasedDeduplication"] == "true",
+	}
+
+	span.SetAttributes(
+		attribute.Bool(traceNamespace+".isFIFO", settings.fifo),
+		attribute.Bool(traceNamespace+".contentBasedDeduplication", settings.contentBasedDeduplication),
+	)
+	span.SetStatus(codes.Ok, "")
+
+	return settings, nil
+}
+
+// getTopicsSettings returns the settings shared by every topic. A queue can't be subscribed to both kinds of topic
+// so an error is returned if only some of them are FIFO topics. Content-based deduplication is only used if every
+// topic uses it.
+func getTopicsSettings(ctx context.Context, client SNSAPI, topicArns []string) (topicSettings, error) {
+	settings := topicSettings{}
+
+	for i, topicArn := range topicArns {
+		topic, err := getTopicSettings(ctx, client, topicArn)
+
+		if err != nil {
+			return topicSettings{}, err
+		}
+
+		if i == 0 {
+			settings = topic
+			continue
+		}
+
+		if topic.fifo != settings.fifo {
+			return topicSettings{}, fmt.Errorf("topics %s and %s can't share a queue because only one of them is a FIFO topic", topicArns[0], topicArn)
+		}
+
+		settings.contentBasedDeduplication = settings.contentBasedDeduplication && topic.contentBasedDeduplication
+	}
+
+	return settings, nil
+}
+
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.ErrorCode() == "AuthorizationError" || apiErr.ErrorCode() == "AccessDenied"
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/smithy-go"
 )
 
 type SNSAPIImpl struct{}
@@ -14,13 +15,31 @@ type SNSAPIImpl struct{}
 func (c SNSAPIImpl) Subscribe(ctx context.Context,
 	params *sns.SubscribeInput,
 	optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
-	if *params.TopicArn == "valid-topic" {
+	for k := range params.Attributes {
+		if k != "RawMessageDelivery" && k != "FilterPolicy" && k != "FilterPolicyScope" {
+			return nil, errors.New("Invalid subscription attribute")
+		}
+	}
+
+	if *params.TopicArn == "arn:aws:sns:us-east-1:123456789012:valid-topic" {
 		return &sns.SubscribeOutput{
 			SubscriptionArn: aws.String("valid:arn"),
 		}, nil
 	}
 
-	if *params.TopicArn == "breaks-on-teardown" {
+	if *params.TopicArn == "arn:aws:sns:us-east-1:123456789012:other-valid-topic" {
+		return &sns.SubscribeOutput{
+			SubscriptionArn: aws.String("other:arn"),
+		}, nil
+	}
+
+	if *params.TopicArn == "arn:aws:sns:us-east-1:210987654321:other-account-topic" {
+		return &sns.SubscribeOutput{
+			SubscriptionArn: aws.String("arn:aws:sns:us-east-1:210987654321:other-account-topic:pending"),
+		}, nil
+	}
+
+	if *params.TopicArn == "arn:aws:sns:us-east-1:123456789012:breaks-on-teardown" {
 		return &sns.SubscribeOutput{
 			SubscriptionArn: aws.String("invalid:arn"),
 		}, nil
@@ -32,21 +51,115 @@ func (c SNSAPIImpl) Subscribe(ctx context.Context,
 func (c SNSAPIImpl) Unsubscribe(ctx context.Context,
 	params *sns.UnsubscribeInput,
 	optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
-	if *params.SubscriptionArn == "valid:arn" {
+	if *params.SubscriptionArn == "valid:arn" || *params.SubscriptionArn == "other:arn" ||
+		*params.SubscriptionArn == "arn:aws:sns:us-east-1:210987654321:other-account-topic:pending" {
 		return &sns.UnsubscribeOutput{}, nil
 	}
 
 	return nil, errors.New("Could not unsubscribe using that ARN")
 }
 
+func (c SNSAPIImpl) GetSubscriptionAttributes(ctx context.Context,
+	params *sns.GetSubscriptionAttributesInput,
+	optFns ...func(*sns.Options)) (*sns.GetSubscriptionAttributesOutput, error) {
+	if *params.SubscriptionArn == "valid:arn" {
+		return &sns.GetSubscriptionAttributesOutput{
+			Attributes: map[string]string{
+				"Endpoint":           "arn:aws:sqs:us-east-1:123456789012:valid-queue",
+				"Protocol":           "sqs",
+				"RawMessageDelivery": "true",
+				"SubscriptionArn":    "valid:arn",
+				"TopicArn":           "arn:aws:sns:us-east-1:123456789012:valid-topic",
+			},
+		}, nil
+	}
+
+	if *params.SubscriptionArn == "arn:aws:sns:us-east-1:210987654321:other-account-topic:pending" {
+		return &sns.GetSubscriptionAttributesOutput{
+			Attributes: map[string]string{
+				"Endpoint":            "arn:aws:sqs:us-east-1:123456789012:valid-queue",
+				"PendingConfirmation": "true",
+				"Protocol":            "sqs",
+				"TopicArn":            "arn:aws:sns:us-east-1:210987654321:other-account-topic",
+			},
+		}, nil
+	}
+
+	if *params.SubscriptionArn == "email:arn" {
+		return &sns.GetSubscriptionAttributesOutput{
+			Attributes: map[string]string{
+				"Endpoint":        "someone@example.com",
+				"Protocol":        "email",
+				"SubscriptionArn": "email:arn",
+				"TopicArn":        "arn:aws:sns:us-east-1:123456789012:valid-topic",
+			},
+		}, nil
+	}
+
+	return nil, errors.New("Couldn't find that subscription")
+}
+
+func (c SNSAPIImpl) GetTopicAttributes(ctx context.Context,
+	params *sns.GetTopicAttributesInput,
+	optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	switch *params.TopicArn {
+	case "arn:aws:sns:us-east-1:123456789012:valid-topic",
+		"arn:aws:sns:us-east-1:123456789012:other-valid-topic",
+		"arn:aws:sns:us-east-1:123456789012:breaks-on-teardown",
+		"arn:aws:sns:us-east-1:210987654321:other-account-topic":
+		return &sns.GetTopicAttributesOutput{
+			Attributes: map[string]string{
+				"TopicArn": *params.TopicArn,
+			},
+		}, nil
+	case "arn:aws:sns:us-east-1:123456789012:valid-topic.fifo":
+		return &sns.GetTopicAttributesOutput{
+			Attributes: map[string]string{
+				"TopicArn":                  *params.TopicArn,
+				"FifoTopic":                 "true",
+				"ContentBasedDeduplication": "true",
+			},
+		}, nil
+	case "arn:aws:sns:us-east-1:123456789012:other-valid-topic.fifo":
+		return &sns.GetTopicAttributesOutput{
+			Attributes: map[string]string{
+				"TopicArn":                  *params.TopicArn,
+				"FifoTopic":                 "true",
+				"ContentBasedDeduplication": "false",
+			},
+		}, nil
+	case "arn:aws:sns:us-east-1:123456789012:denied-topic",
+		"arn:aws:sns:us-east-1:123456789012:denied-topic.fifo":
+		return nil, &smithy.GenericAPIError{Code: "AuthorizationError", Message: "Not authorized"}
+	}
+
+	return nil, errors.New("Couldn't find that topic")
+}
+
+func (c SNSAPIImpl) ConfirmSubscription(ctx context.Context,
+	params *sns.ConfirmSubscriptionInput,
+	optFns ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error) {
+	if *params.Token == "valid-token" {
+		return &sns.ConfirmSubscriptionOutput{
+			SubscriptionArn: aws.String(*params.TopicArn + ":confirmed"),
+		}, nil
+	}
+
+	return nil, errors.New("Invalid token")
+}
+
 func TestSubscribe(t *testing.T) {
 	tests := map[string]struct {
 		shouldErr   bool
 		topicArn    string
+		attributes  map[string]string
 		expectedArn string
 	}{
-		"valid input":   {false, "valid-topic", "valid:arn"},
-		"invalid input": {true, "invalid-topic", ""},
+		"valid input":             {false, "arn:aws:sns:us-east-1:123456789012:valid-topic", map[string]string{}, "valid:arn"},
+		"raw message delivery":    {false, "arn:aws:sns:us-east-1:123456789012:valid-topic", map[string]string{"RawMessageDelivery": "true"}, "valid:arn"},
+		"filter policy":           {false, "arn:aws:sns:us-east-1:123456789012:valid-topic", map[string]string{"FilterPolicy": `{"colour": ["blue"]}`, "FilterPolicyScope": "MessageBody"}, "valid:arn"},
+		"invalid input":           {true, "arn:aws:sns:us-east-1:123456789012:invalid-topic", map[string]string{}, ""},
+		"invalid attribute input": {true, "arn:aws:sns:us-east-1:123456789012:valid-topic", map[string]string{"Foo": "bar"}, ""},
 	}
 
 	client := &SNSAPIImpl{}
@@ -55,7 +168,7 @@ func TestSubscribe(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			result, err := subscribeToTopic(ctx, client, test.topicArn, queueArn)
+			result, err := subscribeToTopic(ctx, client, test.topicArn, queueArn, test.attributes)
 
 			if err != nil && !test.shouldErr {
 				t.Fatalf(
@@ -111,6 +224,152 @@ func TestUnsubscribe(t *testing.T) {
 	}
 }
 
+func TestGetSubscriptionAttributes(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr        bool
+		subscriptionArn  string
+		expectedEndpoint string
+	}{
+		"valid subscription ARN":   {false, "valid:arn", "arn:aws:sqs:us-east-1:123456789012:valid-queue"},
+		"invalid subscription ARN": {true, "invalid:arn", ""},
+	}
+
+	ctx := context.TODO()
+	client := &SNSAPIImpl{}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := getSubscriptionAttributes(ctx, client, test.subscriptionArn)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result["Endpoint"] != test.expectedEndpoint {
+					t.Fatalf(
+						"Subscription endpoint %s did not match expected endpoint %s",
+						result["Endpoint"],
+						test.expectedEndpoint,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmSubscription(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr   bool
+		topicArn    string
+		token       string
+		expectedArn string
+	}{
+		"valid token":   {false, "arn:aws:sns:us-east-1:210987654321:other-account-topic", "valid-token", "arn:aws:sns:us-east-1:210987654321:other-account-topic:confirmed"},
+		"invalid token": {true, "arn:aws:sns:us-east-1:210987654321:other-account-topic", "invalid-token", ""},
+	}
+
+	client := &SNSAPIImpl{}
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := confirmSubscription(ctx, client, test.topicArn, test.token)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result != test.expectedArn {
+					t.Fatalf(
+						"Expected subscription ARN %s but got %s",
+						test.expectedArn,
+						result,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestIsCrossAccount(t *testing.T) {
+	tests := map[string]struct {
+		topicArn string
+		queueArn string
+		expected bool
+	}{
+		"same account":      {"arn:aws:sns:us-east-1:123456789012:my-topic", "arn:aws:sqs:us-east-1:123456789012:my-queue", false},
+		"different account": {"arn:aws:sns:us-east-1:210987654321:my-topic", "arn:aws:sqs:us-east-1:123456789012:my-queue", true},
+		"invalid queue ARN": {"arn:aws:sns:us-east-1:210987654321:my-topic", "my-queue", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := isCrossAccount(test.topicArn, test.queueArn); result != test.expected {
+				t.Fatalf("Expected %t but got %t", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetTopicAttributes(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr    bool
+		topicArn     string
+		expectedFIFO string
+	}{
+		"standard topic":      {false, "arn:aws:sns:us-east-1:123456789012:valid-topic", ""},
+		"FIFO topic":          {false, "arn:aws:sns:us-east-1:123456789012:valid-topic.fifo", "true"},
+		"topic doesn't exist": {true, "arn:aws:sns:us-east-1:123456789012:invalid-topic", ""},
+	}
+
+	client := &SNSAPIImpl{}
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := getTopicAttributes(ctx, client, test.topicArn)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result["FifoTopic"] != test.expectedFIFO {
+					t.Fatalf(
+						"Expected FifoTopic %s for topic with ARN %s but got %s",
+						test.expectedFIFO,
+						test.topicArn,
+						result["FifoTopic"],
+					)
+				}
+			}
+		})
+	}
+}
+
 func TestIsTopicFIFO(t *testing.T) {
 	tests := map[string]struct {
 		shouldErr bool
@@ -119,6 +378,7 @@ func TestIsTopicFIFO(t *testing.T) {
 	}{
 		"FIFO topic":       {false, "arn:aws:sns:us-east-1:123456789012:my-topic.fifo", true},
 		"not a FIFO topic": {false, "arn:aws:sns:us-east-1:123456789012:my-topic", false},
+		"invalid ARN":      {true, "my-topic.fifo", false},
 	}
 
 	ctx := context.TODO()
@@ -151,3 +411,78 @@ func TestIsTopicFIFO(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTopicsSettings(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		topicArns []string
+		expected  topicSettings
+	}{
+		"standard topics": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:valid-topic", "arn:aws:sns:us-east-1:123456789012:other-valid-topic"},
+			topicSettings{false, false},
+		},
+		"FIFO topic": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:valid-topic.fifo"},
+			topicSettings{true, true},
+		},
+		"FIFO topics with different deduplication": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:valid-topic.fifo", "arn:aws:sns:us-east-1:123456789012:other-valid-topic.fifo"},
+			topicSettings{true, false},
+		},
+		"FIFO and standard topics": {
+			true,
+			[]string{"arn:aws:sns:us-east-1:123456789012:valid-topic", "arn:aws:sns:us-east-1:123456789012:valid-topic.fifo"},
+			topicSettings{},
+		},
+		"access denied to standard topic": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:denied-topic"},
+			topicSettings{false, false},
+		},
+		"access denied to FIFO topic": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:denied-topic.fifo", "arn:aws:sns:us-east-1:123456789012:other-valid-topic.fifo"},
+			topicSettings{true, false},
+		},
+		"topic doesn't exist": {
+			true,
+			[]string{"arn:aws:sns:us-east-1:123456789012:invalid-topic"},
+			topicSettings{},
+		},
+	}
+
+	client := &SNSAPIImpl{}
+	ctx := context.TODO()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := getTopicsSettings(ctx, client, test.topicArns)
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if result != test.expected {
+					t.Fatalf(
+						"Expected settings %+v for topics %v but got %+v",
+						test.expected,
+						test.topicArns,
+						result,
+					)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/whatsfordinner/aws-sns-listener/internal/resolve"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+// publishOptions are everything about a message other than its topic.
+type publishOptions struct {
+	message                string
+	subject                string
+	attributes             []string
+	messageStructure       bool
+	messageGroupId         string
+	messageDeduplicationId string
+}
+
+// publish sends a message to every topic provided with -t or -p and prints the ID SNS gives it for each topic.
+func publish(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("publish", flag.ExitOnError)
+	topicArns := listFlag{}
+	flags.Var(&topicArns, "t", "The ARN of a topic to publish to, can be repeated or @path/to/topics.txt")
+	parameterPaths := listFlag{}
+	flags.Var(&parameterPaths, "p", "A reference such as ssm:/path#key to get a topic ARN from, can be repeated or @path/to/references.txt")
+	subject := flags.String("s", "", "Optional subject of the message")
+	attributes := listFlag{}
+	flags.Var(&attributes, "a", "A name=value or name:Type=value message attribute, can be repeated or @path/to/attributes.txt")
+	messageStructure := flags.Bool("j", false, "The message is a JSON object with a message for each protocol")
+	messageGroupId := flags.String("g", "", "Message group ID, required for FIFO topics")
+	messageDeduplicationId := flags.String("d", "", "Optional message deduplication ID for FIFO topics")
+	loadOptions := configFlags(flags, sns.ServiceID, ssm.ServiceID, sts.ServiceID)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of aws-sns-listener publish [flags] [message | @path/to/message.txt | -]:\n")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if (len(topicArns) == 0 && len(parameterPaths) == 0) || flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	// Stdin can only be read once so MFA token codes are read from the terminal when it has the message in it
+	mfaInput := io.Reader(os.Stdin)
+
+	if readsInput(flags.Arg(0)) {
+		tty := &ttyReader{path: "/dev/tty"}
+		defer tty.Close()
+		mfaInput = tty
+	}
+
+	message, err := readMessage(flags.Arg(0), os.Stdin)
+
+	if err != nil {
+		return fmt.Errorf("unable to read message: %w", err)
+	}
+
+	opts := publishOptions{
+		message:                message,
+		subject:                *subject,
+		attributes:             attributes,
+		messageStructure:       *messageStructure,
+		messageGroupId:         *messageGroupId,
+		messageDeduplicationId: *messageDeduplicationId,
+	}
+
+	// Check the message is well formed before resolving any topics
+	_, err = newPublishInput("", opts)
+
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(ctx, loadOptions(), mfaInput, os.Stderr)
+
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+
+	for _, parameterPath := range parameterPaths {
+		paramTopicArn, err := resolve.Resolve(ctx, resolve.Clients{SSM: ssm.NewFromConfig(cfg)}, parameterPath)
+
+		if err != nil {
+			return fmt.Errorf("unable to resolve topic ARN from %s: %w", parameterPath, err)
+		}
+
+		topicArns = append(topicArns, paramTopicArn)
+	}
+
+	// Every topic is checked before anything is published so that a message isn't only sent to some of them
+	inputs := make([]*sns.PublishInput, 0, len(topicArns))
+	regions := make([]string, 0, len(topicArns))
+
+	for _, topicArn := range topicArns {
+		parsedTopicArn, err := listener.ParseTopicArn(topicArn)
+
+		if err != nil {
+			return err
+		}
+
+		params, err := newPublishInput(topicArn, opts)
+
+		if err != nil {
+			return err
+		}
+
+		inputs = append(inputs, params)
+		regions = append(regions, parsedTopicArn.Region)
+	}
+
+	for i, params := range inputs {
+		result, err := sns.NewFromConfig(cfg, func(o *sns.Options) {
+			o.Region = regions[i]
+		}).Publish(ctx, params)
+
+		if err != nil {
+			return fmt.Errorf("unable to publish to %s: %w", *params.TopicArn, err)
+		}
+
+		if result.SequenceNumber != nil {
+			fmt.Printf("%s\t%s\t%s\n", *params.TopicArn, aws.ToString(result.MessageId), *result.SequenceNumber)
+			continue
+		}
+
+		fmt.Printf("%s\t%s\n", *params.TopicArn, aws.ToString(result.MessageId))
+	}
+
+	return nil
+}
+
+// readMessage reads the message from the argument. An argument prefixed with "@" is the path to a file with the
+// message in it, and a missing argument or "-" reads the message from input.
+func readMessage(arg string, input io.Reader) (string, error) {
+	if readsInput(arg) {
+		message, err := io.ReadAll(input)
+
+		return string(message), err
+	}
+
+	if strings.HasPrefix(arg, "@") {
+		message, err := os.ReadFile(strings.TrimPrefix(arg, "@"))
+
+		return string(message), err
+	}
+
+	return arg, nil
+}
+
+// readsInput reports whether or not readMessage reads the message from input for the argument.
+func readsInput(arg string) bool {
+	return arg == "" || arg == "-"
+}
+
+// ttyReader reads from the terminal at path, which is only opened the first time it's read from so that a terminal
+// is only needed when something is actually read.
+type ttyReader struct {
+	path string
+	file *os.File
+}
+
+func (r *ttyReader) Read(p []byte) (int, error) {
+	if r.file == nil {
+		file, err := os.Open(r.path)
+
+		if err != nil {
+			return 0, fmt.Errorf("stdin has the message in it and the terminal can't be opened: %w", err)
+		}
+
+		r.file = file
+	}
+
+	return r.file.Read(p)
+}
+
+func (r *ttyReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Close()
+}
+
+// newPublishInput builds the request to publish the message to the topic, checking that the message is well formed.
+func newPublishInput(topicArn string, opts publishOptions) (*sns.PublishInput, error) {
+	if opts.message == "" {
+		return nil, errors.New("the message is empty")
+	}
+
+	params := &sns.PublishInput{
+		TopicArn: aws.String(topicArn),
+		Message:  aws.String(opts.message),
+	}
+
+	if opts.subject != "" {
+		params.Subject = aws.String(opts.subject)
+	}
+
+	if opts.messageStructure {
+		messages := map[string]string{}
+
+		err := json.Unmarshal([]byte(opts.message), &messages)
+
+		if err != nil {
+			return nil, fmt.Errorf("the message must be a JSON object of protocols to strings with -j: %w", err)
+		}
+
+		if _, ok := messages["default"]; !ok {
+			return nil, errors.New(`the message must have a "default" message with -j`)
+		}
+
+		params.MessageStructure = aws.String("json")
+	}
+
+	if len(opts.attributes) > 0 {
+		params.MessageAttributes = make(map[string]types.MessageAttributeValue, len(opts.attributes))
+	}
+
+	for _, attribute := range opts.attributes {
+		name, value, err := parseMessageAttribute(attribute)
+
+		if err != nil {
+			return nil, err
+		}
+
+		params.MessageAttributes[name] = value
+	}
+
+	if opts.messageGroupId != "" {
+		params.MessageGroupId = aws.String(opts.messageGroupId)
+	}
+
+	if opts.messageDeduplicationId != "" {
+		params.MessageDeduplicationId = aws.String(opts.messageDeduplicationId)
+	}
+
+	if strings.HasSuffix(topicArn, ".fifo") && opts.messageGroupId == "" {
+		return nil, fmt.Errorf("a message group ID is required to publish to the FIFO topic %s", topicArn)
+	}
+
+	return params, nil
+}
+
+// parseMessageAttribute parses an attribute in the form "name=value", which is a String, or "name:Type=value" for any
+// other type. Binary values are base64 encoded.
+func parseMessageAttribute(attribute string) (string, types.MessageAttributeValue, error) {
+	key, value, found := strings.Cut(attribute, "=")
+
+	if !found {
+		return "", types.MessageAttributeValue{}, fmt.Errorf("message attribute %s is not in the form name=value", attribute)
+	}
+
+	name, dataType, hasType := strings.Cut(key, ":")
+
+	if !hasType {
+		dataType = "String"
+	}
+
+	if name == "" || dataType == "" {
+		return "", types.MessageAttributeValue{}, fmt.Errorf("message attribute %s is missing a name or type", attribute)
+	}
+
+	if !strings.HasPrefix(dataType, "Binary") {
+		return name, types.MessageAttributeValue{DataType: aws.String(dataType), StringValue: aws.String(value)}, nil
+	}
+
+	binaryValue, err := base64.StdEncoding.DecodeString(value)
+
+	if err != nil {
+		return "", types.MessageAttributeValue{}, fmt.Errorf("binary message attribute %s is not base64 encoded: %w", name, err)
+	}
+
+	return name, types.MessageAttributeValue{DataType: aws.String(dataType), BinaryValue: binaryValue}, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestReadMessage(t *testing.T) {
+	dir := t.TempDir()
+	messagePath := filepath.Join(dir, "message.json")
+
+	_ = os.WriteFile(messagePath, []byte(`{"colour": "blue"}`), 0600)
+
+	tests := map[string]struct {
+		shouldErr bool
+		arg       string
+		input     string
+		expected  string
+	}{
+		"argument":     {false, "hello", "ignored", "hello"},
+		"file":         {false, "@" + messagePath, "ignored", `{"colour": "blue"}`},
+		"missing file": {true, "@" + filepath.Join(dir, "missing.json"), "", ""},
+		"stdin":        {false, "-", "from stdin", "from stdin"},
+		"no argument":  {false, "", "from stdin", "from stdin"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := readMessage(test.arg, strings.NewReader(test.input))
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if result != test.expected {
+				t.Fatalf("Message %s did not match expected value %s", result, test.expected)
+			}
+		})
+	}
+}
+
+func TestTTYReader(t *testing.T) {
+	dir := t.TempDir()
+	ttyPath := filepath.Join(dir, "tty")
+
+	_ = os.WriteFile(ttyPath, []byte("123456\n"), 0600)
+
+	tests := map[string]struct {
+		shouldErr bool
+		path      string
+		expected  string
+	}{
+		"terminal":    {false, ttyPath, "123456"},
+		"no terminal": {true, filepath.Join(dir, "missing"), ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The message takes all of stdin so the MFA token code has to come from somewhere else
+			stdin := strings.NewReader("hello\n654321\n")
+			message, _ := readMessage("-", stdin)
+
+			if message != "hello\n654321\n" {
+				t.Fatalf("Message %q did not match all of stdin", message)
+			}
+
+			tty := &ttyReader{path: test.path}
+			defer tty.Close()
+
+			code, err := mfaTokenProvider(tty, &bytes.Buffer{})()
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if err != nil && !strings.Contains(err.Error(), "terminal") {
+				t.Fatalf("Expected the error to explain the terminal couldn't be opened but got %s", err.Error())
+			}
+
+			if code != test.expected {
+				t.Fatalf("Code %s did not match expected value %s", code, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseMessageAttribute(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr      bool
+		attribute      string
+		expectedName   string
+		expectedType   string
+		expectedString string
+		expectedBinary string
+	}{
+		"string":            {false, "colour=blue", "colour", "String", "blue", ""},
+		"value with equals": {false, "query=a=b", "query", "String", "a=b", ""},
+		"number":            {false, "size:Number=5", "size", "Number", "5", ""},
+		"string array":      {false, `tags:String.Array=["a", "b"]`, "tags", "String.Array", `["a", "b"]`, ""},
+		"binary":            {false, "payload:Binary=YmF6", "payload", "Binary", "", "baz"},
+		"invalid binary":    {true, "payload:Binary=not base64!", "", "", "", ""},
+		"no value":          {true, "colour", "", "", "", ""},
+		"no name":           {true, "=blue", "", "", "", ""},
+		"no type":           {true, "colour:=blue", "", "", "", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resultName, result, err := parseMessageAttribute(test.attribute)
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if test.shouldErr {
+				return
+			}
+
+			if resultName != test.expectedName || aws.ToString(result.DataType) != test.expectedType {
+				t.Fatalf("Attribute %s of type %s did not match expected %s of type %s", resultName, aws.ToString(result.DataType), test.expectedName, test.expectedType)
+			}
+
+			if aws.ToString(result.StringValue) != test.expectedString || string(result.BinaryValue) != test.expectedBinary {
+				t.Fatalf("Attribute value %s %s did not match expected value %s %s", aws.ToString(result.StringValue), result.BinaryValue, test.expectedString, test.expectedBinary)
+			}
+		})
+	}
+}
+
+func TestNewPublishInput(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		topicArn  string
+		opts      publishOptions
+	}{
+		"message": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			publishOptions{message: "hello", subject: "greeting", attributes: []string{"colour=blue"}},
+		},
+		"empty message": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			publishOptions{},
+		},
+		"message structure": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			publishOptions{message: `{"default": "hello", "sqs": "hello queue"}`, messageStructure: true},
+		},
+		"message structure without default": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			publishOptions{message: `{"sqs": "hello queue"}`, messageStructure: true},
+		},
+		"message structure that isn't JSON": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			publishOptions{message: "hello", messageStructure: true},
+		},
+		"invalid attribute": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:orders",
+			publishOptions{message: "hello", attributes: []string{"colour"}},
+		},
+		"fifo topic": {
+			false,
+			"arn:aws:sns:us-east-1:123456789012:orders.fifo",
+			publishOptions{message: "hello", messageGroupId: "group", messageDeduplicationId: "1"},
+		},
+		"fifo topic without group": {
+			true,
+			"arn:aws:sns:us-east-1:123456789012:orders.fifo",
+			publishOptions{message: "hello"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := newPublishInput(test.topicArn, test.opts)
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if test.shouldErr {
+				return
+			}
+
+			if *result.TopicArn != test.topicArn || *result.Message != test.opts.message || aws.ToString(result.Subject) != test.opts.subject {
+				t.Fatalf("Publish input %+v did not match options %+v", result, test.opts)
+			}
+
+			if len(result.MessageAttributes) != len(test.opts.attributes) {
+				t.Fatalf("Expected %d message attributes but got %d", len(test.opts.attributes), len(result.MessageAttributes))
+			}
+
+			if test.opts.messageStructure != (aws.ToString(result.MessageStructure) == "json") {
+				t.Fatalf("Expected message structure to be set: %t but got %s", test.opts.messageStructure, aws.ToString(result.MessageStructure))
+			}
+
+			if aws.ToString(result.MessageGroupId) != test.opts.messageGroupId || aws.ToString(result.MessageDeduplicationId) != test.opts.messageDeduplicationId {
+				t.Fatalf("Publish input %+v did not have the message group and deduplication IDs from %+v", result, test.opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+)
+
+// snsClientProvider returns SNS clients for each region built from the same configuration, creating each one only once.
+// It's safe to call from multiple goroutines.
+func snsClientProvider(cfg aws.Config) listener.SNSClientProvider {
+	clients := map[string]*sns.Client{}
+	mu := sync.Mutex{}
+
+	return func(region string) listener.SNSAPI {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, ok := clients[region]; !ok {
+			clients[region] = sns.NewFromConfig(cfg, func(o *sns.Options) {
+				o.Region = region
+			})
+		}
+
+		return clients[region]
+	}
+}
+
+// subscriptionQueue returns the ARN of the queue an existing subscription delivers to, using the SNS client for the
+// subscription's region.
+func subscriptionQueue(ctx context.Context, provider listener.SNSClientProvider, subscriptionArn string) (string, error) {
+	parsed, err := arn.Parse(subscriptionArn)
+
+	if err != nil {
+		return "", err
+	}
+
+	result, err := provider(parsed.Region).GetSubscriptionAttributes(
+		ctx,
+		&sns.GetSubscriptionAttributesInput{
+			SubscriptionArn: aws.String(subscriptionArn),
+		},
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return result.Attributes["Endpoint"], nil
+}
+
+// queueRegion decides which region the SQS client should use. An explicitly provided region always wins,
+// then the region of an existing queue or the queue of an existing subscription, then the region of the first topic so that a new queue is created
+// alongside it. If none of those are known the default region is used.
+func queueRegion(explicitRegion string, existingQueue string, topicArns []string, defaultRegion string) string {
+	if explicitRegion != "" {
+		return explicitRegion
+	}
+
+	if existingQueue != "" {
+		if region := regionFromQueue(existingQueue); region != "" {
+			return region
+		}
+
+		return defaultRegion
+	}
+
+	if len(topicArns) > 0 {
+		if parsed, err := arn.Parse(topicArns[0]); err == nil && parsed.Region != "" {
+			return parsed.Region
+		}
+	}
+
+	return defaultRegion
+}
+
+// regionFromQueue returns the region of a queue from its ARN or URL, or an empty string if it can't tell.
+func regionFromQueue(queue string) string {
+	if parsed, err := arn.Parse(queue); err == nil {
+		return parsed.Region
+	}
+
+	queueUrl, err := url.Parse(queue)
+
+	if err != nil {
+		return ""
+	}
+
+	hostname := strings.Split(queueUrl.Hostname(), ".")
+
+	// https://sqs.us-east-1.amazonaws.com/123456789012/my-queue
+	if len(hostname) > 2 && hostname[0] == "sqs" {
+		return hostname[1]
+	}
+
+	// https://us-east-1.queue.amazonaws.com/123456789012/my-queue
+	if len(hostname) > 2 && hostname[1] == "queue" {
+		return hostname[0]
+	}
+
+	return ""
+}
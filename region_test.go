@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/listenertest"
+)
+
+func TestQueueRegion(t *testing.T) {
+	tests := map[string]struct {
+		explicitRegion string
+		existingQueue  string
+		topicArns      []string
+		expected       string
+	}{
+		"explicit region": {
+			"eu-west-1",
+			"arn:aws:sqs:us-west-2:123456789012:my-queue",
+			[]string{"arn:aws:sns:ap-southeast-2:123456789012:my-topic"},
+			"eu-west-1",
+		},
+		"existing queue ARN": {
+			"",
+			"arn:aws:sqs:us-west-2:123456789012:my-queue",
+			[]string{"arn:aws:sns:ap-southeast-2:123456789012:my-topic"},
+			"us-west-2",
+		},
+		"existing subscription queue": {
+			"",
+			"arn:aws:sqs:eu-central-1:123456789012:subscribed-queue",
+			[]string{},
+			"eu-central-1",
+		},
+		"existing queue URL": {
+			"",
+			"https://sqs.us-west-2.amazonaws.com/123456789012/my-queue",
+			[]string{},
+			"us-west-2",
+		},
+		"existing legacy queue URL": {
+			"",
+			"https://us-west-2.queue.amazonaws.com/123456789012/my-queue",
+			[]string{},
+			"us-west-2",
+		},
+		"existing queue URL without a region": {
+			"",
+			"http://localhost:4566/123456789012/my-queue",
+			[]string{"arn:aws:sns:ap-southeast-2:123456789012:my-topic"},
+			"us-east-1",
+		},
+		"first topic": {
+			"",
+			"",
+			[]string{"arn:aws:sns:ap-southeast-2:123456789012:my-topic", "arn:aws:sns:eu-west-1:123456789012:my-topic"},
+			"ap-southeast-2",
+		},
+		"no topics": {
+			"",
+			"",
+			[]string{},
+			"us-east-1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := queueRegion(test.explicitRegion, test.existingQueue, test.topicArns, "us-east-1")
+
+			if result != test.expected {
+				t.Fatalf("Expected region %s but got %s", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSubscriptionQueue(t *testing.T) {
+	ctx := context.TODO()
+	f := listenertest.New(listenertest.WithRegion("eu-central-1"))
+
+	topic, _ := f.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String("valid-topic")})
+	queue, _ := f.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String("valid-queue")})
+	queueArn, _ := f.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: queue.QueueUrl, AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn}})
+	subscription, _ := f.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn: topic.TopicArn,
+		Protocol: aws.String("sqs"),
+		Endpoint: aws.String(queueArn.Attributes["QueueArn"]),
+	})
+
+	tests := map[string]struct {
+		shouldErr       bool
+		subscriptionArn string
+		expected        string
+	}{
+		"existing subscription": {false, *subscription.SubscriptionArn, queueArn.Attributes["QueueArn"]},
+		"missing subscription":  {true, "arn:aws:sns:eu-central-1:123456789012:valid-topic:missing", ""},
+		"invalid ARN":           {true, "not-an-arn", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			regions := []string{}
+			provider := func(region string) listener.SNSAPI {
+				regions = append(regions, region)
+				return f
+			}
+
+			result, err := subscriptionQueue(ctx, provider, test.subscriptionArn)
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if result != test.expected {
+				t.Fatalf("Queue %s did not match expected value %s", result, test.expected)
+			}
+
+			if len(regions) > 0 && regions[0] != "eu-central-1" {
+				t.Fatalf("Expected the subscription's region to be used but got %v", regions)
+			}
+		})
+	}
+}
+
+func TestSNSClientProvider(t *testing.T) {
+	provider := snsClientProvider(aws.Config{Region: "us-east-1"})
+	regions := []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-2"}
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(region string) {
+			defer wg.Done()
+			provider(region)
+		}(regions[i%len(regions)])
+	}
+
+	wg.Wait()
+
+	for _, region := range regions {
+		client := provider(region)
+
+		if client == nil {
+			t.Fatalf("Expected a client for region %s but got nil", region)
+		}
+
+		if provider(region) != client {
+			t.Fatalf("Expected the client for region %s to be reused", region)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/recording"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+)
+
+// replay republishes the messages in a recording to a topic, or prints them the same as messages received from a
+// queue if there's no topic, until every message is replayed or it's interrupted.
+func replay(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	topicArn := flags.String("t", "", "Optional ARN of a topic to publish the recorded messages to instead of printing them")
+	speed := flags.Float64("speed", 1, "Speed to replay messages at compared to when they were received, 0 replays them without waiting")
+	loadOptions := configFlags(flags, sns.ServiceID, sts.ServiceID)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of aws-sns-listener replay [flags] path/to/recording.ndjson:\n")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	target, err := replayTarget(ctx, *topicArn, loadOptions())
+
+	if err != nil {
+		return err
+	}
+
+	reader, err := recording.Open(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	replayed := 0
+
+	err = recording.Replay(
+		ctx,
+		reader,
+		func(ctx context.Context, record recording.Record) error {
+			err := target(ctx, record)
+
+			if err == nil {
+				replayed++
+			}
+
+			return err
+		},
+		recording.WithSpeed(*speed),
+	)
+
+	if errors.Is(err, context.Canceled) {
+		log.Print("Received interrupt instruction, stopping replay")
+		err = nil
+	}
+
+	log.Printf("Replayed %d messages", replayed)
+
+	return err
+}
+
+// replayTarget returns where to replay messages to. Without a topic messages are passed to the same consumer used when
+// listening so they're printed.
+func replayTarget(ctx context.Context, topicArn string, opts configOptions) (recording.Target, error) {
+	if topicArn == "" {
+		return recording.ConsumerTarget(consumer{}), nil
+	}
+
+	parsedTopicArn, err := listener.ParseTopicArn(topicArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadConfig(ctx, opts, os.Stdin, os.Stderr)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+
+	client := sns.NewFromConfig(cfg, func(o *sns.Options) {
+		o.Region = parsedTopicArn.Region
+	})
+
+	return recording.TopicTarget(client, topicArn), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReplayTarget(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr bool
+		topicArn  string
+	}{
+		"no topic":          {false, ""},
+		"topic":             {false, "arn:aws:sns:ap-southeast-2:123456789012:orders"},
+		"invalid topic ARN": {true, "arn:aws:sqs:ap-southeast-2:123456789012:orders"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			target, err := replayTarget(context.TODO(), test.topicArn, configOptions{region: "ap-southeast-2"})
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if err == nil && target == nil {
+				t.Fatal("Expected a target but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/whatsfordinner/aws-sns-listener/internal/emulator"
+	"github.com/whatsfordinner/aws-sns-listener/pkg/listener/listenertest"
+)
+
+// serve runs an SNS and SQS emulator until it's interrupted.
+func serve(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:4566", "Address to listen on")
+	region := flags.String("region", "us-east-1", "Region of the emulated topics and queues")
+	accountId := flags.String("account", "000000000000", "Account ID that owns the emulated topics and queues")
+	topicNames := listFlag{}
+	flags.Var(&topicNames, "t", "The name of a topic to create on start up, can be repeated or @path/to/names.txt")
+	verbose := flags.Bool("v", false, "Log every request")
+
+	_ = flags.Parse(args)
+
+	ln, err := net.Listen("tcp", *addr)
+
+	if err != nil {
+		return err
+	}
+
+	fake, err := newFake(ctx, *region, *accountId, "http://"+ln.Addr().String(), topicNames)
+
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	for _, topicArn := range fake.Topics() {
+		log.Printf("Created topic %s", topicArn)
+	}
+
+	var logger *log.Logger
+
+	if *verbose {
+		logger = log.Default()
+	}
+
+	server := &http.Server{Handler: emulator.New(fake, logger)}
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- server.Serve(ln)
+	}()
+
+	log.Printf("Emulating SNS and SQS at http://%s", ln.Addr().String())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-sigCh:
+		log.Print("Received interrupt instruction, shutting down")
+	}
+
+	err = server.Shutdown(ctx)
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// newFake returns a Fake with queue URLs that point at the emulator and the named topics already created.
+// Topics with names ending in ".fifo" are created as FIFO topics.
+func newFake(ctx context.Context, region string, accountId string, baseUrl string, topicNames []string) (*listenertest.Fake, error) {
+	fake := listenertest.New(
+		listenertest.WithRegion(region),
+		listenertest.WithAccountID(accountId),
+		listenertest.WithQueueBaseURL(baseUrl),
+	)
+
+	for _, topicName := range topicNames {
+		attributes := map[string]string{}
+
+		if strings.HasSuffix(topicName, ".fifo") {
+			attributes["FifoTopic"] = "true"
+		}
+
+		_, err := fake.CreateTopic(ctx, &sns.CreateTopicInput{Name: aws.String(topicName), Attributes: attributes})
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to create topic %s: %w", topicName, err)
+		}
+	}
+
+	return fake, nil
+}
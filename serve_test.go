@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+func TestNewFake(t *testing.T) {
+	tests := map[string]struct {
+		shouldErr    bool
+		topicNames   []string
+		expected     []string
+		expectedFIFO map[string]string
+	}{
+		"no topics": {
+			false,
+			[]string{},
+			[]string{},
+			map[string]string{},
+		},
+		"standard and fifo topics": {
+			false,
+			[]string{"orders", "payments.fifo"},
+			[]string{"arn:aws:sns:ap-southeast-2:000000000000:orders", "arn:aws:sns:ap-southeast-2:000000000000:payments.fifo"},
+			map[string]string{
+				"arn:aws:sns:ap-southeast-2:000000000000:orders":        "",
+				"arn:aws:sns:ap-southeast-2:000000000000:payments.fifo": "true",
+			},
+		},
+		"invalid topic name": {
+			true,
+			[]string{"orders!"},
+			nil,
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+			fake, err := newFake(ctx, "ap-southeast-2", "000000000000", "http://localhost:4566", test.topicNames)
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf("Expected no error but got %s", err.Error())
+			}
+
+			if test.shouldErr {
+				return
+			}
+
+			topicArns := fake.Topics()
+
+			if len(topicArns) != len(test.expected) {
+				t.Fatalf("Topics %v did not match expected values %v", topicArns, test.expected)
+			}
+
+			for i := range topicArns {
+				if topicArns[i] != test.expected[i] {
+					t.Fatalf("Topics %v did not match expected values %v", topicArns, test.expected)
+				}
+
+				result, _ := fake.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(topicArns[i])})
+
+				if result.Attributes["FifoTopic"] != test.expectedFIFO[topicArns[i]] {
+					t.Fatalf("Expected FifoTopic %s for %s but got %s", test.expectedFIFO[topicArns[i]], topicArns[i], result.Attributes["FifoTopic"])
+				}
+			}
+		})
+	}
+}
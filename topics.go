@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// listFlag collects the values of a flag that can be provided more than once.
+// Values prefixed with "@" are treated as a path to a file with one value per line.
+// Blank lines and lines starting with "#" in the file are ignored.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	if !strings.HasPrefix(value, "@") {
+		*f = append(*f, value)
+		return nil
+	}
+
+	file, err := os.Open(strings.TrimPrefix(value, "@"))
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		*f = append(*f, line)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListFlag(t *testing.T) {
+	dir := t.TempDir()
+	topicsPath := filepath.Join(dir, "topics.txt")
+
+	_ = os.WriteFile(
+		topicsPath,
+		[]byte("# Order topics\narn:aws:sns:us-east-1:123456789012:orders\n\n  arn:aws:sns:us-east-1:123456789012:refunds  \n"),
+		0600,
+	)
+
+	tests := map[string]struct {
+		shouldErr bool
+		values    []string
+		expected  []string
+	}{
+		"single value": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:orders"},
+			[]string{"arn:aws:sns:us-east-1:123456789012:orders"},
+		},
+		"repeated values": {
+			false,
+			[]string{"arn:aws:sns:us-east-1:123456789012:orders", "arn:aws:sns:us-east-1:123456789012:payments"},
+			[]string{"arn:aws:sns:us-east-1:123456789012:orders", "arn:aws:sns:us-east-1:123456789012:payments"},
+		},
+		"file and value": {
+			false,
+			[]string{"@" + topicsPath, "arn:aws:sns:us-east-1:123456789012:payments"},
+			[]string{
+				"arn:aws:sns:us-east-1:123456789012:orders",
+				"arn:aws:sns:us-east-1:123456789012:refunds",
+				"arn:aws:sns:us-east-1:123456789012:payments",
+			},
+		},
+		"missing file": {
+			true,
+			[]string{"@" + filepath.Join(dir, "missing.txt")},
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := listFlag{}
+
+			var err error
+
+			for _, value := range test.values {
+				err = result.Set(value)
+
+				if err != nil {
+					break
+				}
+			}
+
+			if err != nil && !test.shouldErr {
+				t.Fatalf(
+					"Expected no error but got %s",
+					err.Error(),
+				)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("Expected error but got no error")
+			}
+
+			if err == nil && !test.shouldErr {
+				if len(result) != len(test.expected) {
+					t.Fatalf(
+						"Values %v did not match expected values %v",
+						result,
+						test.expected,
+					)
+				}
+
+				for i, value := range test.expected {
+					if result[i] != value {
+						t.Fatalf(
+							"Values %v did not match expected values %v",
+							result,
+							test.expected,
+						)
+					}
+				}
+			}
+		})
+	}
+}